@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type Report struct {
+	Users         int                       `json:"users"`
+	TopUserAgents []Count                   `json:"top_user_agents"`
+	Families      []Count                   `json:"families"`
+	Versions      []Count                   `json:"versions"`
+	EmailDomains  []Count                   `json:"email_domains"`
+	CoOccurrence  map[string]map[string]int `json:"co_occurrence"`
+}
+
+// BrowserStats накапливает статистику по пользователям, переданным в Add
+type BrowserStats struct {
+	topN         int
+	users        int
+	userAgents   map[string]int
+	families     map[string]int
+	versions     map[string]int
+	emailDomains map[string]int
+	coOccurrence map[string]map[string]int
+}
+
+func NewBrowserStats(topN int) *BrowserStats {
+	return &BrowserStats{
+		topN:         topN,
+		userAgents:   make(map[string]int),
+		families:     make(map[string]int),
+		versions:     make(map[string]int),
+		emailDomains: make(map[string]int),
+		coOccurrence: make(map[string]map[string]int),
+	}
+}
+
+func (s *BrowserStats) Add(person Person) {
+	s.users++
+
+	userFamilies := make([]string, 0, len(person.Browsers))
+
+	for _, browser := range person.Browsers {
+		s.userAgents[browser]++
+
+		family, version := parseUserAgent(browser)
+		s.families[family]++
+		if version != "" {
+			s.versions[family+" "+version]++
+		}
+
+		if !contains(userFamilies, family) {
+			userFamilies = append(userFamilies, family)
+		}
+	}
+
+	for _, a := range userFamilies {
+		row, ok := s.coOccurrence[a]
+		if !ok {
+			row = make(map[string]int)
+			s.coOccurrence[a] = row
+		}
+		for _, b := range userFamilies {
+			row[b]++
+		}
+	}
+
+	if at := strings.LastIndexByte(person.Email, '@'); at >= 0 {
+		s.emailDomains[strings.ToLower(person.Email[at+1:])]++
+	}
+}
+
+func (s *BrowserStats) Report() *Report {
+	coOccurrence := make(map[string]map[string]int, len(s.coOccurrence))
+	for a, row := range s.coOccurrence {
+		coOccurrence[a] = make(map[string]int, len(row))
+		for b, count := range row {
+			coOccurrence[a][b] = count
+		}
+	}
+
+	return &Report{
+		Users:         s.users,
+		TopUserAgents: sortedCounts(s.userAgents, s.topN),
+		Families:      sortedCounts(s.families, 0),
+		Versions:      sortedCounts(s.versions, 0),
+		EmailDomains:  sortedCounts(s.emailDomains, 0),
+		CoOccurrence:  coOccurrence,
+	}
+}
+
+// sortedCounts сортирует по убыванию количества, при limit > 0 оставляет первые limit
+func sortedCounts(counts map[string]int, limit int) []Count {
+	result := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, Count{key, count})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+var userAgentFamilies = []struct {
+	family string
+	marker string
+}{
+	{"Edge", "Edge/"},
+	{"Opera", "OPR/"},
+	{"Opera", "Opera/"},
+	{"MSIE", "MSIE "},
+	{"MSIE", "Trident/"},
+	{"Android", "Android "},
+	{"Firefox", "Firefox/"},
+	{"Chrome", "Chrome/"},
+	{"Safari", "Safari/"},
+}
+
+// parseUserAgent определяет семейство браузера и его мажорную версию.
+// Android считается отдельным семейством, как и в FastSearch
+func parseUserAgent(ua string) (family string, version string) {
+	for _, f := range userAgentFamilies {
+		i := strings.Index(ua, f.marker)
+		if i < 0 {
+			continue
+		}
+
+		rest := ua[i+len(f.marker):]
+		switch f.marker {
+		case "Trident/":
+			if j := strings.Index(ua, "rv:"); j >= 0 {
+				rest = ua[j+len("rv:"):]
+			} else {
+				rest = ""
+			}
+		case "Opera/", "Safari/":
+			if j := strings.Index(ua, "Version/"); j >= 0 {
+				rest = ua[j+len("Version/"):]
+			}
+		}
+
+		return f.family, majorVersion(rest)
+	}
+
+	return "Other", ""
+}
+
+func majorVersion(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
+
+func contains(items []string, item string) bool {
+	for _, current := range items {
+		if current == item {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) families() []string {
+	result := make([]string, 0, len(r.Families))
+	for _, family := range r.Families {
+		result = append(result, family.Key)
+	}
+	return result
+}
+
+func (r *Report) WriteText(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Users\t"+strconv.Itoa(r.Users))
+
+	sections := []struct {
+		title  string
+		counts []Count
+	}{
+		{"Top user agents", r.TopUserAgents},
+		{"Browser families", r.Families},
+		{"Browser versions", r.Versions},
+		{"Email domains", r.EmailDomains},
+	}
+	for _, section := range sections {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, section.title+":")
+		for _, count := range section.counts {
+			fmt.Fprintf(w, "%d\t%s\n", count.Count, count.Key)
+		}
+	}
+
+	families := r.families()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Co-occurrence:")
+	fmt.Fprintln(w, "\t"+strings.Join(families, "\t"))
+	for _, a := range families {
+		fmt.Fprint(w, a)
+		for _, b := range families {
+			fmt.Fprintf(w, "\t%d", r.CoOccurrence[a][b])
+		}
+		fmt.Fprintln(w)
+	}
+
+	return w.Flush()
+}
+
+func (r *Report) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+
+	w.Write([]string{"section", "key", "count"})
+	w.Write([]string{"users", "", strconv.Itoa(r.Users)})
+
+	sections := []struct {
+		name   string
+		counts []Count
+	}{
+		{"user_agent", r.TopUserAgents},
+		{"family", r.Families},
+		{"version", r.Versions},
+		{"email_domain", r.EmailDomains},
+	}
+	for _, section := range sections {
+		for _, count := range section.counts {
+			w.Write([]string{section.name, count.Key, strconv.Itoa(count.Count)})
+		}
+	}
+
+	families := r.families()
+	for _, a := range families {
+		for _, b := range families {
+			if count := r.CoOccurrence[a][b]; count > 0 {
+				w.Write([]string{"co_occurrence", a + "+" + b, strconv.Itoa(count)})
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func (r *Report) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const baselineVersion = 1
+
+type Baseline struct {
+	Version    int                 `json:"version"`
+	GoOS       string              `json:"goos,omitempty"`
+	GoArch     string              `json:"goarch,omitempty"`
+	CPU        string              `json:"cpu,omitempty"`
+	Dataset    *Dataset            `json:"dataset,omitempty"`
+	Benchmarks map[string]*Samples `json:"benchmarks"`
+}
+
+type Dataset struct {
+	Users     int   `json:"users"`
+	Seed      int64 `json:"seed"`
+	Generator int   `json:"generator"`
+}
+
+type Samples struct {
+	NsPerOp     []float64 `json:"ns_per_op"`
+	BytesPerOp  []float64 `json:"bytes_per_op,omitempty"`
+	AllocsPerOp []float64 `json:"allocs_per_op,omitempty"`
+}
+
+var metrics = []struct {
+	name   string
+	unit   string
+	values func(*Samples) []float64
+}{
+	{"time", "ns/op", func(s *Samples) []float64 { return s.NsPerOp }},
+	{"alloc bytes", "B/op", func(s *Samples) []float64 { return s.BytesPerOp }},
+	{"allocs", "allocs/op", func(s *Samples) []float64 { return s.AllocsPerOp }},
+}
+
+func readBaseline(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d, want %d", path, baseline.Version, baselineVersion)
+	}
+	return &baseline, nil
+}
+
+func writeBaseline(path string, baseline *Baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// parseBenchOutput разбирает вывод go test -bench -benchmem. Суффикс
+// GOMAXPROCS (-8) у имён отбрасывается, чтобы базу можно было сравнивать
+// между машинами
+func parseBenchOutput(r io.Reader) (*Baseline, error) {
+	result := &Baseline{
+		Version:    baselineVersion,
+		Benchmarks: make(map[string]*Samples),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "goos: "):
+			result.GoOS = strings.TrimPrefix(line, "goos: ")
+			continue
+		case strings.HasPrefix(line, "goarch: "):
+			result.GoArch = strings.TrimPrefix(line, "goarch: ")
+			continue
+		case strings.HasPrefix(line, "cpu: "):
+			result.CPU = strings.TrimPrefix(line, "cpu: ")
+			continue
+		case !strings.HasPrefix(line, "Benchmark"):
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		name := trimProcs(fields[0])
+		samples, ok := result.Benchmarks[name]
+		if !ok {
+			samples = &Samples{}
+			result.Benchmarks[name] = samples
+		}
+
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad value in %q: %s", line, err)
+			}
+
+			switch fields[i+1] {
+			case "ns/op":
+				samples.NsPerOp = append(samples.NsPerOp, value)
+			case "B/op":
+				samples.BytesPerOp = append(samples.BytesPerOp, value)
+			case "allocs/op":
+				samples.AllocsPerOp = append(samples.AllocsPerOp, value)
+			}
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+func trimProcs(name string) string {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return name
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+	return name[:i]
+}
@@ -0,0 +1,235 @@
+// benchcheck прогоняет бенчмарки hw3_bench несколько раз и сравнивает
+// результаты с сохранённой базой. Запускать из hw3_bench:
+//
+//	go run ./benchcheck -users 10000 -update     # записать базу
+//	go run ./benchcheck                          # сравнить с базой
+//
+// При регрессии больше -threshold процентов, статистически значимой на
+// уровне -alpha, код выхода ненулевой. Если набор данных задан, data/users.txt
+// генерируется заново, но только поверх файла, который тоже записал usersgen:
+// настоящую выгрузку benchcheck не трогает
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/akurin/golang-webservices/hw3_bench/usersgen"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "package with benchmarks")
+	bench := flag.String("bench", ".", "benchmarks to run, as in go test -bench")
+	benchtime := flag.String("benchtime", "", "go test -benchtime")
+	count := flag.Int("count", 10, "runs per benchmark")
+	baselinePath := flag.String("baseline", "benchmarks.json", "baseline file")
+	update := flag.Bool("update", false, "write results to the baseline instead of comparing")
+	threshold := flag.Float64("threshold", 5, "allowed slowdown, percent")
+	alpha := flag.Float64("alpha", 0.05, "significance level")
+	users := flag.Int("users", 0, "generate data/users.txt with this many users before running")
+	seed := flag.Int64("seed", 1, "seed for generated users")
+	flag.Parse()
+
+	var baseline *Baseline
+	if !*update {
+		var err error
+		baseline, err = readBaseline(*baselinePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var dataset *Dataset
+	switch {
+	case *users > 0:
+		dataset = &Dataset{Users: *users, Seed: *seed, Generator: usersgen.Version}
+	case baseline != nil && baseline.Dataset != nil:
+		dataset = baseline.Dataset
+	}
+
+	if dataset != nil {
+		if dataset.Generator != usersgen.Version {
+			log.Fatalf("baseline dataset was made by generator version %d, current is %d; rerun with -update", dataset.Generator, usersgen.Version)
+		}
+		if baseline != nil && baseline.Dataset != nil && *baseline.Dataset != *dataset {
+			log.Fatalf("dataset %+v differs from baseline dataset %+v", *dataset, *baseline.Dataset)
+		}
+
+		dataDir := filepath.Join(*dir, "data")
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+		usersPath := filepath.Join(dataDir, "users.txt")
+		if err := checkReplaceable(usersPath); err != nil {
+			log.Fatal(err)
+		}
+		config := usersgen.Config{Users: dataset.Users, Seed: dataset.Seed}
+		if err := usersgen.GenerateFile(usersPath, config); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	current, err := runBenchmarks(*dir, *bench, *benchtime, *count)
+	if err != nil {
+		log.Fatal(err)
+	}
+	current.Dataset = dataset
+
+	if *update {
+		if err := writeBaseline(*baselinePath, current); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("baseline with %d benchmarks written to %s\n", len(current.Benchmarks), *baselinePath)
+		return
+	}
+
+	regressions := compare(os.Stdout, baseline, current, *threshold/100, *alpha)
+	if regressions > 0 {
+		fmt.Printf("%d regression(s) beyond %.1f%%\n", regressions, *threshold)
+		os.Exit(1)
+	}
+}
+
+// checkReplaceable не даёт затереть настоящую выгрузку: перезаписать можно
+// только файл, который записал usersgen
+func checkReplaceable(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	generated, err := usersgen.IsGenerated(path)
+	if err != nil {
+		return err
+	}
+	if !generated {
+		return fmt.Errorf("%s was not generated by usersgen, move it away to benchmark on a generated dataset", path)
+	}
+	return nil
+}
+
+func runBenchmarks(dir string, bench string, benchtime string, count int) (*Baseline, error) {
+	args := []string{"test", "-run", "^$", "-bench", bench, "-benchmem", "-count", strconv.Itoa(count)}
+	if benchtime != "" {
+		args = append(args, "-benchtime", benchtime)
+	}
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		os.Stdout.Write(out.Bytes())
+		return nil, fmt.Errorf("go test: %s", err)
+	}
+
+	result, err := parseBenchOutput(&out)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Benchmarks) == 0 {
+		return nil, fmt.Errorf("no benchmarks matched %q", bench)
+	}
+	return result, nil
+}
+
+// compare печатает таблицу в духе benchstat и возвращает число регрессий
+func compare(out io.Writer, baseline *Baseline, current *Baseline, threshold float64, alpha float64) int {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "name\tmetric\told\tnew\tdelta\t")
+
+	names := make([]string, 0, len(current.Benchmarks))
+	for name := range current.Benchmarks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	regressions := 0
+	for _, name := range names {
+		old, ok := baseline.Benchmarks[name]
+		if !ok {
+			fmt.Fprintf(w, "%s\t\t\t\tnew benchmark\t\n", name)
+			continue
+		}
+
+		for _, metric := range metrics {
+			oldValues := metric.values(old)
+			newValues := metric.values(current.Benchmarks[name])
+			if len(oldValues) == 0 || len(newValues) == 0 {
+				continue
+			}
+
+			oldMedian, newMedian := median(oldValues), median(newValues)
+			p := mannWhitneyU(oldValues, newValues)
+
+			var delta string
+			switch {
+			case p >= alpha:
+				delta = fmt.Sprintf("~ (p=%.3f n=%d+%d)", p, len(oldValues), len(newValues))
+			case oldMedian == 0:
+				delta = fmt.Sprintf("+inf (p=%.3f n=%d+%d)", p, len(oldValues), len(newValues))
+			default:
+				change := (newMedian - oldMedian) / oldMedian
+				delta = fmt.Sprintf("%+.2f%% (p=%.3f n=%d+%d)", change*100, p, len(oldValues), len(newValues))
+			}
+
+			regressed := p < alpha && newMedian > oldMedian && (oldMedian == 0 || (newMedian-oldMedian)/oldMedian > threshold)
+			if regressed {
+				regressions++
+				delta += " REGRESSION"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s ±%2.0f%%\t%s ±%2.0f%%\t%s\t\n",
+				name, metric.unit,
+				formatValue(oldMedian, metric.unit), spread(oldValues)*100,
+				formatValue(newMedian, metric.unit), spread(newValues)*100,
+				delta)
+		}
+	}
+
+	var missing []string
+	for name := range baseline.Benchmarks {
+		if _, ok := current.Benchmarks[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		fmt.Fprintf(w, "%s\t\t\t\tmissing from this run\t\n", name)
+	}
+
+	w.Flush()
+	return regressions
+}
+
+func formatValue(value float64, unit string) string {
+	var scales []string
+	switch unit {
+	case "ns/op":
+		scales = []string{"ns", "µs", "ms", "s"}
+	case "B/op":
+		scales = []string{"B", "kB", "MB", "GB"}
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	i := 0
+	for value >= 1000 && i < len(scales)-1 {
+		value /= 1000
+		i++
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64) + scales[i]
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akurin/golang-webservices/hw3_bench/usersgen"
+)
+
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: github.com/akurin/golang-webservices/hw3_bench
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkSlow-8   	      42	  34463447 ns/op	17519241 B/op	  158115 allocs/op
+BenchmarkFast-8   	     705	   2224216 ns/op	  532556 B/op	    7489 allocs/op
+BenchmarkFast-8   	     700	   2200000 ns/op	  532556 B/op	    7489 allocs/op
+BenchmarkFastParallel/workers=2-8  	     716	   2205457 ns/op	  632178 B/op	    7527 allocs/op
+PASS
+ok  	github.com/akurin/golang-webservices/hw3_bench	10.177s
+`
+
+func TestParseBenchOutput(t *testing.T) {
+	result, err := parseBenchOutput(strings.NewReader(benchOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.GoOS != "linux" || result.GoArch != "amd64" || result.CPU != "Intel(R) Xeon(R) Processor" {
+		t.Errorf("unexpected environment: %+v", result)
+	}
+
+	if len(result.Benchmarks) != 3 {
+		t.Fatalf("len(Benchmarks) = %v, want 3", len(result.Benchmarks))
+	}
+
+	fast := result.Benchmarks["BenchmarkFast"]
+	if fast == nil || len(fast.NsPerOp) != 2 || fast.NsPerOp[1] != 2200000 || fast.AllocsPerOp[0] != 7489 {
+		t.Errorf("unexpected BenchmarkFast samples: %+v", fast)
+	}
+
+	if _, ok := result.Benchmarks["BenchmarkFastParallel/workers=2"]; !ok {
+		t.Errorf("sub-benchmark not parsed: %v", result.Benchmarks)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	same := []float64{10, 11, 12, 10, 11, 12, 10, 11, 12, 11}
+	if p := mannWhitneyU(same, same); p < 0.5 {
+		t.Errorf("p for identical samples = %v, want >= 0.5", p)
+	}
+
+	slower := []float64{20, 21, 22, 20, 21, 22, 20, 21, 22, 21}
+	if p := mannWhitneyU(same, slower); p > 0.01 {
+		t.Errorf("p for separated samples = %v, want <= 0.01", p)
+	}
+
+	constant := []float64{7489, 7489, 7489}
+	if p := mannWhitneyU(constant, constant); p != 1 {
+		t.Errorf("p for constant samples = %v, want 1", p)
+	}
+}
+
+func TestCompareDetectsRegressions(t *testing.T) {
+	baseline := &Baseline{Benchmarks: map[string]*Samples{
+		"BenchmarkFast": {
+			NsPerOp:     []float64{100, 101, 99, 100, 102, 98, 100, 101},
+			AllocsPerOp: []float64{10, 10, 10, 10, 10, 10, 10, 10},
+		},
+		"BenchmarkGone": {NsPerOp: []float64{1}},
+	}}
+
+	tests := []struct {
+		name    string
+		nsPerOp []float64
+		want    int
+	}{
+		{"noise", []float64{101, 100, 99, 102, 100, 98, 101, 100}, 0},
+		{"small slowdown", []float64{103, 104, 103, 102, 104, 103, 104, 103}, 0},
+		{"regression", []float64{120, 121, 119, 120, 122, 118, 120, 121}, 1},
+		{"improvement", []float64{50, 51, 49, 50, 52, 48, 50, 51}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current := &Baseline{Benchmarks: map[string]*Samples{
+				"BenchmarkFast": {
+					NsPerOp:     test.nsPerOp,
+					AllocsPerOp: []float64{10, 10, 10, 10, 10, 10, 10, 10},
+				},
+			}}
+
+			if got := compare(ioutil.Discard, baseline, current, 0.05, 0.05); got != test.want {
+				t.Errorf("regressions = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckReplaceable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benchcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "users.txt")
+	if err := checkReplaceable(path); err != nil {
+		t.Errorf("missing file: %s", err)
+	}
+
+	if err := usersgen.GenerateFile(path, usersgen.Config{Users: 10, Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReplaceable(path); err != nil {
+		t.Errorf("generated file: %s", err)
+	}
+
+	dump := filepath.Join(dir, "dump.txt")
+	if err := ioutil.WriteFile(dump, []byte(`{"browsers":[],"email":"a@a.com","name":"A"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReplaceable(dump); err == nil {
+		t.Errorf("real dump must not be replaceable")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
+
+// spread - максимальное отклонение от медианы в долях медианы, как "±" у benchstat
+func spread(values []float64) float64 {
+	m := median(values)
+	if m == 0 || math.IsNaN(m) {
+		return 0
+	}
+
+	var result float64
+	for _, value := range values {
+		if d := math.Abs(value-m) / m; d > result {
+			result = d
+		}
+	}
+	return result
+}
+
+// mannWhitneyU возвращает двусторонний p-value U-критерия Манна-Уитни,
+// которым пользуется benchstat. Используется нормальное приближение с
+// поправкой на связки и на непрерывность
+func mannWhitneyU(a []float64, b []float64) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type ranked struct {
+		value float64
+		first bool
+	}
+	all := make([]ranked, 0, len(a)+len(b))
+	for _, value := range a {
+		all = append(all, ranked{value, true})
+	}
+	for _, value := range b {
+		all = append(all, ranked{value, false})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].value < all[j].value
+	})
+
+	var rankSumA, tieCorrection float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSumA += rank
+			}
+		}
+
+		ties := float64(j - i)
+		tieCorrection += ties*ties*ties - ties
+		i = j
+	}
+
+	u := rankSumA - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - tieCorrection/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"regexp"
 	"strings"
 	// "log"
@@ -14,14 +13,21 @@ import (
 const filePath string = "./data/users.txt"
 
 func SlowSearch(out io.Writer) {
-	file, err := os.Open(filePath)
-	if err != nil {
+	if _, err := SlowSearchWithOptions(out, SearchOptions{}); err != nil {
 		panic(err)
 	}
+}
 
-	fileContents, err := ioutil.ReadAll(file)
+func SlowSearchWithOptions(out io.Writer, opts SearchOptions) (*Summary, error) {
+	input, err := openInput(opts.path(), opts.ParallelDecode)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	defer input.Close()
+
+	fileContents, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
 	}
 
 	r := regexp.MustCompile("@")
@@ -31,13 +37,19 @@ func SlowSearch(out io.Writer) {
 
 	lines := strings.Split(string(fileContents), "\n")
 
+	summary := &Summary{Lines: len(lines)}
+
 	users := make([]map[string]interface{}, 0)
-	for _, line := range lines {
+	for i, line := range lines {
 		user := make(map[string]interface{})
 		// fmt.Printf("%v %v\n", err, line)
 		err := json.Unmarshal([]byte(line), &user)
 		if err != nil {
-			panic(err)
+			if err := summary.lineFailed(opts.Policy, i, err); err != nil {
+				return summary, err
+			}
+			// пустой пользователь, чтобы не сбить нумерацию строк
+			user = nil
 		}
 		users = append(users, user)
 	}
@@ -108,4 +120,5 @@ func SlowSearch(out io.Writer) {
 
 	fmt.Fprintln(out, "found users:\n"+foundUsers)
 	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	return summary, nil
 }
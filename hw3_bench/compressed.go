@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ErrCompressedInput - режим поиска работает только с несжатым файлом
+var ErrCompressedInput = errors.New("compressed input not supported in this mode")
+
+const (
+	readAheadBlockSize = 256 << 10
+	readAheadBlocks    = 4
+
+	// ограничивает память zstd-распаковщика: больше окна никто не пишет
+	// при обычных уровнях сжатия
+	zstdMaxWindow = 64 << 20
+)
+
+// openInput открывает файл и, если по магическим байтам он сжат gzip или
+// zstd, возвращает потоковый распаковщик. При parallel распаковка идёт в
+// отдельных горутинах: zstd распаковывает блоки параллельно, а gzip, который
+// так не умеет, распаковывается с упреждением, параллельно с разбором строк
+func openInput(path string, parallel bool) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		var decoded io.ReadCloser = gz
+		if parallel {
+			decoded = newReadAheadReader(gz, readAheadBlockSize, readAheadBlocks)
+		}
+		return &closingReader{decoded, []io.Closer{decoded, file}}, nil
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		concurrency := 1
+		if parallel {
+			concurrency = runtime.GOMAXPROCS(0)
+		}
+
+		zr, err := zstd.NewReader(buffered,
+			zstd.WithDecoderConcurrency(concurrency),
+			zstd.WithDecoderLowmem(!parallel),
+			zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		decoded := zr.IOReadCloser()
+		return &closingReader{decoded, []io.Closer{decoded, file}}, nil
+	}
+
+	return &closingReader{buffered, []io.Closer{file}}, nil
+}
+
+// isCompressed проверяет, начинается ли файл с магических байтов gzip или zstd
+func isCompressed(file io.ReaderAt) (bool, error) {
+	magic := make([]byte, len(zstdMagic))
+	n, err := file.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return bytes.HasPrefix(magic[:n], gzipMagic) || bytes.HasPrefix(magic[:n], zstdMagic), nil
+}
+
+type closingReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *closingReader) Close() error {
+	var result error
+	for _, closer := range r.closers {
+		if err := closer.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// readAheadReader читает src в отдельной горутине на несколько блоков вперёд
+type readAheadReader struct {
+	src io.ReadCloser
+
+	full chan readAheadBlock
+	free chan []byte
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	block   []byte
+	unread  []byte
+	lastErr error
+}
+
+type readAheadBlock struct {
+	data []byte
+	err  error
+}
+
+func newReadAheadReader(src io.ReadCloser, blockSize int, blocks int) *readAheadReader {
+	r := &readAheadReader{
+		src:     src,
+		full:    make(chan readAheadBlock, blocks),
+		free:    make(chan []byte, blocks),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	for i := 0; i < blocks; i++ {
+		r.free <- make([]byte, blockSize)
+	}
+
+	go r.fill()
+	return r
+}
+
+func (r *readAheadReader) fill() {
+	defer close(r.stopped)
+
+	for {
+		var buf []byte
+		select {
+		case buf = <-r.free:
+		case <-r.stop:
+			return
+		}
+
+		n, err := io.ReadFull(r.src, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+
+		select {
+		case r.full <- readAheadBlock{buf[:n], err}:
+		case <-r.stop:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *readAheadReader) Read(p []byte) (int, error) {
+	for len(r.unread) == 0 {
+		if r.lastErr != nil {
+			return 0, r.lastErr
+		}
+
+		if r.block != nil {
+			r.free <- r.block[:cap(r.block)]
+			r.block = nil
+		}
+
+		block := <-r.full
+		r.block = block.data
+		r.unread = block.data
+		r.lastErr = block.err
+	}
+
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+func (r *readAheadReader) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.stopped
+	return r.src.Close()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	compressedOnce sync.Once
+	compressedDir  string
+	compressedErr  error
+)
+
+// TestMain удаляет сжатые копии, общие для тестов и бенчмарков пакета
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if compressedDir != "" {
+		os.RemoveAll(compressedDir)
+	}
+	os.Exit(code)
+}
+
+// compressedUsers возвращает пути к users.txt, сжатому gzip и zstd
+func compressedUsers(tb testing.TB) (gzipPath string, zstdPath string) {
+	compressedOnce.Do(func() {
+		compressedDir, compressedErr = ioutil.TempDir("", "hw3_compressed")
+		if compressedErr != nil {
+			return
+		}
+
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			compressedErr = err
+			return
+		}
+
+		compressedErr = writeCompressed(filepath.Join(compressedDir, "users.txt.gz"), data, func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		})
+		if compressedErr != nil {
+			return
+		}
+
+		compressedErr = writeCompressed(filepath.Join(compressedDir, "users.txt.zst"), data, func(w io.Writer) (io.WriteCloser, error) {
+			// маленькие блоки, чтобы параллельной распаковке было что делать
+			return zstd.NewWriter(w, zstd.WithWindowSize(1<<16))
+		})
+	})
+	if compressedErr != nil {
+		tb.Fatal(compressedErr)
+	}
+
+	return filepath.Join(compressedDir, "users.txt.gz"), filepath.Join(compressedDir, "users.txt.zst")
+}
+
+func writeCompressed(path string, data []byte, newWriter func(io.Writer) (io.WriteCloser, error)) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w, err := newWriter(file)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func TestSearchCompressed(t *testing.T) {
+	gzipPath, zstdPath := compressedUsers(t)
+
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	for _, path := range []string{gzipPath, zstdPath} {
+		for _, parallel := range []bool{false, true} {
+			opts := SearchOptions{Path: path, ParallelDecode: parallel}
+
+			out := new(bytes.Buffer)
+			if _, err := FastSearchWithOptions(out, opts); err != nil {
+				t.Fatalf("%+v: %s", opts, err)
+			}
+			if out.String() != fastOut.String() {
+				t.Errorf("%+v: results not match\nGot:\n%v\nExpected:\n%v", opts, out.String(), fastOut.String())
+			}
+
+			slowOut := new(bytes.Buffer)
+			if _, err := SlowSearchWithOptions(slowOut, opts); err != nil {
+				t.Fatalf("%+v: %s", opts, err)
+			}
+			if slowOut.String() != fastOut.String() {
+				t.Errorf("%+v: slow results not match\nGot:\n%v\nExpected:\n%v", opts, slowOut.String(), fastOut.String())
+			}
+
+			parallelOut := new(bytes.Buffer)
+			if _, err := FastSearchParallel(parallelOut, opts, 4); err != nil {
+				t.Fatalf("%+v: %s", opts, err)
+			}
+			if parallelOut.String() != fastOut.String() {
+				t.Errorf("%+v: parallel results not match\nGot:\n%v\nExpected:\n%v", opts, parallelOut.String(), fastOut.String())
+			}
+		}
+
+		if _, err := FastSearchMmap(ioutil.Discard, SearchOptions{Path: path}); err != ErrCompressedInput {
+			t.Errorf("%s: FastSearchMmap error = %v, want %v", path, err, ErrCompressedInput)
+		}
+	}
+}
+
+func TestReadAheadReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	for _, blockSize := range []int{1, 7, 4096, len(data), 2 * len(data)} {
+		r := newReadAheadReader(ioutil.NopCloser(bytes.NewReader(data)), blockSize, 3)
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("blockSize %d: data mismatch", blockSize)
+		}
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// закрытие посреди чтения не должно зависать
+	r := newReadAheadReader(ioutil.NopCloser(bytes.NewReader(data)), 10, 2)
+	if _, err := r.Read(make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func benchmarkCompressed(b *testing.B, path string, parallel bool) {
+	opts := SearchOptions{Path: path, ParallelDecode: parallel}
+	for i := 0; i < b.N; i++ {
+		if _, err := FastSearchWithOptions(ioutil.Discard, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFastGzip(b *testing.B) {
+	gzipPath, _ := compressedUsers(b)
+	benchmarkCompressed(b, gzipPath, false)
+}
+
+func BenchmarkFastGzipParallel(b *testing.B) {
+	gzipPath, _ := compressedUsers(b)
+	benchmarkCompressed(b, gzipPath, true)
+}
+
+func BenchmarkFastZstd(b *testing.B) {
+	_, zstdPath := compressedUsers(b)
+	benchmarkCompressed(b, zstdPath, false)
+}
+
+func BenchmarkFastZstdParallel(b *testing.B) {
+	_, zstdPath := compressedUsers(b)
+	benchmarkCompressed(b, zstdPath, true)
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// PersonBytes - это Person, поля которого ссылаются на разобранную строку
+// (или на внутренний буфер, если в строке были escape-последовательности).
+// Значения действительны до следующего вызова Extract.
+type PersonBytes struct {
+	Browsers [][]byte
+	Email    []byte
+	Name     []byte
+
+	scratch []byte
+}
+
+const maxNestingDepth = 10000
+
+var (
+	keyBrowsers = []byte("browsers")
+	keyEmail    = []byte("email")
+	keyName     = []byte("name")
+)
+
+// Extract разбирает строку так же, как json.Unmarshal в Person, но без
+// аллокаций: буферы переиспользуются между вызовами
+func (p *PersonBytes) Extract(data []byte) error {
+	p.Browsers = p.Browsers[:0]
+	p.Email = nil
+	p.Name = nil
+
+	// одна ошибочная UTF-8 последовательность раскрывается максимум в 3 байта,
+	// так что такой ёмкости хватит на все строки и буфер не переедет во время разбора
+	if cap(p.scratch) < 3*len(data) {
+		p.scratch = make([]byte, 0, 3*len(data))
+	}
+	p.scratch = p.scratch[:0]
+
+	e := extractor{data: data, person: p}
+
+	e.skipSpace()
+	if err := e.topLevel(); err != nil {
+		return err
+	}
+	e.skipSpace()
+	if e.pos != len(e.data) {
+		return e.syntaxError("invalid character after top-level value")
+	}
+	return e.typeErr
+}
+
+type extractor struct {
+	data   []byte
+	pos    int
+	depth  int
+	person *PersonBytes
+
+	// как и encoding/json, несовпадение типов не прерывает разбор
+	typeErr error
+}
+
+func (e *extractor) syntaxError(msg string) error {
+	return fmt.Errorf("invalid json at offset %d: %s", e.pos, msg)
+}
+
+func (e *extractor) typeError(field string) {
+	if e.typeErr == nil {
+		e.typeErr = fmt.Errorf("invalid json at offset %d: unexpected type of %s", e.pos, field)
+	}
+}
+
+func (e *extractor) skipSpace() {
+	for e.pos < len(e.data) {
+		switch e.data[e.pos] {
+		case ' ', '\t', '\n', '\r':
+			e.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (e *extractor) peek() byte {
+	if e.pos < len(e.data) {
+		return e.data[e.pos]
+	}
+	return 0
+}
+
+func (e *extractor) topLevel() error {
+	switch e.peek() {
+	case '{':
+	case 'n':
+		return e.literal("null")
+	default:
+		e.typeError("person")
+		return e.skipValue()
+	}
+
+	return e.object(func(key []byte) error {
+		switch {
+		case bytes.EqualFold(key, keyBrowsers):
+			return e.browsers()
+		case bytes.EqualFold(key, keyEmail):
+			return e.stringField(&e.person.Email, "email")
+		case bytes.EqualFold(key, keyName):
+			return e.stringField(&e.person.Name, "name")
+		default:
+			return e.skipValue()
+		}
+	})
+}
+
+func (e *extractor) object(value func(key []byte) error) error {
+	if err := e.enter(); err != nil {
+		return err
+	}
+	e.pos++ // {
+
+	e.skipSpace()
+	if e.peek() == '}' {
+		e.pos++
+		e.depth--
+		return nil
+	}
+
+	for {
+		if e.peek() != '"' {
+			return e.syntaxError("looking for beginning of object key string")
+		}
+		key, err := e.str()
+		if err != nil {
+			return err
+		}
+
+		e.skipSpace()
+		if e.peek() != ':' {
+			return e.syntaxError("after object key")
+		}
+		e.pos++
+		e.skipSpace()
+
+		if err := value(key); err != nil {
+			return err
+		}
+
+		e.skipSpace()
+		switch e.peek() {
+		case ',':
+			e.pos++
+			e.skipSpace()
+		case '}':
+			e.pos++
+			e.depth--
+			return nil
+		default:
+			return e.syntaxError("after object key:value pair")
+		}
+	}
+}
+
+func (e *extractor) array(value func() error) error {
+	if err := e.enter(); err != nil {
+		return err
+	}
+	e.pos++ // [
+
+	e.skipSpace()
+	if e.peek() == ']' {
+		e.pos++
+		e.depth--
+		return nil
+	}
+
+	for {
+		if err := value(); err != nil {
+			return err
+		}
+
+		e.skipSpace()
+		switch e.peek() {
+		case ',':
+			e.pos++
+			e.skipSpace()
+		case ']':
+			e.pos++
+			e.depth--
+			return nil
+		default:
+			return e.syntaxError("after array element")
+		}
+	}
+}
+
+func (e *extractor) enter() error {
+	e.depth++
+	if e.depth > maxNestingDepth {
+		return e.syntaxError("exceeded max depth")
+	}
+	return nil
+}
+
+func (e *extractor) browsers() error {
+	switch e.peek() {
+	case 'n':
+		if err := e.literal("null"); err != nil {
+			return err
+		}
+		e.person.Browsers = e.person.Browsers[:0]
+		return nil
+	case '[':
+	default:
+		e.typeError("browsers")
+		return e.skipValue()
+	}
+
+	e.person.Browsers = e.person.Browsers[:0]
+	return e.array(func() error {
+		var browser []byte
+		if err := e.stringField(&browser, "browser"); err != nil {
+			return err
+		}
+		e.person.Browsers = append(e.person.Browsers, browser)
+		return nil
+	})
+}
+
+func (e *extractor) stringField(dst *[]byte, field string) error {
+	switch e.peek() {
+	case '"':
+		value, err := e.str()
+		if err != nil {
+			return err
+		}
+		*dst = value
+		return nil
+	case 'n':
+		return e.literal("null")
+	default:
+		e.typeError(field)
+		return e.skipValue()
+	}
+}
+
+func (e *extractor) skipValue() error {
+	switch c := e.peek(); {
+	case c == '{':
+		return e.object(func([]byte) error {
+			return e.skipValue()
+		})
+	case c == '[':
+		return e.array(e.skipValue)
+	case c == '"':
+		_, err := e.str()
+		return err
+	case c == '-' || c >= '0' && c <= '9':
+		return e.number()
+	case c == 't':
+		return e.literal("true")
+	case c == 'f':
+		return e.literal("false")
+	case c == 'n':
+		return e.literal("null")
+	default:
+		return e.syntaxError("looking for beginning of value")
+	}
+}
+
+func (e *extractor) literal(lit string) error {
+	if len(e.data)-e.pos < len(lit) || string(e.data[e.pos:e.pos+len(lit)]) != lit {
+		return e.syntaxError("in literal " + lit)
+	}
+	e.pos += len(lit)
+	return nil
+}
+
+func (e *extractor) number() error {
+	if e.peek() == '-' {
+		e.pos++
+	}
+
+	switch c := e.peek(); {
+	case c == '0':
+		e.pos++
+	case c >= '1' && c <= '9':
+		e.digits()
+	default:
+		return e.syntaxError("in numeric literal")
+	}
+
+	if e.peek() == '.' {
+		e.pos++
+		if e.digits() == 0 {
+			return e.syntaxError("after decimal point in numeric literal")
+		}
+	}
+
+	if c := e.peek(); c == 'e' || c == 'E' {
+		e.pos++
+		if c := e.peek(); c == '+' || c == '-' {
+			e.pos++
+		}
+		if e.digits() == 0 {
+			return e.syntaxError("in exponent of numeric literal")
+		}
+	}
+
+	return nil
+}
+
+func (e *extractor) digits() int {
+	start := e.pos
+	for c := e.peek(); c >= '0' && c <= '9'; c = e.peek() {
+		e.pos++
+	}
+	return e.pos - start
+}
+
+// str разбирает строку, начинающуюся с кавычки. Если строка не содержит
+// escape-последовательностей и является корректным UTF-8, то возвращается
+// кусок исходных данных, иначе строка раскодируется в scratch
+func (e *extractor) str() ([]byte, error) {
+	e.pos++ // "
+	start := e.pos
+
+	simple := true
+	for {
+		if e.pos >= len(e.data) {
+			return nil, e.syntaxError("in string literal")
+		}
+
+		c := e.data[e.pos]
+		switch {
+		case c == '"':
+			raw := e.data[start:e.pos]
+			e.pos++
+			if simple && utf8.Valid(raw) {
+				return raw, nil
+			}
+			return e.unquote(raw), nil
+		case c == '\\':
+			simple = false
+			e.pos++
+			switch e.peek() {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				e.pos++
+			case 'u':
+				e.pos++
+				for i := 0; i < 4; i++ {
+					if !isHex(e.peek()) {
+						return nil, e.syntaxError("in \\u hexadecimal character escape")
+					}
+					e.pos++
+				}
+			default:
+				return nil, e.syntaxError("in string escape code")
+			}
+		case c < ' ':
+			return nil, e.syntaxError("in string literal")
+		default:
+			e.pos++
+		}
+	}
+}
+
+// unquote повторяет правила encoding/json: некорректный UTF-8 и непарные
+// суррогаты заменяются на U+FFFD. Синтаксис уже проверен в str
+func (e *extractor) unquote(raw []byte) []byte {
+	scratch := e.person.scratch
+	start := len(scratch)
+
+	for r := 0; r < len(raw); {
+		c := raw[r]
+		switch {
+		case c == '\\':
+			r++
+			switch raw[r] {
+			case 'b':
+				scratch = append(scratch, '\b')
+			case 'f':
+				scratch = append(scratch, '\f')
+			case 'n':
+				scratch = append(scratch, '\n')
+			case 'r':
+				scratch = append(scratch, '\r')
+			case 't':
+				scratch = append(scratch, '\t')
+			case 'u':
+				rr := getu4(raw[r-1:])
+				r += 5
+				if utf16.IsSurrogate(rr) {
+					if dec := utf16.DecodeRune(rr, getu4(raw[r:])); dec != unicode.ReplacementChar {
+						r += 6
+						scratch = utf8.AppendRune(scratch, dec)
+						continue
+					}
+					rr = unicode.ReplacementChar
+				}
+				scratch = utf8.AppendRune(scratch, rr)
+				continue
+			default: // " \ /
+				scratch = append(scratch, raw[r])
+			}
+			r++
+		case c < utf8.RuneSelf:
+			scratch = append(scratch, c)
+			r++
+		default:
+			rr, size := utf8.DecodeRune(raw[r:])
+			r += size
+			scratch = utf8.AppendRune(scratch, rr)
+		}
+	}
+
+	e.person.scratch = scratch
+	return scratch[start:len(scratch):len(scratch)]
+}
+
+func getu4(s []byte) rune {
+	if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+		return -1
+	}
+	var r rune
+	for _, c := range s[2:6] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return -1
+		}
+		r = r*16 + rune(c)
+	}
+	return r
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
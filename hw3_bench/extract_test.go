@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+var extractSamples = []string{
+	`{"browsers":["Mozilla/5.0 (Linux; Android 4.4.2) Safari/537.36","Mozilla/4.0 (compatible; MSIE 8.0)"],"company":"Flashpoint","country":"Dominican Republic","email":"JonathanMorris@Muxo.edu","job":"Programmer Analyst #{N}","name":"Sharon Crawford","phone":"176-88-49"}`,
+	`{"name":"Jo\"hn A😀\ud800 \\ \/","email":null,"browsers":null}`,
+	`{"NAME":"case","Browſers":["fold"],"extra":{"a":[1,-2.5e+10,true,false,null,{}]}}`,
+	` null `,
+	`{"name":"a","name":"b","browsers":["x"],"browsers":[]}`,
+	`{"browsers":[null,"a"]}`,
+	"{\"name\":\"\xff\xfe invalid utf8\"}",
+	`{"name":5}`,
+	`{"browsers":"Android"}`,
+	`["not an object"]`,
+	`{"name":"unterminated}`,
+	`{"name":"a",}`,
+	`{"email":"\u12"}`,
+	`{"a":01}`,
+	`{"a":1.}`,
+	`{} {}`,
+	``,
+	"{\"name\":\"tab\tinside\"}",
+}
+
+func TestPersonBytesExtract(t *testing.T) {
+	for _, sample := range extractSamples {
+		checkExtractMatchesJSON(t, []byte(sample))
+	}
+}
+
+func FuzzPersonBytesExtract(f *testing.F) {
+	for _, sample := range extractSamples {
+		f.Add([]byte(sample))
+	}
+	f.Fuzz(checkExtractMatchesJSON)
+}
+
+// plainPerson не реализует json.Unmarshaler, так что разбирается самим encoding/json
+type plainPerson Person
+
+func checkExtractMatchesJSON(t *testing.T, data []byte) {
+	var want plainPerson
+	wantErr := json.Unmarshal(data, &want)
+
+	var got PersonBytes
+	err := got.Extract(data)
+
+	if (err != nil) != (wantErr != nil) {
+		t.Fatalf("Extract(%q) error = %v, json.Unmarshal error = %v", data, err, wantErr)
+	}
+	if err != nil {
+		return
+	}
+
+	if string(got.Name) != want.Name {
+		t.Errorf("Extract(%q) Name = %q, want %q", data, got.Name, want.Name)
+	}
+	if string(got.Email) != want.Email {
+		t.Errorf("Extract(%q) Email = %q, want %q", data, got.Email, want.Email)
+	}
+	if len(got.Browsers) != len(want.Browsers) {
+		t.Fatalf("Extract(%q) Browsers = %q, want %q", data, got.Browsers, want.Browsers)
+	}
+	for i := range want.Browsers {
+		if string(got.Browsers[i]) != want.Browsers[i] {
+			t.Errorf("Extract(%q) Browsers[%d] = %q, want %q", data, i, got.Browsers[i], want.Browsers[i])
+		}
+	}
+}
+
+func TestPersonBytesExtractDoesNotAllocate(t *testing.T) {
+	var lines [][]byte
+	for _, sample := range extractSamples[:3] {
+		lines = append(lines, []byte(sample))
+	}
+
+	var person PersonBytes
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, line := range lines {
+			if err := person.Extract(line); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("allocs = %v, want 0", allocs)
+	}
+}
+
+func TestSearchMmap(t *testing.T) {
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	mmapOut := new(bytes.Buffer)
+	if _, err := FastSearchMmap(mmapOut, SearchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fastOut.String() != mmapOut.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", mmapOut.String(), fastOut.String())
+	}
+}
+
+func BenchmarkPersonBytesExtract(b *testing.B) {
+	line := []byte(extractSamples[0])
+	var person PersonBytes
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	for i := 0; i < b.N; i++ {
+		if err := person.Extract(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFastMmap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := FastSearchMmap(ioutil.Discard, SearchOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
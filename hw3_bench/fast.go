@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -20,60 +19,102 @@ type Person struct {
 
 // вам надо написать более быструю оптимальную этой функции
 func FastSearch(out io.Writer) {
-	file, err := os.Open(filePath)
+	if _, err := fastSearch(out, SearchOptions{}, nil); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// FastSearchWithOptions не завершает процесс на ошибках, а возвращает их
+// или пропускает строки в соответствии с opts.Policy
+func FastSearchWithOptions(out io.Writer, opts SearchOptions) (*Summary, error) {
+	return fastSearch(out, opts, nil)
+}
+
+// FastSearchWithReport за тот же проход по файлу собирает статистику по браузерам
+func FastSearchWithReport(out io.Writer, topN int) *Report {
+	stats := NewBrowserStats(topN)
+	observe := func(_ int, person Person) {
+		stats.Add(person)
+	}
+	if _, err := fastSearch(out, SearchOptions{}, observe); err != nil {
+		log.Fatal(err)
+	}
+	return stats.Report()
+}
+
+func fastSearch(out io.Writer, opts SearchOptions, observe func(lineIndex int, person Person)) (*Summary, error) {
+	input, err := openInput(opts.path(), opts.ParallelDecode)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer input.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(input)
 
 	seenBrowsers := make(map[string]bool, 114)
 
 	var foundUsersBuffer bytes.Buffer
 
-	var lineIndex = -1
+	summary := &Summary{}
 
-	for scanner.Scan() {
-		lineIndex++
+	for ; scanner.Scan(); summary.Lines++ {
+		lineIndex := summary.Lines
 
 		var person Person
 		if err := person.UnmarshalJSON(scanner.Bytes()); err != nil {
-			log.Fatal(err)
+			if err := summary.lineFailed(opts.Policy, lineIndex, err); err != nil {
+				return summary, err
+			}
+			continue
 		}
 
-		isAndroid := false
-		isMSIE := false
+		if observe != nil {
+			observe(lineIndex, person)
+		}
 
-		browsers := person.Browsers
+		if !matchPerson(person, seenBrowsers) {
+			continue
+		}
 
-		for _, browser := range browsers {
-			if strings.Contains(browser, "Android") {
-				isAndroid = true
-				seenBrowsers[browser] = true
-			}
+		writeFoundUser(&foundUsersBuffer, lineIndex, person)
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, &LineError{Line: summary.Lines, Err: err}
+	}
 
-			if strings.Contains(browser, "MSIE") {
-				isMSIE = true
-				seenBrowsers[browser] = true
-			}
+	fmt.Fprintln(out, "found users:\n"+foundUsersBuffer.String())
+	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	return summary, nil
+}
+
+func matchPerson(person Person, seenBrowsers map[string]bool) bool {
+	isAndroid := false
+	isMSIE := false
+
+	for _, browser := range person.Browsers {
+		if strings.Contains(browser, "Android") {
+			isAndroid = true
+			seenBrowsers[browser] = true
 		}
 
-		if !(isAndroid && isMSIE) {
-			continue
+		if strings.Contains(browser, "MSIE") {
+			isMSIE = true
+			seenBrowsers[browser] = true
 		}
+	}
 
-		foundUsersBuffer.WriteString("[")
-		foundUsersBuffer.WriteString(strconv.Itoa(lineIndex))
-		foundUsersBuffer.WriteString("] ")
-		foundUsersBuffer.WriteString(person.Name)
-		foundUsersBuffer.WriteString(" <")
+	return isAndroid && isMSIE
+}
 
-		email := strings.Replace(person.Email, "@", " [at] ", 1)
-		foundUsersBuffer.WriteString(email)
+func writeFoundUser(buf *bytes.Buffer, lineIndex int, person Person) {
+	buf.WriteString("[")
+	buf.WriteString(strconv.Itoa(lineIndex))
+	buf.WriteString("] ")
+	buf.WriteString(person.Name)
+	buf.WriteString(" <")
 
-		foundUsersBuffer.WriteString(">\n")
-	}
+	email := strings.Replace(person.Email, "@", " [at] ", 1)
+	buf.WriteString(email)
 
-	fmt.Fprintln(out, "found users:\n"+foundUsersBuffer.String())
-	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	buf.WriteString(">\n")
 }
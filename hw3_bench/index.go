@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const indexMagic = "hw3idx1\n"
+
+var ErrStaleIndex = errors.New("index is stale: source file has changed")
+
+// badIndexError - файл по пути индекса не читается как индекс: чужой файл,
+// повреждённый или записанный в другом формате
+type badIndexError struct {
+	reason string
+}
+
+func (e *badIndexError) Error() string {
+	return e.reason
+}
+
+// Index - обратный индекс от строк браузеров к пользователям исходного файла.
+// Сам индекс хранит только смещения строк, имя и email при поиске читаются
+// из исходного файла, поэтому индекс привязан к его размеру и времени изменения
+type Index struct {
+	sourcePath  string
+	sourceSize  int64
+	sourceMtime int64
+
+	users    []userLine
+	browsers []indexedBrowser
+
+	// токен браузера -> номера браузеров, строится при загрузке
+	tokens map[string][]int
+}
+
+type userLine struct {
+	offset int64
+	length int64
+}
+
+type indexedBrowser struct {
+	name  string
+	users []int // по возрастанию
+}
+
+// IndexedSearch делает то же, что и FastSearch, используя индекс рядом с
+// файлом. Индекс перестраивается, если файл изменился
+func IndexedSearch(out io.Writer) error {
+	index, err := LoadOrBuildIndex(filePath, filePath+".idx")
+	if err != nil {
+		return err
+	}
+	return index.Search(out, "Android", "MSIE")
+}
+
+// LoadOrBuildIndex читает индекс из indexPath, а если его нет, он устарел
+// или не читается как индекс - строит заново и сохраняет
+func LoadOrBuildIndex(sourcePath string, indexPath string) (*Index, error) {
+	index, err := ReadIndexFile(sourcePath, indexPath)
+	if err == nil {
+		return index, nil
+	}
+	if _, bad := err.(*badIndexError); !bad && !os.IsNotExist(err) && err != ErrStaleIndex {
+		return nil, err
+	}
+
+	index, err = BuildIndex(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := index.WriteFile(indexPath); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func BuildIndex(sourcePath string) (*Index, error) {
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, unmap, err := mmapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+
+	index := &Index{
+		sourcePath:  sourcePath,
+		sourceSize:  info.Size(),
+		sourceMtime: info.ModTime().UnixNano(),
+	}
+
+	browserNumbers := make(map[string]int)
+
+	var person PersonBytes
+
+	for offset := 0; offset < len(data); {
+		end := len(data)
+		next := len(data)
+		if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+			end = offset + i
+			next = end + 1
+		}
+		line := bytes.TrimSuffix(data[offset:end], []byte{'\r'})
+
+		if err := person.Extract(line); err != nil {
+			return nil, fmt.Errorf("line %d: %s", len(index.users), err)
+		}
+
+		user := len(index.users)
+		index.users = append(index.users, userLine{int64(offset), int64(len(line))})
+
+		for _, browser := range person.Browsers {
+			number, ok := browserNumbers[string(browser)]
+			if !ok {
+				number = len(index.browsers)
+				browserNumbers[string(browser)] = number
+				index.browsers = append(index.browsers, indexedBrowser{name: string(browser)})
+			}
+
+			users := index.browsers[number].users
+			if len(users) == 0 || users[len(users)-1] != user {
+				index.browsers[number].users = append(users, user)
+			}
+		}
+
+		offset = next
+	}
+
+	index.buildTokens()
+	return index, nil
+}
+
+func (idx *Index) buildTokens() {
+	idx.tokens = make(map[string][]int)
+	for number, browser := range idx.browsers {
+		for _, token := range browserTokens(browser.name) {
+			numbers := idx.tokens[token]
+			if len(numbers) == 0 || numbers[len(numbers)-1] != number {
+				idx.tokens[token] = append(numbers, number)
+			}
+		}
+	}
+}
+
+func browserTokens(browser string) []string {
+	return strings.FieldsFunc(browser, isNotTokenRune)
+}
+
+func isNotTokenRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Fresh сообщает, соответствует ли индекс текущему состоянию исходного файла
+func (idx *Index) Fresh() (bool, error) {
+	info, err := os.Stat(idx.sourcePath)
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == idx.sourceSize && info.ModTime().UnixNano() == idx.sourceMtime, nil
+}
+
+// browsersContaining возвращает номера браузеров, содержащих substr.
+// Если substr - часть одного токена, перебираются только токены,
+// иначе все браузеры
+func (idx *Index) browsersContaining(substr string) []int {
+	var candidates []int
+	if strings.IndexFunc(substr, isNotTokenRune) < 0 {
+		for token, numbers := range idx.tokens {
+			if strings.Contains(token, substr) {
+				candidates = append(candidates, numbers...)
+			}
+		}
+		sort.Ints(candidates)
+	} else {
+		for number := range idx.browsers {
+			candidates = append(candidates, number)
+		}
+	}
+
+	var result []int
+	for i, number := range candidates {
+		if i > 0 && candidates[i-1] == number {
+			continue
+		}
+		if strings.Contains(idx.browsers[number].name, substr) {
+			result = append(result, number)
+		}
+	}
+	return result
+}
+
+// Search печатает то же, что и FastSearch, для пользователей, у которых
+// для каждой подстроки из browsers есть содержащий её браузер
+func (idx *Index) Search(out io.Writer, browsers ...string) error {
+	fresh, err := idx.Fresh()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrStaleIndex
+	}
+
+	seenBrowsers := make(map[int]bool)
+
+	var found []int
+	for i, substr := range browsers {
+		var users []int
+		for _, number := range idx.browsersContaining(substr) {
+			seenBrowsers[number] = true
+			users = append(users, idx.browsers[number].users...)
+		}
+		sort.Ints(users)
+
+		if i == 0 {
+			found = dedupSorted(users)
+		} else {
+			found = intersectSorted(found, users)
+		}
+	}
+
+	file, err := os.Open(idx.sourcePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var foundUsersBuffer bytes.Buffer
+
+	var person Person
+	var line []byte
+	for _, user := range found {
+		userLine := idx.users[user]
+		if int64(cap(line)) < userLine.length {
+			line = make([]byte, userLine.length)
+		}
+		line = line[:userLine.length]
+
+		if _, err := file.ReadAt(line, userLine.offset); err != nil {
+			return err
+		}
+		person = Person{}
+		if err := person.UnmarshalJSON(line); err != nil {
+			return fmt.Errorf("line %d: %s", user, err)
+		}
+
+		writeFoundUser(&foundUsersBuffer, user, person)
+	}
+
+	fmt.Fprintln(out, "found users:\n"+foundUsersBuffer.String())
+	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	return nil
+}
+
+func dedupSorted(items []int) []int {
+	result := items[:0]
+	for i, item := range items {
+		if i == 0 || items[i-1] != item {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func intersectSorted(a []int, b []int) []int {
+	var result []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			for i < len(a) && a[i] == b[j] {
+				i++
+			}
+			for j < len(b) && b[j] == result[len(result)-1] {
+				j++
+			}
+		}
+	}
+	return result
+}
+
+// WriteFile атомарно записывает индекс в path
+func (idx *Index) WriteFile(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if _, err := idx.WriteTo(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// WriteTo пишет индекс в формате:
+// магия, размер и mtime файла, смещения и длины строк (смещения - дельтами),
+// затем браузеры со списками пользователей (тоже дельтами). Все числа - uvarint
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	iw := &indexWriter{w: w}
+
+	iw.bytes([]byte(indexMagic))
+	iw.uvarint(uint64(idx.sourceSize))
+	iw.uvarint(uint64(idx.sourceMtime))
+
+	iw.uvarint(uint64(len(idx.users)))
+	var prevOffset int64
+	for _, user := range idx.users {
+		iw.uvarint(uint64(user.offset - prevOffset))
+		iw.uvarint(uint64(user.length))
+		prevOffset = user.offset
+	}
+
+	iw.uvarint(uint64(len(idx.browsers)))
+	for _, browser := range idx.browsers {
+		iw.uvarint(uint64(len(browser.name)))
+		iw.bytes([]byte(browser.name))
+
+		iw.uvarint(uint64(len(browser.users)))
+		prevUser := 0
+		for _, user := range browser.users {
+			iw.uvarint(uint64(user - prevUser))
+			prevUser = user
+		}
+	}
+
+	return iw.n, iw.err
+}
+
+type indexWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (iw *indexWriter) bytes(b []byte) {
+	if iw.err != nil {
+		return
+	}
+	n, err := iw.w.Write(b)
+	iw.n += int64(n)
+	iw.err = err
+}
+
+func (iw *indexWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(iw.buf[:], v)
+	iw.bytes(iw.buf[:n])
+}
+
+// ReadIndexFile читает индекс для sourcePath и возвращает ErrStaleIndex,
+// если файл с тех пор изменился
+func ReadIndexFile(sourcePath string, indexPath string) (*Index, error) {
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	index, err := ReadIndex(bufio.NewReader(file), sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fresh, err := index.Fresh()
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrStaleIndex
+	}
+	return index, nil
+}
+
+func ReadIndex(r *bufio.Reader, sourcePath string) (*Index, error) {
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != indexMagic {
+		return nil, &badIndexError{"not an index file"}
+	}
+
+	ir := &indexReader{r: r}
+	index := &Index{
+		sourcePath:  sourcePath,
+		sourceSize:  int64(ir.uvarint()),
+		sourceMtime: int64(ir.uvarint()),
+	}
+
+	usersCount := ir.count()
+	var offset int64
+	for i := 0; i < usersCount && ir.err == nil; i++ {
+		offset += int64(ir.uvarint())
+		index.users = append(index.users, userLine{offset, int64(ir.uvarint())})
+	}
+
+	browsersCount := ir.count()
+	for i := 0; i < browsersCount && ir.err == nil; i++ {
+		browser := indexedBrowser{name: string(ir.bytes(ir.count()))}
+
+		count := ir.count()
+		user := 0
+		for j := 0; j < count && ir.err == nil; j++ {
+			user += int(ir.uvarint())
+			if user >= usersCount {
+				ir.err = fmt.Errorf("user %d out of range", user)
+			}
+			browser.users = append(browser.users, user)
+		}
+
+		index.browsers = append(index.browsers, browser)
+	}
+
+	if ir.err != nil {
+		return nil, &badIndexError{fmt.Sprintf("corrupted index: %s", ir.err)}
+	}
+
+	index.buildTokens()
+	return index, nil
+}
+
+type indexReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (ir *indexReader) uvarint() uint64 {
+	if ir.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(ir.r)
+	ir.err = err
+	return v
+}
+
+func (ir *indexReader) count() int {
+	v := ir.uvarint()
+	if v > 1<<31 {
+		if ir.err == nil {
+			ir.err = fmt.Errorf("count %d is too large", v)
+		}
+		return 0
+	}
+	return int(v)
+}
+
+func (ir *indexReader) bytes(n int) []byte {
+	if ir.err != nil {
+		return nil
+	}
+	// строки длиннее не пропустил бы и bufio.Scanner при построении
+	if n > bufio.MaxScanTokenSize {
+		ir.err = fmt.Errorf("string of %d bytes is too long", n)
+		return nil
+	}
+	b := make([]byte, n)
+	_, ir.err = io.ReadFull(ir.r, b)
+	return b
+}
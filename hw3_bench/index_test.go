@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexedSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hw3_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	indexPath := filepath.Join(dir, "users.txt.idx")
+
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	index, err := LoadOrBuildIndex(filePath, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexOut := new(bytes.Buffer)
+	if err := index.Search(indexOut, "Android", "MSIE"); err != nil {
+		t.Fatal(err)
+	}
+	if fastOut.String() != indexOut.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", indexOut.String(), fastOut.String())
+	}
+
+	loaded, err := ReadIndexFile(filePath, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedOut := new(bytes.Buffer)
+	if err := loaded.Search(loadedOut, "Android", "MSIE"); err != nil {
+		t.Fatal(err)
+	}
+	if fastOut.String() != loadedOut.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", loadedOut.String(), fastOut.String())
+	}
+}
+
+func TestIndexSubstringQueries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hw3_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "users.txt")
+	source := `{"browsers":["Mozilla/4.0 (compatible; MSIE 8.0)","Opera/9.80 (Linux; Android 4.0)"],"email":"a@a.com","name":"A"}` + "\n" +
+		`{"browsers":["Mozilla/4.0 (compatible; MSIE 6.0)"],"email":"b@b.com","name":"B"}` + "\n" +
+		`{"browsers":["Mozilla/5.0 (Linux; Android 2.3)","Mozilla/4.0 (compatible; MSIE 8.0)"],"email":"c@c.com","name":"C"}` + "\n"
+	if err := ioutil.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query []string
+		want  string
+	}{
+		{[]string{"Android", "MSIE"}, "found users:\n[0] A <a [at] a.com>\n[2] C <c [at] c.com>\n\nTotal unique browsers 4\n"},
+		{[]string{"MSIE 8"}, "found users:\n[0] A <a [at] a.com>\n[2] C <c [at] c.com>\n\nTotal unique browsers 1\n"},
+		{[]string{"ndroi"}, "found users:\n[0] A <a [at] a.com>\n[2] C <c [at] c.com>\n\nTotal unique browsers 2\n"},
+		{[]string{"Opera", "MSIE 6"}, "found users:\n\nTotal unique browsers 2\n"},
+	}
+	for _, test := range tests {
+		out := new(bytes.Buffer)
+		if err := index.Search(out, test.query...); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("Search(%q) = %q, want %q", test.query, out.String(), test.want)
+		}
+	}
+}
+
+func TestIndexInvalidatedWhenSourceChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hw3_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "users.txt")
+	indexPath := sourcePath + ".idx"
+
+	first := `{"browsers":["Android","MSIE"],"email":"a@a.com","name":"A"}`
+	if err := ioutil.WriteFile(sourcePath, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := LoadOrBuildIndex(sourcePath, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := `{"browsers":["Android","MSIE"],"email":"b@b.com","name":"B"}`
+	if err := ioutil.WriteFile(sourcePath, []byte(second), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(sourcePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := index.Search(ioutil.Discard, "Android"); err != ErrStaleIndex {
+		t.Errorf("Search error = %v, want %v", err, ErrStaleIndex)
+	}
+	if _, err := ReadIndexFile(sourcePath, indexPath); err != ErrStaleIndex {
+		t.Errorf("ReadIndexFile error = %v, want %v", err, ErrStaleIndex)
+	}
+
+	index, err = LoadOrBuildIndex(sourcePath, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	if err := index.Search(out, "Android", "MSIE"); err != nil {
+		t.Fatal(err)
+	}
+	want := "found users:\n[0] B <b [at] b.com>\n\nTotal unique browsers 2\n"
+	if out.String() != want {
+		t.Errorf("Search = %q, want %q", out.String(), want)
+	}
+}
+
+func TestIndexRebuiltWhenFileIsNotIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hw3_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sourcePath := filepath.Join(dir, "users.txt")
+	indexPath := sourcePath + ".idx"
+
+	line := `{"browsers":["Android","MSIE"],"email":"a@a.com","name":"A"}`
+	if err := ioutil.WriteFile(sourcePath, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := BuildIndex(sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.WriteFile(indexPath); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string][]byte{
+		"garbage":   []byte("definitely not an index"),
+		"old":       append([]byte("hw3idx0\n"), valid[len(indexMagic):]...),
+		"truncated": valid[:len(valid)-1],
+	}
+	for name, data := range contents {
+		if err := ioutil.WriteFile(indexPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		index, err := LoadOrBuildIndex(sourcePath, indexPath)
+		if err != nil {
+			t.Errorf("%s: LoadOrBuildIndex error: %v", name, err)
+			continue
+		}
+		out := new(bytes.Buffer)
+		if err := index.Search(out, "Android", "MSIE"); err != nil {
+			t.Fatal(err)
+		}
+		want := "found users:\n[0] A <a [at] a.com>\n\nTotal unique browsers 2\n"
+		if out.String() != want {
+			t.Errorf("%s: Search = %q, want %q", name, out.String(), want)
+		}
+
+		// перестроенный индекс сохранён поверх испорченного
+		if _, err := ReadIndexFile(sourcePath, indexPath); err != nil {
+			t.Errorf("%s: ReadIndexFile error: %v", name, err)
+		}
+	}
+}
+
+func BenchmarkIndexed(b *testing.B) {
+	if _, err := LoadOrBuildIndex(filePath, filePath+".idx"); err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		if err := IndexedSearch(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
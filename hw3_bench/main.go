@@ -0,0 +1,33 @@
+package main
+
+// поисковый сервис поверх FastSearch:
+//
+//	go run . -addr :8080
+//	curl 'localhost:8080/search?browser=Android&browser=MSIE&format=json&limit=10'
+//	curl localhost:8080/metrics
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	path := flag.String("file", filePath, "users file, may be gzip or zstd compressed")
+	reload := flag.Duration("reload", 5*time.Second, "how often to check the users file for changes")
+	flag.Parse()
+
+	service, err := NewSearchService(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go service.Watch(*reload, nil)
+
+	http.Handle("/", service)
+
+	fmt.Println("starting server at", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -2,17 +2,41 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/akurin/golang-webservices/hw3_bench/usersgen"
 )
 
 // запускаем перед основными функциями по разу чтобы файл остался в памяти в файловом кеше
 // ioutil.Discard - это ioutil.Writer который никуда не пишет
 func init() {
+	if err := ensureUsersFile(); err != nil {
+		panic(err)
+	}
+
 	SlowSearch(ioutil.Discard)
 	FastSearch(ioutil.Discard)
 }
 
+// data/users.txt нет в репозитории, поэтому если его нет и рядом,
+// генерируем детерминированный
+func ensureUsersFile() error {
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return usersgen.GenerateFile(filePath, usersgen.Config{Users: 1000, Seed: 1})
+}
+
 // -----
 // go test -v
 
@@ -31,6 +55,26 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearchParallel(t *testing.T) {
+	slowOut := new(bytes.Buffer)
+	SlowSearch(slowOut)
+	slowResult := slowOut.String()
+
+	for _, workers := range []int{0, 1, 2, 3, 8, 1000} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			parallelOut := new(bytes.Buffer)
+			if _, err := FastSearchParallel(parallelOut, SearchOptions{}, workers); err != nil {
+				t.Fatal(err)
+			}
+			parallelResult := parallelOut.String()
+
+			if slowResult != parallelResult {
+				t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", parallelResult, slowResult)
+			}
+		})
+	}
+}
+
 // -----
 // go test -bench . -benchmem
 
@@ -45,3 +89,91 @@ func BenchmarkFast(b *testing.B) {
 		FastSearch(ioutil.Discard)
 	}
 }
+
+func BenchmarkFastParallel(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := FastSearchParallel(ioutil.Discard, SearchOptions{}, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchWithReport(t *testing.T) {
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	reportOut := new(bytes.Buffer)
+	report := FastSearchWithReport(reportOut, 10)
+
+	if fastOut.String() != reportOut.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", reportOut.String(), fastOut.String())
+	}
+
+	if len(report.TopUserAgents) > 10 {
+		t.Errorf("len(TopUserAgents) = %v, want <= 10", len(report.TopUserAgents))
+	}
+
+	browsers, users := 0, 0
+	for _, family := range report.Families {
+		browsers += family.Count
+		users += report.CoOccurrence[family.Key][family.Key]
+	}
+	if users < report.Users && len(report.Families) > 0 {
+		t.Errorf("co-occurrence diagonal covers %v users, want at least %v", users, report.Users)
+	}
+
+	versions := 0
+	for _, version := range report.Versions {
+		versions += version.Count
+	}
+	if versions > browsers {
+		t.Errorf("versions = %v, want <= %v", versions, browsers)
+	}
+
+	jsonOut := new(bytes.Buffer)
+	if err := report.WriteJSON(jsonOut); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, report) {
+		t.Errorf("json round trip mismatch")
+	}
+
+	if err := report.WriteText(ioutil.Discard); err != nil {
+		t.Error(err)
+	}
+	if err := report.WriteCSV(ioutil.Discard); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua          string
+		wantFamily  string
+		wantVersion string
+	}{
+		{"Mozilla/5.0 (Linux; U; Android 4.0.4; en-us) AppleWebKit/534.30 Version/4.0 Mobile Safari/534.30", "Android", "4"},
+		{"Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 6.0; Trident/4.0)", "MSIE", "8"},
+		{"Mozilla/5.0 (Windows NT 6.3; Trident/7.0; rv:11.0) like Gecko", "MSIE", "11"},
+		{"Mozilla/5.0 (Windows NT 10.0) AppleWebKit/537.36 Chrome/46.0.2486.0 Safari/537.36 Edge/13.10586", "Edge", "13"},
+		{"Opera/9.80 (Windows NT 6.0) Presto/2.12.388 Version/12.14", "Opera", "12"},
+		{"Mozilla/5.0 (Windows NT 6.1; WOW64; rv:40.0) Gecko/20100101 Firefox/40.1", "Firefox", "40"},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 Chrome/41.0.2227.0 Safari/537.36", "Chrome", "41"},
+		{"Mozilla/5.0 (Macintosh) AppleWebKit/600.7.12 (KHTML, like Gecko) Version/8.0.7 Safari/600.7.12", "Safari", "8"},
+		{"curl/7.54.0", "Other", ""},
+	}
+	for _, test := range tests {
+		family, version := parseUserAgent(test.ua)
+		if family != test.wantFamily || version != test.wantVersion {
+			t.Errorf("parseUserAgent(%q) = %v %v, want %v %v", test.ua, family, version, test.wantFamily, test.wantVersion)
+		}
+	}
+}
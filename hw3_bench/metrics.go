@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// границы корзин гистограммы задержек, в секундах
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// serviceMetrics отдаёт метрики в текстовом формате Prometheus
+type serviceMetrics struct {
+	mu sync.Mutex
+
+	latency map[int]*histogram // по коду ответа
+
+	reloadsOK     uint64
+	reloadsFailed uint64
+	users         int
+	skippedLines  int
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func newServiceMetrics() *serviceMetrics {
+	return &serviceMetrics{
+		latency: make(map[int]*histogram),
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (m *serviceMetrics) observe(handler http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	start := time.Now()
+	handler(recorder, r)
+	elapsed := time.Since(start).Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.latency[recorder.status]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.latency[recorder.status] = h
+	}
+
+	for i, bound := range latencyBuckets {
+		if elapsed <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += elapsed
+}
+
+func (m *serviceMetrics) reloaded(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.reloadsFailed++
+	} else {
+		m.reloadsOK++
+	}
+}
+
+func (m *serviceMetrics) setUsers(users int, skippedLines int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = users
+	m.skippedLines = skippedLines
+}
+
+func (m *serviceMetrics) writeTo(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetricHeader(w, "search_request_duration_seconds", "histogram", "Latency of /search requests.")
+
+	codes := make([]int, 0, len(m.latency))
+	for code := range m.latency {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		h := m.latency[code]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "search_request_duration_seconds_bucket{code=\"%d\",le=\"%s\"} %d\n",
+				code, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(w, "search_request_duration_seconds_bucket{code=\"%d\",le=\"+Inf\"} %d\n", code, h.count)
+		fmt.Fprintf(w, "search_request_duration_seconds_sum{code=\"%d\"} %s\n", code, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "search_request_duration_seconds_count{code=\"%d\"} %d\n", code, h.count)
+	}
+
+	writeMetricHeader(w, "search_reloads_total", "counter", "Reloads of the users file.")
+	fmt.Fprintf(w, "search_reloads_total{result=\"ok\"} %d\n", m.reloadsOK)
+	fmt.Fprintf(w, "search_reloads_total{result=\"error\"} %d\n", m.reloadsFailed)
+
+	writeMetricHeader(w, "search_users", "gauge", "Users in the loaded file.")
+	fmt.Fprintf(w, "search_users %d\n", m.users)
+
+	writeMetricHeader(w, "search_skipped_lines", "gauge", "Malformed lines skipped in the loaded file.")
+	fmt.Fprintf(w, "search_skipped_lines %d\n", m.skippedLines)
+}
+
+func writeMetricHeader(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
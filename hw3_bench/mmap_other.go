@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func mmapFile(file *os.File) (data []byte, unmap func() error, err error) {
+	data, err = ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
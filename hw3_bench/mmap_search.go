@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+var (
+	android = []byte("Android")
+	msie    = []byte("MSIE")
+)
+
+// FastSearchMmap делает то же, что и FastSearchWithOptions, но отображает
+// файл в память и разбирает строки через PersonBytes, не создавая строк для
+// каждого пользователя. Сжатый файл отобразить нельзя, для него возвращается
+// ErrCompressedInput
+func FastSearchMmap(out io.Writer, opts SearchOptions) (*Summary, error) {
+	file, err := os.Open(opts.path())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	compressed, err := isCompressed(file)
+	if err != nil {
+		return nil, err
+	}
+	if compressed {
+		return nil, ErrCompressedInput
+	}
+
+	data, unmap, err := mmapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+
+	seenBrowsers := make(map[string]bool, 114)
+
+	var foundUsersBuffer bytes.Buffer
+
+	var person PersonBytes
+
+	summary := &Summary{}
+
+	for ; len(data) > 0; summary.Lines++ {
+		lineIndex := summary.Lines
+
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+
+		if err := person.Extract(line); err != nil {
+			if err := summary.lineFailed(opts.Policy, lineIndex, err); err != nil {
+				return summary, err
+			}
+			continue
+		}
+
+		if !matchPersonBytes(&person, seenBrowsers) {
+			continue
+		}
+
+		foundUsersBuffer.WriteString("[")
+		foundUsersBuffer.WriteString(strconv.Itoa(lineIndex))
+		foundUsersBuffer.WriteString("] ")
+		foundUsersBuffer.Write(person.Name)
+		foundUsersBuffer.WriteString(" <")
+
+		if at := bytes.IndexByte(person.Email, '@'); at >= 0 {
+			foundUsersBuffer.Write(person.Email[:at])
+			foundUsersBuffer.WriteString(" [at] ")
+			foundUsersBuffer.Write(person.Email[at+1:])
+		} else {
+			foundUsersBuffer.Write(person.Email)
+		}
+
+		foundUsersBuffer.WriteString(">\n")
+	}
+
+	fmt.Fprintln(out, "found users:\n"+foundUsersBuffer.String())
+	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	return summary, nil
+}
+
+func matchPersonBytes(person *PersonBytes, seenBrowsers map[string]bool) bool {
+	isAndroid := false
+	isMSIE := false
+
+	for _, browser := range person.Browsers {
+		matched := false
+
+		if bytes.Contains(browser, android) {
+			isAndroid = true
+			matched = true
+		}
+
+		if bytes.Contains(browser, msie) {
+			isMSIE = true
+			matched = true
+		}
+
+		// поиск по string(browser) не аллоцирует, в отличие от вставки
+		if matched && !seenBrowsers[string(browser)] {
+			seenBrowsers[string(browser)] = true
+		}
+	}
+
+	return isAndroid && isMSIE
+}
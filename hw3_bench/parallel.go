@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+type chunk struct {
+	offset int64
+	size   int64
+}
+
+type chunkResult struct {
+	// номера строк в summary - от начала куска
+	summary      Summary
+	foundUsers   []foundUser
+	seenBrowsers map[string]bool
+	err          error
+}
+
+type foundUser struct {
+	lineIndex int
+	person    Person
+}
+
+// FastSearchParallel делает то же, что и FastSearchWithOptions, но разбивает
+// файл на выровненные по переводу строки куски и разбирает их на workers
+// горутинах. При workers <= 0 используется GOMAXPROCS. Сжатый файл на куски
+// не делится и разбирается одним потоком, распаковка - как в
+// FastSearchWithOptions. Номера строк в ошибках и в summary - во всём файле
+func FastSearchParallel(out io.Writer, opts SearchOptions, workers int) (*Summary, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	file, err := os.Open(opts.path())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	compressed, err := isCompressed(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []chunkResult
+	if compressed {
+		input, err := openInput(opts.path(), opts.ParallelDecode)
+		if err != nil {
+			return nil, err
+		}
+		defer input.Close()
+
+		results = []chunkResult{scanChunk(input, opts.Policy)}
+	} else {
+		results, err = scanChunks(file, workers, opts.Policy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seenBrowsers := make(map[string]bool, 114)
+
+	var foundUsersBuffer bytes.Buffer
+
+	summary := &Summary{}
+	for _, result := range results {
+		lineOffset := summary.Lines
+
+		if result.err != nil {
+			if lineErr, ok := result.err.(*LineError); ok {
+				lineErr.Line += lineOffset
+			}
+			summary.Lines += result.summary.Lines
+			return summary, result.err
+		}
+
+		for browser := range result.seenBrowsers {
+			seenBrowsers[browser] = true
+		}
+
+		for _, user := range result.foundUsers {
+			writeFoundUser(&foundUsersBuffer, lineOffset+user.lineIndex, user.person)
+		}
+
+		for _, lineErr := range result.summary.Errors {
+			lineErr.Line += lineOffset
+			summary.Errors = append(summary.Errors, lineErr)
+		}
+		summary.Skipped += result.summary.Skipped
+		summary.Lines += result.summary.Lines
+	}
+
+	fmt.Fprintln(out, "found users:\n"+foundUsersBuffer.String())
+	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	return summary, nil
+}
+
+// scanChunks разбирает несжатый файл кусками на workers горутинах
+func scanChunks(file *os.File, workers int, policy ErrorPolicy) ([]chunkResult, error) {
+	chunks, err := splitToChunks(file, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]chunkResult, len(chunks))
+
+	wg := &sync.WaitGroup{}
+	for i, c := range chunks {
+		wg.Add(1)
+		go func(i int, c chunk) {
+			defer wg.Done()
+			results[i] = scanChunk(io.NewSectionReader(file, c.offset, c.size), policy)
+		}(i, c)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func splitToChunks(file *os.File, count int) ([]chunk, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	var chunks []chunk
+
+	var start int64
+	for i := 1; i <= count && start < size; i++ {
+		end := size
+		if i < count {
+			end, err = nextLineStart(file, size*int64(i)/int64(count), size)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if end <= start {
+			continue
+		}
+
+		chunks = append(chunks, chunk{offset: start, size: end - start})
+		start = end
+	}
+
+	return chunks, nil
+}
+
+// nextLineStart возвращает смещение начала первой строки, начинающейся не раньше pos
+func nextLineStart(file io.ReaderAt, pos int64, size int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, 4096)
+	for pos <= size {
+		n, err := file.ReadAt(buf, pos-1)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i), nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		pos += int64(n)
+	}
+
+	return size, nil
+}
+
+func scanChunk(r io.Reader, policy ErrorPolicy) chunkResult {
+	result := chunkResult{
+		seenBrowsers: make(map[string]bool, 114),
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	for ; scanner.Scan(); result.summary.Lines++ {
+		lineIndex := result.summary.Lines
+
+		var person Person
+		if err := person.UnmarshalJSON(scanner.Bytes()); err != nil {
+			if err := result.summary.lineFailed(policy, lineIndex, err); err != nil {
+				result.err = err
+				return result
+			}
+			continue
+		}
+
+		if matchPerson(person, result.seenBrowsers) {
+			result.foundUsers = append(result.foundUsers, foundUser{lineIndex, person})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		result.err = &LineError{Line: result.summary.Lines, Err: err}
+	}
+	return result
+}
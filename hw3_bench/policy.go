@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ErrorPolicy определяет, что делать со строкой, которую не удалось разобрать
+type ErrorPolicy int
+
+const (
+	// FailFast прекращает поиск на первой ошибке
+	FailFast ErrorPolicy = iota
+	// SkipAndCount пропускает строку и только считает такие строки
+	SkipAndCount
+	// CollectErrors пропускает строку и запоминает ошибку в Summary.Errors
+	CollectErrors
+)
+
+type SearchOptions struct {
+	// по умолчанию ./data/users.txt, может быть сжат gzip или zstd
+	Path   string
+	Policy ErrorPolicy
+	// распаковывать сжатый файл в отдельных горутинах
+	ParallelDecode bool
+}
+
+func (opts SearchOptions) path() string {
+	if opts.Path == "" {
+		return filePath
+	}
+	return opts.Path
+}
+
+// LineError - ошибка разбора строки. Line считается с нуля, как и в выводе поиска
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LineError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Line  int    `json:"line"`
+		Error string `json:"error"`
+	}{e.Line, e.Err.Error()})
+}
+
+// Summary - итог разбора файла
+type Summary struct {
+	Lines   int         `json:"lines"`
+	Skipped int         `json:"skipped"`
+	Errors  []LineError `json:"errors,omitempty"`
+}
+
+// lineFailed применяет policy к ошибке в строке line. Возвращает ошибку,
+// если поиск надо прекратить
+func (s *Summary) lineFailed(policy ErrorPolicy, line int, err error) error {
+	lineErr := &LineError{Line: line, Err: err}
+
+	switch policy {
+	case SkipAndCount:
+	case CollectErrors:
+		s.Errors = append(s.Errors, *lineErr)
+	default:
+		return lineErr
+	}
+
+	s.Skipped++
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCorruptedUsers(t *testing.T) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "hw3_policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := []string{
+		`{"browsers":["Android 4.4","MSIE 8.0"],"email":"a@a.com","name":"A"}`,
+		`{"browsers":["Android 4.4","MSIE 8.0"],"email":"b@b.com","name":`,
+		`{"browsers":["Android 2.3","MSIE 9.0"],"email":"c@c.com","name":"C"}`,
+		`not json at all`,
+		`{"browsers":["MSIE 9.0"],"email":"d@d.com","name":"D"}`,
+	}
+
+	path = filepath.Join(dir, "users.txt")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+type searchWithOptions func(out io.Writer, opts SearchOptions) (*Summary, error)
+
+func TestSearchErrorPolicies(t *testing.T) {
+	path, cleanup := writeCorruptedUsers(t)
+	defer cleanup()
+
+	searches := map[string]searchWithOptions{
+		"slow": SlowSearchWithOptions,
+		"fast": FastSearchWithOptions,
+		"mmap": FastSearchMmap,
+		// три куска по 1-2 строки: номера строк считаются во всём файле
+		"parallel": func(out io.Writer, opts SearchOptions) (*Summary, error) {
+			return FastSearchParallel(out, opts, 3)
+		},
+	}
+
+	wantOut := "found users:\n[0] A <a [at] a.com>\n[2] C <c [at] c.com>\n\nTotal unique browsers 4\n"
+
+	for name, search := range searches {
+		t.Run(name+"/fail fast", func(t *testing.T) {
+			out := new(bytes.Buffer)
+			_, err := search(out, SearchOptions{Path: path, Policy: FailFast})
+
+			lineErr, ok := err.(*LineError)
+			if !ok {
+				t.Fatalf("err = %#v, want *LineError", err)
+			}
+			if lineErr.Line != 1 {
+				t.Errorf("Line = %v, want 1", lineErr.Line)
+			}
+			if out.Len() != 0 {
+				t.Errorf("unexpected output: %q", out.String())
+			}
+		})
+
+		t.Run(name+"/skip and count", func(t *testing.T) {
+			out := new(bytes.Buffer)
+			summary, err := search(out, SearchOptions{Path: path, Policy: SkipAndCount})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Lines != 5 || summary.Skipped != 2 || len(summary.Errors) != 0 {
+				t.Errorf("summary = %+v, want 5 lines, 2 skipped, no errors", summary)
+			}
+			if out.String() != wantOut {
+				t.Errorf("out = %q, want %q", out.String(), wantOut)
+			}
+		})
+
+		t.Run(name+"/collect", func(t *testing.T) {
+			out := new(bytes.Buffer)
+			summary, err := search(out, SearchOptions{Path: path, Policy: CollectErrors})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Skipped != 2 || len(summary.Errors) != 2 {
+				t.Fatalf("summary = %+v, want 2 skipped and 2 errors", summary)
+			}
+			if summary.Errors[0].Line != 1 || summary.Errors[1].Line != 3 {
+				t.Errorf("error lines = %v, %v, want 1, 3", summary.Errors[0].Line, summary.Errors[1].Line)
+			}
+			if out.String() != wantOut {
+				t.Errorf("out = %q, want %q", out.String(), wantOut)
+			}
+		})
+
+		t.Run(name+"/missing file", func(t *testing.T) {
+			_, err := search(ioutil.Discard, SearchOptions{Path: path + ".missing"})
+			if !os.IsNotExist(err) {
+				t.Errorf("err = %v, want not exist error", err)
+			}
+		})
+	}
+}
+
+func TestSummaryJSON(t *testing.T) {
+	path, cleanup := writeCorruptedUsers(t)
+	defer cleanup()
+
+	summary, err := FastSearchWithOptions(ioutil.Discard, SearchOptions{Path: path, Policy: CollectErrors})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"lines":5,"skipped":2,"errors":[{"line":1,"error":`) {
+		t.Errorf("unexpected summary json: %s", data)
+	}
+
+	// ошибка FailFast - *LineError, и сериализуется так же
+	_, err = FastSearchWithOptions(ioutil.Discard, SearchOptions{Path: path, Policy: FailFast})
+	data, err = json.Marshal(err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"line":1,"error":`) {
+		t.Errorf("unexpected error json: %s", data)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultSearchBrowsers = []string{"Android", "MSIE"}
+
+// SearchService отвечает на поисковые запросы по файлу пользователей,
+// держа его разобранным в памяти и перечитывая при изменении
+type SearchService struct {
+	path string
+
+	mu       sync.RWMutex
+	snapshot *usersSnapshot
+
+	metrics *serviceMetrics
+}
+
+type usersSnapshot struct {
+	users   []lineUser
+	summary *Summary
+	size    int64
+	modTime time.Time
+}
+
+type lineUser struct {
+	lineIndex int
+	person    Person
+}
+
+func NewSearchService(path string) (*SearchService, error) {
+	service := &SearchService{
+		path:    path,
+		metrics: newServiceMetrics(),
+	}
+
+	if _, err := service.ReloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// ReloadIfChanged перечитывает файл, если изменились его размер или время
+// изменения. При ошибке продолжает работать старый снимок
+func (srv *SearchService) ReloadIfChanged() (bool, error) {
+	info, err := os.Stat(srv.path)
+	if err != nil {
+		srv.metrics.reloaded(err)
+		return false, err
+	}
+
+	srv.mu.RLock()
+	current := srv.snapshot
+	srv.mu.RUnlock()
+
+	if current != nil && current.size == info.Size() && current.modTime.Equal(info.ModTime()) {
+		return false, nil
+	}
+
+	snapshot, err := loadUsers(srv.path)
+	srv.metrics.reloaded(err)
+	if err != nil {
+		return false, err
+	}
+	snapshot.size = info.Size()
+	snapshot.modTime = info.ModTime()
+
+	srv.mu.Lock()
+	srv.snapshot = snapshot
+	srv.mu.Unlock()
+
+	srv.metrics.setUsers(len(snapshot.users), snapshot.summary.Skipped)
+	return true, nil
+}
+
+// Watch проверяет файл раз в interval, пока не закрыт stop
+func (srv *SearchService) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reloaded, err := srv.ReloadIfChanged()
+			if err != nil {
+				log.Printf("reload %s: %s", srv.path, err)
+			} else if reloaded {
+				log.Printf("reloaded %s", srv.path)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func loadUsers(path string) (*usersSnapshot, error) {
+	snapshot := &usersSnapshot{}
+
+	observe := func(lineIndex int, person Person) {
+		snapshot.users = append(snapshot.users, lineUser{lineIndex, person})
+	}
+
+	summary, err := fastSearch(ioutil.Discard, SearchOptions{Path: path, Policy: SkipAndCount}, observe)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.summary = summary
+	return snapshot, nil
+}
+
+// search возвращает пользователей, у которых для каждой подстроки из browsers
+// есть содержащий её браузер, и число различных браузеров, содержащих
+// хотя бы одну из них, - как FastSearch для Android и MSIE
+func (s *usersSnapshot) search(browsers []string) ([]lineUser, int) {
+	seenBrowsers := make(map[string]bool, 114)
+	matched := make([]bool, len(browsers))
+
+	var found []lineUser
+	for _, user := range s.users {
+		for i := range matched {
+			matched[i] = false
+		}
+
+		for _, browser := range user.person.Browsers {
+			for i, substr := range browsers {
+				if strings.Contains(browser, substr) {
+					matched[i] = true
+					seenBrowsers[browser] = true
+				}
+			}
+		}
+
+		all := len(browsers) > 0
+		for _, m := range matched {
+			all = all && m
+		}
+		if all {
+			found = append(found, user)
+		}
+	}
+
+	return found, len(seenBrowsers)
+}
+
+type searchRequest struct {
+	browsers []string
+	offset   int
+	limit    int
+	format   string
+}
+
+func parseSearchRequest(r *http.Request) (searchRequest, error) {
+	query := r.URL.Query()
+
+	req := searchRequest{
+		browsers: query["browser"],
+		format:   query.Get("format"),
+	}
+
+	if len(req.browsers) == 0 {
+		req.browsers = defaultSearchBrowsers
+	}
+	for _, browser := range req.browsers {
+		if browser == "" {
+			return req, fmt.Errorf("browser must not be empty")
+		}
+	}
+
+	var err error
+	if req.offset, err = intParam(query.Get("offset")); err != nil || req.offset < 0 {
+		return req, fmt.Errorf("offset must be int >= 0")
+	}
+	if req.limit, err = intParam(query.Get("limit")); err != nil || req.limit < 0 {
+		return req, fmt.Errorf("limit must be int >= 0")
+	}
+
+	switch req.format {
+	case "":
+		req.format = "text"
+	case "text", "json":
+	default:
+		return req, fmt.Errorf("format must be one of [text, json]")
+	}
+
+	return req, nil
+}
+
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func (srv *SearchService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/search":
+		srv.metrics.observe(srv.handleSearch, w, r)
+	case "/metrics":
+		srv.metrics.writeTo(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (srv *SearchService) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := parseSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	srv.mu.RLock()
+	snapshot := srv.snapshot
+	srv.mu.RUnlock()
+
+	found, uniqueBrowsers := snapshot.search(req.browsers)
+	total := len(found)
+
+	found = page(found, req.offset, req.limit)
+
+	if req.format == "json" {
+		writeSearchJSON(w, found, total, req, uniqueBrowsers)
+		return
+	}
+
+	var foundUsersBuffer bytes.Buffer
+	for _, user := range found {
+		writeFoundUser(&foundUsersBuffer, user.lineIndex, user.person)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	fmt.Fprintln(w, "found users:\n"+foundUsersBuffer.String())
+	fmt.Fprintln(w, "Total unique browsers", uniqueBrowsers)
+}
+
+func page(users []lineUser, offset int, limit int) []lineUser {
+	if offset >= len(users) {
+		return nil
+	}
+	users = users[offset:]
+	if limit > 0 && limit < len(users) {
+		users = users[:limit]
+	}
+	return users
+}
+
+type searchResponse struct {
+	Users          []searchResponseUser `json:"users"`
+	Total          int                  `json:"total"`
+	Offset         int                  `json:"offset"`
+	Limit          int                  `json:"limit"`
+	UniqueBrowsers int                  `json:"unique_browsers"`
+}
+
+type searchResponseUser struct {
+	Line     int      `json:"line"`
+	Name     string   `json:"name"`
+	Email    string   `json:"email"`
+	Browsers []string `json:"browsers"`
+}
+
+func writeSearchJSON(w http.ResponseWriter, found []lineUser, total int, req searchRequest, uniqueBrowsers int) {
+	response := searchResponse{
+		Users:          make([]searchResponseUser, 0, len(found)),
+		Total:          total,
+		Offset:         req.offset,
+		Limit:          req.limit,
+		UniqueBrowsers: uniqueBrowsers,
+	}
+	for _, user := range found {
+		response.Users = append(response.Users, searchResponseUser{
+			Line:     user.lineIndex,
+			Name:     user.person.Name,
+			Email:    user.person.Email,
+			Browsers: user.person.Browsers,
+		})
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSearchServer(t *testing.T, path string) (*SearchService, *httptest.Server) {
+	service, err := NewSearchService(path)
+	if err != nil {
+		t.Fatalf("NewSearchService: %s", err)
+	}
+	ts := httptest.NewServer(service)
+	t.Cleanup(ts.Close)
+	return service, ts
+}
+
+func getBody(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s: %s", url, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestServerDefaultQueryMatchesFastSearch(t *testing.T) {
+	_, ts := newTestSearchServer(t, filePath)
+
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	for _, query := range []string{"", "?browser=Android&browser=MSIE", "?format=text"} {
+		status, body := getBody(t, ts.URL+"/search"+query)
+		if status != http.StatusOK {
+			t.Fatalf("%q: status %d: %s", query, status, body)
+		}
+		if body != fastOut.String() {
+			t.Errorf("%q: results not match\nGot:\n%v\nExpected:\n%v", query, body, fastOut.String())
+		}
+	}
+}
+
+func TestServerJSONPagination(t *testing.T) {
+	_, ts := newTestSearchServer(t, filePath)
+
+	var all searchResponse
+	_, body := getBody(t, ts.URL+"/search?format=json")
+	if err := json.Unmarshal([]byte(body), &all); err != nil {
+		t.Fatalf("bad json %q: %s", body, err)
+	}
+	if all.Total != len(all.Users) || all.Total == 0 {
+		t.Fatalf("expected all %d users, got %d", all.Total, len(all.Users))
+	}
+
+	var paged []searchResponseUser
+	for offset := 0; offset < all.Total; offset += 3 {
+		var page searchResponse
+		_, body := getBody(t, ts.URL+"/search?format=json&limit=3&offset="+strconv.Itoa(offset))
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatalf("bad json %q: %s", body, err)
+		}
+		if page.Total != all.Total || page.UniqueBrowsers != all.UniqueBrowsers {
+			t.Errorf("offset %d: got total %d and %d browsers, expected %d and %d",
+				offset, page.Total, page.UniqueBrowsers, all.Total, all.UniqueBrowsers)
+		}
+		paged = append(paged, page.Users...)
+	}
+
+	if len(paged) != len(all.Users) {
+		t.Fatalf("expected %d paged users, got %d", len(all.Users), len(paged))
+	}
+	for i := range paged {
+		if paged[i].Line != all.Users[i].Line {
+			t.Errorf("user %d: got line %d, expected %d", i, paged[i].Line, all.Users[i].Line)
+		}
+	}
+}
+
+func TestServerBrowserPredicates(t *testing.T) {
+	_, ts := newTestSearchServer(t, filePath)
+
+	var result searchResponse
+	_, body := getBody(t, ts.URL+"/search?format=json&browser=Firefox")
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("bad json %q: %s", body, err)
+	}
+	for _, user := range result.Users {
+		if !strings.Contains(strings.Join(user.Browsers, "\n"), "Firefox") {
+			t.Errorf("line %d has no Firefox: %v", user.Line, user.Browsers)
+		}
+	}
+}
+
+func TestServerBadRequests(t *testing.T) {
+	_, ts := newTestSearchServer(t, filePath)
+
+	cases := map[string]int{
+		"/search?offset=-1":     http.StatusBadRequest,
+		"/search?limit=x":       http.StatusBadRequest,
+		"/search?format=xml":    http.StatusBadRequest,
+		"/search?browser=":      http.StatusBadRequest,
+		"/unknown":              http.StatusNotFound,
+		"/search?offset=100500": http.StatusOK,
+	}
+	for path, expected := range cases {
+		status, body := getBody(t, ts.URL+path)
+		if status != expected {
+			t.Errorf("%s: expected %d, got %d: %s", path, expected, status, body)
+		}
+	}
+}
+
+func TestServerReloadAndMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hw3_server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "users.txt")
+	first := `{"browsers":["Mozilla/5.0 (Linux; Android 4.4.2) MSIE"],"email":"a@example.com","name":"A"}`
+	if err := ioutil.WriteFile(path, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service, ts := newTestSearchServer(t, path)
+
+	if _, body := getBody(t, ts.URL+"/search"); !strings.Contains(body, "[0] A <a [at] example.com>") {
+		t.Fatalf("unexpected body before reload:\n%s", body)
+	}
+
+	second := first + "\nnot json\n" +
+		`{"browsers":["Android", "MSIE"],"email":"b@example.com","name":"B"}`
+	if err := ioutil.WriteFile(path, []byte(second), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	go service.Watch(10*time.Millisecond, stop)
+	defer close(stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, body := getBody(t, ts.URL+"/search")
+		if strings.Contains(body, "[2] B <b [at] example.com>") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("file was not reloaded:\n%s", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, metrics := getBody(t, ts.URL+"/metrics")
+	for _, expected := range []string{
+		"# TYPE search_request_duration_seconds histogram",
+		`search_request_duration_seconds_bucket{code="200",le="+Inf"} `,
+		`search_reloads_total{result="ok"} 2`,
+		"search_users 2",
+		"search_skipped_lines 1",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("no %q in metrics:\n%s", expected, metrics)
+		}
+	}
+
+	// битый файл не должен ломать уже загруженный снимок
+	if err := ioutil.WriteFile(path, []byte{0x1f, 0x8b, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ReloadIfChanged(); err == nil {
+		t.Errorf("expected error on broken gzip file")
+	}
+	if _, body := getBody(t, ts.URL+"/search"); !strings.Contains(body, "[2] B <b [at] example.com>") {
+		t.Errorf("old snapshot was lost:\n%s", body)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+// генератор data/users.txt, запускать из hw3_bench:
+//
+//	go run ./users_gen -users 10000 -seed 1 -o data/users.txt
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/akurin/golang-webservices/hw3_bench/usersgen"
+)
+
+func main() {
+	var config usersgen.Config
+	flag.IntVar(&config.Users, "users", 1000, "number of users")
+	flag.Int64Var(&config.Seed, "seed", 1, "random seed, the same seed gives the same file")
+	flag.IntVar(&config.UserAgents, "user-agents", 0, "number of distinct user agents, 0 for default")
+	flag.IntVar(&config.MaxBrowsers, "max-browsers", 0, "max browsers per user, 0 for default")
+	output := flag.String("o", "-", "output file, - for stdout")
+	flag.Parse()
+
+	if *output != "-" {
+		if err := usersgen.GenerateFile(*output, config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := usersgen.Generate(w, config); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
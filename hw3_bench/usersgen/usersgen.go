@@ -0,0 +1,349 @@
+// Package usersgen генерирует синтетический data/users.txt: по пользователю
+// на строку в том же формате, что и исходный файл курса
+package usersgen
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// Version меняется при любом изменении генератора, влияющем на вывод
+// при тех же Config
+const Version = 1
+
+type Config struct {
+	Users int
+	Seed  int64
+
+	// размер пула различных user agent'ов, из которого выбираются браузеры
+	// пользователей. Популярность внутри пула распределена по Ципфу,
+	// как и в реальных логах. 0 - значение по умолчанию
+	UserAgents int
+	// максимальное число браузеров у пользователя, 0 - значение по умолчанию
+	MaxBrowsers int
+}
+
+const (
+	defaultUserAgents  = 500
+	defaultMaxBrowsers = 8
+)
+
+type person struct {
+	Browsers []string `json:"browsers"`
+	Company  string   `json:"company"`
+	Country  string   `json:"country"`
+	Email    string   `json:"email"`
+	Job      string   `json:"job"`
+	Name     string   `json:"name"`
+	Phone    string   `json:"phone"`
+}
+
+// Generate пишет config.Users строк с пользователями. Для одного и того же
+// config результат одинаков. Как и в исходном файле, после последней строки
+// перевода строки нет
+func Generate(w io.Writer, config Config) error {
+	random := rand.New(rand.NewSource(config.Seed))
+
+	poolSize := config.UserAgents
+	if poolSize <= 0 {
+		poolSize = defaultUserAgents
+	}
+	maxBrowsers := config.MaxBrowsers
+	if maxBrowsers <= 0 {
+		maxBrowsers = defaultMaxBrowsers
+	}
+
+	pool := userAgentPool(random, poolSize)
+	popularity := rand.NewZipf(random, 1.2, 8, uint64(len(pool)-1))
+
+	for i := 0; i < config.Users; i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+
+		line, err := json.Marshal(newPerson(random, pool, popularity, maxBrowsers))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateFile пишет Generate в path и рядом метку path+".usersgen", по
+// которой IsGenerated отличает сгенерированный файл от настоящей выгрузки
+func GenerateFile(path string, config Config) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	if err := Generate(w, config); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	mark, err := json.Marshal(marker{Config: config, Version: Version, Size: info.Size()})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+markerSuffix, mark, 0644)
+}
+
+const markerSuffix = ".usersgen"
+
+type marker struct {
+	Config  Config
+	Version int
+	Size    int64
+}
+
+// IsGenerated сообщает, записан ли path функцией GenerateFile и не заменён ли
+// с тех пор другим файлом: размер совпадает с меткой и файл не новее её
+func IsGenerated(path string) (bool, error) {
+	markerPath := path + markerSuffix
+	markerInfo, err := os.Stat(markerPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	data, err := ioutil.ReadFile(markerPath)
+	if err != nil {
+		return false, err
+	}
+
+	var mark marker
+	if err := json.Unmarshal(data, &mark); err != nil {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == mark.Size && !info.ModTime().After(markerInfo.ModTime()), nil
+}
+
+func newPerson(random *rand.Rand, pool []string, popularity *rand.Zipf, maxBrowsers int) person {
+	// чаще всего у пользователя 1-3 браузера, но бывают и коллекционеры
+	count := 1
+	for count < maxBrowsers && random.Float64() < 0.6 {
+		count++
+	}
+
+	browsers := make([]string, count)
+	for i := range browsers {
+		browsers[i] = pool[popularity.Uint64()]
+	}
+
+	firstName := pick(random, firstNames)
+	lastName := pick(random, lastNames)
+	company := pick(random, companies)
+
+	var domain string
+	if random.Float64() < 0.6 {
+		domain = pick(random, emailDomains)
+	} else {
+		domain = strings.ToLower(company) + "." + pick(random, topLevelDomains)
+	}
+
+	return person{
+		Browsers: browsers,
+		Company:  company,
+		Country:  pick(random, countries),
+		Email:    firstName + lastName + "@" + domain,
+		Job:      pick(random, jobs),
+		Name:     firstName + " " + lastName,
+		Phone:    fmt.Sprintf("%d-%02d-%02d", 100+random.Intn(900), random.Intn(100), random.Intn(100)),
+	}
+}
+
+// userAgentPool собирает size различных user agent'ов с учётом долей семейств.
+// Пул перемешивается, чтобы самыми популярными по Ципфу становились
+// случайные браузеры, а не первые сгенерированные
+func userAgentPool(random *rand.Rand, size int) []string {
+	totalWeight := 0
+	for _, family := range families {
+		totalWeight += family.weight
+	}
+
+	seen := make(map[string]bool, size)
+	pool := make([]string, 0, size)
+	for attempts := 0; len(pool) < size && attempts < size*100; attempts++ {
+		ua := pickFamily(random, totalWeight).userAgent(random)
+		if seen[ua] {
+			continue
+		}
+		seen[ua] = true
+		pool = append(pool, ua)
+	}
+
+	random.Shuffle(len(pool), func(i, j int) {
+		pool[i], pool[j] = pool[j], pool[i]
+	})
+	return pool
+}
+
+func pickFamily(random *rand.Rand, totalWeight int) family {
+	n := random.Intn(totalWeight)
+	for _, family := range families {
+		if n < family.weight {
+			return family
+		}
+		n -= family.weight
+	}
+	return families[len(families)-1]
+}
+
+func pick(random *rand.Rand, items []string) string {
+	return items[random.Intn(len(items))]
+}
+
+type family struct {
+	name   string
+	weight int
+	// шаблоны, %[1]d - мажорная версия браузера, %[2]d - минорная, %[3]s - платформа
+	templates []string
+	platforms []string
+	minMajor  int
+	maxMajor  int
+}
+
+func (f family) userAgent(random *rand.Rand) string {
+	template := pick(random, f.templates)
+	major := f.minMajor + random.Intn(f.maxMajor-f.minMajor+1)
+	minor := random.Intn(5000)
+	return fmt.Sprintf(template, major, minor, pick(random, f.platforms))
+}
+
+// доли семейств примерно соответствуют статистике браузеров середины 2010-х
+var families = []family{
+	{
+		name:   "Chrome",
+		weight: 38,
+		templates: []string{
+			"Mozilla/5.0 (%[3]s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%[1]d.0.%[2]d.0 Safari/537.36",
+		},
+		platforms: []string{"Windows NT 6.1; WOW64", "Windows NT 10.0; Win64; x64", "Macintosh; Intel Mac OS X 10_11_6", "X11; Linux x86_64"},
+		minMajor:  30,
+		maxMajor:  65,
+	},
+	{
+		name:   "Android",
+		weight: 18,
+		templates: []string{
+			"Mozilla/5.0 (Linux; U; Android %[1]d.%[2]d; %[3]s) AppleWebKit/534.30 (KHTML, like Gecko) Version/4.0 Mobile Safari/534.30",
+			"Mozilla/5.0 (Linux; Android %[1]d.0.%[2]d; %[3]s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.83 Mobile Safari/537.36",
+		},
+		platforms: []string{"en-us; GT-I9300 Build/IMM76D", "SM-G930F Build/NRD90M", "Nexus 5 Build/MRA58N", "HTC One Build/KOT49H"},
+		minMajor:  2,
+		maxMajor:  8,
+	},
+	{
+		name:   "MSIE",
+		weight: 14,
+		templates: []string{
+			"Mozilla/4.0 (compatible; MSIE %[1]d.0; %[3]s; Trident/%[2]d.0)",
+			"Mozilla/5.0 (compatible; MSIE %[1]d.0; %[3]s; Trident/6.0; .NET CLR %[2]d.0)",
+		},
+		platforms: []string{"Windows NT 5.1", "Windows NT 6.0", "Windows NT 6.1", "Windows NT 6.2; WOW64"},
+		minMajor:  6,
+		maxMajor:  10,
+	},
+	{
+		name:   "Safari",
+		weight: 12,
+		templates: []string{
+			"Mozilla/5.0 (%[3]s) AppleWebKit/603.%[2]d (KHTML, like Gecko) Version/%[1]d.0 Safari/603.3.8",
+		},
+		platforms: []string{"Macintosh; Intel Mac OS X 10_12_6", "iPhone; CPU iPhone OS 10_3 like Mac OS X", "iPad; CPU OS 9_3_5 like Mac OS X"},
+		minMajor:  5,
+		maxMajor:  11,
+	},
+	{
+		name:   "Firefox",
+		weight: 11,
+		templates: []string{
+			"Mozilla/5.0 (%[3]s; rv:%[1]d.0) Gecko/20100101 Firefox/%[1]d.%[2]d",
+		},
+		platforms: []string{"Windows NT 6.1; WOW64", "Windows NT 10.0; Win64; x64", "X11; Ubuntu; Linux x86_64", "Macintosh; Intel Mac OS X 10.12"},
+		minMajor:  20,
+		maxMajor:  57,
+	},
+	{
+		name:   "Opera",
+		weight: 4,
+		templates: []string{
+			"Opera/9.80 (%[3]s) Presto/2.12.%[2]d Version/%[1]d.00",
+			"Mozilla/5.0 (%[3]s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/49.0.2623.112 Safari/537.36 OPR/%[1]d.0.%[2]d",
+		},
+		platforms: []string{"Windows NT 6.1", "X11; Linux x86_64", "Macintosh; Intel Mac OS X 10.9"},
+		minMajor:  12,
+		maxMajor:  45,
+	},
+	{
+		name:   "Edge",
+		weight: 3,
+		templates: []string{
+			"Mozilla/5.0 (%[3]s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Safari/537.36 Edge/%[1]d.%[2]d",
+		},
+		platforms: []string{"Windows NT 10.0; Win64; x64", "Windows NT 10.0"},
+		minMajor:  12,
+		maxMajor:  16,
+	},
+}
+
+var (
+	firstNames = []string{
+		"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda",
+		"William", "Elizabeth", "David", "Barbara", "Richard", "Susan", "Joseph", "Jessica",
+		"Thomas", "Sarah", "Charles", "Karen", "Christopher", "Nancy", "Daniel", "Lisa",
+		"Matthew", "Betty", "Anthony", "Margaret", "Mark", "Sandra", "Donald", "Ashley",
+	}
+	lastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis",
+		"Rodriguez", "Martinez", "Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas",
+		"Taylor", "Moore", "Jackson", "Martin", "Lee", "Perez", "Thompson", "White",
+		"Harris", "Sanchez", "Clark", "Ramirez", "Lewis", "Robinson", "Walker", "Young",
+	}
+	companies = []string{
+		"Flashpoint", "Muxo", "Yodel", "Skinix", "Quimba", "Jabbersphere", "Realcube", "Zoomzone",
+		"Topicshots", "Voonyx", "Tagfeed", "Browsebug", "Livetube", "Oyoloo", "Trilith", "Kwilith",
+	}
+	emailDomains    = []string{"gmail.com", "yahoo.com", "hotmail.com", "mail.ru", "outlook.com", "yandex.ru"}
+	topLevelDomains = []string{"com", "net", "org", "info", "biz", "edu"}
+	countries       = []string{
+		"United States", "Russia", "Germany", "Brazil", "India", "China", "France", "Japan",
+		"United Kingdom", "Canada", "Mexico", "Indonesia", "Dominican Republic", "Poland", "Turkey", "Spain",
+	}
+	jobs = []string{
+		"Programmer Analyst", "Software Engineer", "Web Developer", "Accountant", "Marketing Manager",
+		"Sales Associate", "Nurse", "Teacher", "Data Coordinator", "Financial Analyst", "Help Desk Operator",
+	}
+)
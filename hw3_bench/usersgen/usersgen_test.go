@@ -0,0 +1,124 @@
+package usersgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	first := new(bytes.Buffer)
+	if err := Generate(first, Config{Users: 100, Seed: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	second := new(bytes.Buffer)
+	if err := Generate(second, Config{Users: 100, Seed: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.String() != second.String() {
+		t.Errorf("same seed produced different output")
+	}
+
+	other := new(bytes.Buffer)
+	if err := Generate(other, Config{Users: 100, Seed: 43}); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.String() == other.String() {
+		t.Errorf("different seeds produced the same output")
+	}
+}
+
+func TestGenerateProducesUsersFileFormat(t *testing.T) {
+	out := new(bytes.Buffer)
+	config := Config{Users: 2000, Seed: 1, MaxBrowsers: 5}
+	if err := Generate(out, config); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.HasSuffix(out.String(), "\n") {
+		t.Errorf("output must not end with a newline")
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != config.Users {
+		t.Fatalf("lines = %v, want %v", len(lines), config.Users)
+	}
+
+	families := make(map[string]int)
+	androidAndMSIE := 0
+	for i, line := range lines {
+		var p person
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			t.Fatalf("line %d: %s", i, err)
+		}
+
+		if p.Name == "" || !strings.Contains(p.Email, "@") {
+			t.Errorf("line %d: bad name or email: %q %q", i, p.Name, p.Email)
+		}
+		if len(p.Browsers) == 0 || len(p.Browsers) > config.MaxBrowsers {
+			t.Errorf("line %d: %d browsers", i, len(p.Browsers))
+		}
+
+		isAndroid, isMSIE := false, false
+		for _, browser := range p.Browsers {
+			for _, family := range []string{"Android", "MSIE", "Chrome/", "Firefox/", "Safari/", "Opera", "Edge/"} {
+				if strings.Contains(browser, family) {
+					families[family]++
+				}
+			}
+			isAndroid = isAndroid || strings.Contains(browser, "Android")
+			isMSIE = isMSIE || strings.Contains(browser, "MSIE")
+		}
+		if isAndroid && isMSIE {
+			androidAndMSIE++
+		}
+	}
+
+	for _, family := range []string{"Android", "MSIE", "Chrome/", "Firefox/", "Safari/", "Opera", "Edge/"} {
+		if families[family] == 0 {
+			t.Errorf("no %s browsers generated", family)
+		}
+	}
+	if androidAndMSIE == 0 {
+		t.Errorf("no users with both Android and MSIE browsers")
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usersgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "users.txt")
+	if err := GenerateFile(path, Config{Users: 10, Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if generated, err := IsGenerated(path); err != nil || !generated {
+		t.Errorf("IsGenerated = %v, %v, want true", generated, err)
+	}
+
+	// настоящая выгрузка на месте сгенерированного файла
+	if err := ioutil.WriteFile(path, []byte(`{"browsers":[],"email":"a@a.com","name":"A"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if generated, err := IsGenerated(path); err != nil || generated {
+		t.Errorf("IsGenerated after replace = %v, %v, want false", generated, err)
+	}
+
+	other := filepath.Join(dir, "dump.txt")
+	if err := ioutil.WriteFile(other, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if generated, err := IsGenerated(other); err != nil || generated {
+		t.Errorf("IsGenerated without marker = %v, %v, want false", generated, err)
+	}
+}
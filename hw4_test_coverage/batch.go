@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// пакетный поиск - POST на URL с этим окончанием
+	batchSuffix = "/batch"
+	// сколько запросов сервер принимает в одном пакете
+	maxBatch = 50
+	// сколько отдельных запросов идут одновременно, если пакеты не работают
+	fallbackConcurrency = 8
+)
+
+// errBatchUnsupported - сервер не знает пакетного поиска
+var errBatchUnsupported = errors.New("batch search is not supported")
+
+// BatchResult - ответ или ошибка одного запроса FindUsersBatch
+type BatchResult struct {
+	Response *SearchResponse
+	Err      error
+}
+
+type batchRequest struct {
+	Requests []string `json:"requests"`
+}
+
+type batchResponse struct {
+	Results []struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"results"`
+}
+
+// FindUsersBatch выполняет запросы за одно обращение к серверу и возвращает
+// результаты в том же порядке
+func (srv *SearchClient) FindUsersBatch(reqs []SearchRequest) []BatchResult {
+	return srv.FindUsersBatchContext(context.Background(), reqs)
+}
+
+// FindUsersBatchContext - FindUsersBatch, который прерывается вместе с ctx.
+// Если пакет не удался, например сервер не знает пакетного поиска, запросы
+// выполняются по отдельности, параллельно, с повторами как у FindUsersContext
+func (srv *SearchClient) FindUsersBatchContext(ctx context.Context, reqs []SearchRequest) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+	calls := make([]*searchCall, len(reqs))
+
+	// ответы в кеше лежат под отпечатком токена
+	var accessToken string
+	if srv.Cache != nil {
+		token, err := srv.accessToken(ctx)
+		if err != nil {
+			for i := range results {
+				results[i].Err = err
+			}
+			return results
+		}
+		accessToken = token
+	}
+
+	var pending []int
+	for i, req := range reqs {
+		call, err := prepareCall(req)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		calls[i] = call
+
+		if srv.Cache != nil {
+			if entry, fresh := srv.Cache.get(srv.cacheKey(accessToken, call.params)); fresh {
+				response := copyResponse(&entry.response)
+				results[i].Response = &response
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+
+	var failed []int
+	for len(pending) > 0 {
+		chunk := pending
+		if len(chunk) > maxBatch {
+			chunk = chunk[:maxBatch]
+		}
+		pending = pending[len(chunk):]
+
+		if atomic.LoadInt32(&srv.batchUnsupported) == 0 {
+			err := srv.doBatch(ctx, chunk, calls, results)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				// до findEach не дойдут ни этот пакет, ни следующие, ни упавшие раньше
+				for _, indexes := range [][]int{failed, chunk, pending} {
+					for _, i := range indexes {
+						results[i].Err = ctx.Err()
+					}
+				}
+				return results
+			}
+			if err == errBatchUnsupported {
+				atomic.StoreInt32(&srv.batchUnsupported, 1)
+			}
+		}
+		failed = append(failed, chunk...)
+	}
+
+	srv.findEach(ctx, failed, reqs, results)
+	return results
+}
+
+func (srv *SearchClient) doBatch(ctx context.Context, chunk []int, calls []*searchCall, results []BatchResult) error {
+	batch := batchRequest{Requests: make([]string, len(chunk))}
+	for k, i := range chunk {
+		batch.Requests[k] = calls[i].params.Encode()
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := srv.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest("POST", strings.TrimSuffix(srv.URL, "/")+batchSuffix, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("AccessToken", accessToken)
+
+	httpClient := srv.HTTPClient
+	if httpClient == nil {
+		httpClient = client
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return errBatchUnsupported
+	default:
+		return fmt.Errorf("batch: unexpected status %s", resp.Status)
+	}
+
+	var batchResp batchResponse
+	if err := json.Unmarshal(respBody, &batchResp); err != nil {
+		return fmt.Errorf("batch: cant unpack result json: %s", err)
+	}
+	if len(batchResp.Results) != len(chunk) {
+		return fmt.Errorf("batch: got %d results for %d requests", len(batchResp.Results), len(chunk))
+	}
+
+	for k, i := range chunk {
+		result := batchResp.Results[k]
+		status := fmt.Sprintf("%d %s", result.Status, http.StatusText(result.Status))
+		if err := responseError(result.Status, status, result.Body, calls[i].req); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if result.Status != http.StatusOK {
+			results[i].Err = fmt.Errorf("unexpected status %s", status)
+			continue
+		}
+
+		response, err := calls[i].decode(result.Body)
+		if err == nil && srv.Cache != nil {
+			srv.Cache.put(srv.cacheKey(accessToken, calls[i].params), response, "")
+		}
+		results[i] = BatchResult{Response: response, Err: err}
+	}
+	return nil
+}
+
+// findEach выполняет запросы с номерами indexes по отдельности
+func (srv *SearchClient) findEach(ctx context.Context, indexes []int, reqs []SearchRequest, results []BatchResult) {
+	slots := make(chan struct{}, fallbackConcurrency)
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			response, err := srv.FindUsersContext(ctx, reqs[i])
+			results[i] = BatchResult{Response: response, Err: err}
+		}(i)
+	}
+	wg.Wait()
+}
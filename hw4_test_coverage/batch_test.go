@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBatchServer - dataset-сервер, который считает пакетные запросы.
+// Если batch = false, он их не знает, как сервер до появления пакетов
+func countingBatchServer(t *testing.T, batch bool) (*httptest.Server, *int32, *int32) {
+	dataset := newDatasetHandler(t)
+	var batches, singles int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/batch") {
+			atomic.AddInt32(&batches, 1)
+			if !batch {
+				http.NotFound(w, r)
+				return
+			}
+		} else {
+			atomic.AddInt32(&singles, 1)
+		}
+		dataset.ServeHTTP(w, r)
+	}))
+	return server, &batches, &singles
+}
+
+var batchTestRequests = []SearchRequest{
+	{Limit: 3, OrderField: "Age", OrderBy: OrderByAsc},
+	{Limit: 5, Offset: 2, Query: "Boyd"},
+	{Limit: 1, OrderField: "Salary"},
+	{Limit: -1},
+	{Limit: 2, UseCursor: true, OrderField: "Id", OrderBy: OrderByDesc},
+	{Limit: 2, Query: "cillum", OrderField: OrderFieldRelevance, Fields: []string{"Id", "Name"}},
+	{Limit: 25, Filter: "age>"},
+}
+
+func checkBatchResults(t *testing.T, client *SearchClient, reqs []SearchRequest, results []BatchResult) {
+	t.Helper()
+
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d results for %d requests", len(results), len(reqs))
+	}
+	direct := &SearchClient{AccessToken: client.AccessToken, URL: client.URL}
+	for i, req := range reqs {
+		want, wantErr := direct.FindUsers(req)
+		got := results[i]
+		if (got.Err == nil) != (wantErr == nil) || got.Err != nil && got.Err.Error() != wantErr.Error() {
+			t.Errorf("%+v: error %v, want %v", req, got.Err, wantErr)
+		}
+		if !reflect.DeepEqual(got.Response, want) {
+			t.Errorf("%+v: got %+v, want %+v", req, got.Response, want)
+		}
+	}
+}
+
+func Test_Client_FindUsersBatch(t *testing.T) {
+	server, batches, singles := countingBatchServer(t, true)
+	defer server.Close()
+
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL + "/"}
+	results := client.FindUsersBatch(batchTestRequests)
+	if atomic.LoadInt32(batches) != 1 || atomic.LoadInt32(singles) != 0 {
+		t.Errorf("batches = %d, singles = %d", *batches, *singles)
+	}
+	checkBatchResults(t, client, batchTestRequests, results)
+
+	if !errors.Is(results[2].Err, ErrBadOrderField) || !errors.Is(results[6].Err, ErrBadFilter) {
+		t.Errorf("unexpected errors %v, %v", results[2].Err, results[6].Err)
+	}
+
+	// запросы сверх размера пакета уходят следующим пакетом
+	many := make([]SearchRequest, maxBatch+1)
+	for i := range many {
+		many[i] = SearchRequest{Limit: 1, Offset: i % 30, OrderField: "Id", OrderBy: OrderByAsc}
+	}
+	atomic.StoreInt32(batches, 0)
+	results = client.FindUsersBatch(many)
+	if atomic.LoadInt32(batches) != 2 {
+		t.Errorf("batches = %d, want 2", *batches)
+	}
+	for i, result := range results {
+		if result.Err != nil || result.Response.Users[0].Id != i%30 {
+			t.Errorf("%d: got %+v", i, result)
+		}
+	}
+
+	client.AccessToken = "wrong"
+	for _, result := range client.FindUsersBatch(batchTestRequests[:2]) {
+		if !errors.Is(result.Err, ErrBadToken) {
+			t.Errorf("Expected ErrBadToken, got: %v", result.Err)
+		}
+	}
+}
+
+func Test_Client_FindUsersBatch_Falls_Back(t *testing.T) {
+	server, batches, singles := countingBatchServer(t, false)
+	defer server.Close()
+
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL}
+	results := client.FindUsersBatch(batchTestRequests)
+	// о том, что пакетов нет, клиент узнаёт один раз
+	client.FindUsersBatch(batchTestRequests)
+	if got := atomic.LoadInt32(batches); got != 1 {
+		t.Errorf("batches = %d, want 1", got)
+	}
+	// по два раза каждый из 6 запросов, прошедших проверку клиента
+	if got := atomic.LoadInt32(singles); got != 12 {
+		t.Errorf("singles = %d, want 12", got)
+	}
+	checkBatchResults(t, client, batchTestRequests, results)
+}
+
+func Test_Client_FindUsersBatch_Cache_And_Cancel(t *testing.T) {
+	server, batches, _ := countingBatchServer(t, true)
+	defer server.Close()
+
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL, Cache: NewResponseCache(10, time.Minute)}
+	first := client.FindUsersBatch(batchTestRequests[:2])
+	second := client.FindUsersBatch(batchTestRequests[:2])
+	if atomic.LoadInt32(batches) != 1 || !reflect.DeepEqual(first, second) {
+		t.Errorf("batches = %d, second = %+v", *batches, second)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, result := range client.FindUsersBatchContext(ctx, batchTestRequests[2:3]) {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got: %v", result.Err)
+		}
+	}
+}
+
+func Test_Client_FindUsersBatch_Cancel_After_Failed_Batch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var batches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&batches, 1) == 1 {
+			// первый пакет падает, его запросы ждут отдельного выполнения
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// пока уходит второй пакет, вызывающий передумал. Тело дочитывается,
+		// иначе сервер не заметит закрытого соединения
+		ioutil.ReadAll(r.Body)
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL}
+	reqs := make([]SearchRequest, maxBatch+10)
+	for i := range reqs {
+		reqs[i] = SearchRequest{Limit: 1, Offset: i}
+	}
+
+	results := client.FindUsersBatchContext(ctx, reqs)
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d: expected context.Canceled, got: %+v", i, result)
+		}
+	}
+	if got := atomic.LoadInt32(&batches); got != 2 {
+		t.Errorf("batches = %d, want 2", got)
+	}
+}
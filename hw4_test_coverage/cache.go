@@ -0,0 +1,123 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ResponseCache хранит ответы FindUsers по параметрам запроса и отпечатку
+// токена, поэтому его можно делить между клиентами с разными токенами. Пока
+// ответ моложе ttl, он отдаётся без запроса к серверу, потом перепроверяется
+// через If-None-Match. Если записей больше maxEntries, вытесняются давно не
+// использованные
+type ResponseCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type cacheEntry struct {
+	key      string
+	response SearchResponse
+	etag     string
+	storedAt time.Time
+}
+
+func NewResponseCache(maxEntries int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Len - число записей в кеше
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// get возвращает запись и признак того, что её можно отдать без перепроверки
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+
+	if c.now().Sub(entry.storedAt) < c.ttl {
+		c.lru.MoveToFront(elem)
+		return entry, true
+	}
+
+	// без ETag устаревшую запись не перепроверить
+	if entry.etag == "" {
+		c.removeElement(elem)
+		return nil, false
+	}
+	return entry, false
+}
+
+func (c *ResponseCache) put(key string, response *SearchResponse, etag string) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{
+		key:      key,
+		response: copyResponse(response),
+		etag:     etag,
+		storedAt: c.now(),
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// revalidated продлевает запись после ответа 304
+func (c *ResponseCache) revalidated(key string, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok && elem.Value == entry {
+		entry.storedAt = c.now()
+		c.lru.MoveToFront(elem)
+	}
+}
+
+func (c *ResponseCache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// copyResponse не даёт вызывающему коду испортить закешированный ответ
+func copyResponse(response *SearchResponse) SearchResponse {
+	result := *response
+	// [:0:0] сохраняет разницу между nil и пустым списком
+	result.Users = append(response.Users[:0:0], response.Users...)
+	result.Relevance = append(response.Relevance[:0:0], response.Relevance...)
+	return result
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/searcher"
+)
+
+type countingServer struct {
+	*httptest.Server
+	dataset     *searcher.Server
+	requests    int32
+	notModified int32
+}
+
+func newCountingServer(t *testing.T, withETag bool) *countingServer {
+	users, err := searcher.LoadDataset("dataset.xml")
+	if err != nil {
+		t.Fatalf("cant load dataset: %s", err)
+	}
+
+	server := &countingServer{dataset: searcher.NewServer(users, []string{testAccessToken})}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&server.requests, 1)
+		if !withETag {
+			r.Header.Del("If-None-Match")
+			w = headerFilter{w}
+		}
+
+		recorder := httptest.NewRecorder()
+		server.dataset.ServeHTTP(recorder, r)
+		if recorder.Code == http.StatusNotModified {
+			atomic.AddInt32(&server.notModified, 1)
+		}
+
+		for key, values := range recorder.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	}))
+	return server
+}
+
+// headerFilter прячет ETag, как сервер, который его не поддерживает
+type headerFilter struct {
+	http.ResponseWriter
+}
+
+func (w headerFilter) WriteHeader(status int) {
+	w.Header().Del("ETag")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (s *countingServer) counts() (int32, int32) {
+	return atomic.LoadInt32(&s.requests), atomic.LoadInt32(&s.notModified)
+}
+
+func fakeClock(cache *ResponseCache) *time.Time {
+	now := time.Date(2018, 4, 2, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	return &now
+}
+
+func Test_Cache_Serves_Fresh_Responses(t *testing.T) {
+	server := newCountingServer(t, true)
+	defer server.Close()
+
+	cache := NewResponseCache(10, time.Minute)
+	now := fakeClock(cache)
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL, Cache: cache}
+
+	req := SearchRequest{Limit: 5, OrderField: "Age", OrderBy: OrderByAsc}
+	first, err := client.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := copyResponse(first)
+	first.Users[0].Name = "changed by caller"
+
+	second, err := client.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(*second, want) {
+		t.Errorf("cached response changed: %+v", second)
+	}
+	if requests, _ := server.counts(); requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+
+	// после ttl ответ перепроверяется и, раз данные не менялись, переиспользуется
+	*now = now.Add(time.Minute)
+	third, err := client.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(*third, want) {
+		t.Errorf("revalidated response differs: %+v", third)
+	}
+	if requests, notModified := server.counts(); requests != 2 || notModified != 1 {
+		t.Errorf("requests = %d, 304 = %d, want 2 and 1", requests, notModified)
+	}
+
+	// и снова свежий
+	if _, err := client.FindUsers(req); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if requests, _ := server.counts(); requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+func Test_Cache_Revalidates_After_Dataset_Change(t *testing.T) {
+	server := newCountingServer(t, true)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+		// нулевой ttl: каждый запрос перепроверяется
+		Cache: NewResponseCache(10, 0),
+	}
+
+	req := SearchRequest{Limit: 1, OrderField: "Age", OrderBy: OrderByAsc}
+	for i := 0; i < 3; i++ {
+		if _, err := client.FindUsers(req); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if requests, notModified := server.counts(); requests != 3 || notModified != 2 {
+		t.Errorf("requests = %d, 304 = %d, want 3 and 2", requests, notModified)
+	}
+
+	if err := server.dataset.Insert(searcher.User{Id: 100, Name: "Youngest", Age: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(got.Users) != 1 || got.Users[0].Id != 100 {
+		t.Errorf("expected new user, got %+v", got.Users)
+	}
+	if _, notModified := server.counts(); notModified != 2 {
+		t.Errorf("304 = %d, want 2", notModified)
+	}
+}
+
+func Test_Cache_Is_Bounded(t *testing.T) {
+	server := newCountingServer(t, true)
+	defer server.Close()
+
+	cache := NewResponseCache(2, time.Hour)
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL, Cache: cache}
+
+	for _, offset := range []int{0, 1, 2, 0} {
+		if _, err := client.FindUsers(SearchRequest{Limit: 1, Offset: offset}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("Len = %d, want 2", cache.Len())
+	}
+	// запрос с offset 0 был вытеснен
+	if requests, _ := server.counts(); requests != 4 {
+		t.Errorf("requests = %d, want 4", requests)
+	}
+
+	// а с offset 2 - остался
+	if _, err := client.FindUsers(SearchRequest{Limit: 1, Offset: 2}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if requests, _ := server.counts(); requests != 4 {
+		t.Errorf("requests = %d, want 4", requests)
+	}
+}
+
+func Test_Cache_Without_ETag(t *testing.T) {
+	server := newCountingServer(t, false)
+	defer server.Close()
+
+	cache := NewResponseCache(10, time.Minute)
+	now := fakeClock(cache)
+	client := &SearchClient{AccessToken: testAccessToken, URL: server.URL, Cache: cache}
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.FindUsers(SearchRequest{Limit: 3}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		*now = now.Add(40 * time.Second)
+	}
+
+	if requests, notModified := server.counts(); requests != 2 || notModified != 0 {
+		t.Errorf("requests = %d, 304 = %d, want 2 and 0", requests, notModified)
+	}
+}
+
+func Test_Cache_Does_Not_Store_Errors(t *testing.T) {
+	server := newCountingServer(t, true)
+	defer server.Close()
+
+	cache := NewResponseCache(10, time.Hour)
+	client := &SearchClient{AccessToken: "bad", URL: server.URL, Cache: cache}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.FindUsers(SearchRequest{}); err == nil {
+			t.Fatalf("Expected error")
+		}
+	}
+	if requests, _ := server.counts(); requests != 2 || cache.Len() != 0 {
+		t.Errorf("requests = %d, cached = %d, want 2 and 0", requests, cache.Len())
+	}
+}
+
+func Test_Cache_Is_Keyed_By_Token(t *testing.T) {
+	server := newCountingServer(t, true)
+	defer server.Close()
+
+	cache := NewResponseCache(10, time.Hour)
+	authorized := &SearchClient{AccessToken: testAccessToken, URL: server.URL, Cache: cache}
+	anonymous := &SearchClient{AccessToken: "bad", URL: server.URL, Cache: cache}
+
+	req := SearchRequest{Limit: 5}
+	if _, err := authorized.FindUsers(req); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// ответ, закешированный для одного токена, не достаётся другому
+	if _, err := anonymous.FindUsers(req); err != ErrBadToken {
+		t.Errorf("FindUsers error = %v, want %v", err, ErrBadToken)
+	}
+	results := anonymous.FindUsersBatch([]SearchRequest{req})
+	if results[0].Err != ErrBadToken {
+		t.Errorf("FindUsersBatch error = %v, want %v", results[0].Err, ErrBadToken)
+	}
+
+	// а свой ответ по-прежнему отдаётся из кеша
+	before, _ := server.counts()
+	if _, err := authorized.FindUsers(req); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if requests, _ := server.counts(); requests != before {
+		t.Errorf("requests = %d, want %d", requests, before)
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/model"
 )
 
 const (
@@ -22,21 +29,33 @@ var (
 	client  = &http.Client{Timeout: time.Second}
 )
 
-type User struct {
-	Id     int
-	Name   string
-	Age    int
-	About  string
-	Gender string
-}
+type User = model.User
 
 type SearchResponse struct {
 	Users    []User
 	NextPage bool
+	// курсор следующей страницы, если запрос был в режиме курсоров
+	NextCursor string
+	// для OrderField = OrderFieldRelevance: оценка и сниппет Users[i]
+	Relevance []Relevance
+}
+
+// Relevance - оценка пользователя по BM25 и фрагмент Name или About в виде
+// HTML: текст экранирован, слова Query обёрнуты в <em></em>
+type Relevance struct {
+	Score   float64
+	Snippet string
+}
+
+// scoredUser - пользователь в ответе с OrderFieldRelevance
+type scoredUser struct {
+	User
+	Relevance
 }
 
 type SearchErrorResponse struct {
-	Error string
+	Error   string
+	Message string `json:",omitempty"`
 }
 
 const (
@@ -45,27 +64,219 @@ const (
 	OrderByDesc = 1
 
 	ErrorBadOrderField = `OrderField invalid`
+
+	// OrderFieldRelevance - полнотекстовый поиск: Query - слова, пользователи
+	// с лучшими совпадениями идут первыми, OrderBy не учитывается
+	OrderFieldRelevance = "relevance"
 )
 
 type SearchRequest struct {
 	Limit      int
 	Offset     int    // Можно учесть после сортировки
-	Query      string // подстрока в 1 из полей
+	Query      string // подстрока в 1 из полей, для OrderFieldRelevance - слова
 	OrderField string
 	// -1 по убыванию, 0 как встретилось, 1 по возрастанию
 	OrderBy int
+	// фильтр по полям, например: age>30 and (gender=female or name~Wolf).
+	// Поля: id, age, name, about, gender, eyecolor, company, email, favoritefruit;
+	// операции = != < <= > >= ~ !~
+	Filter string
+	// сортировка по нескольким полям, если задана, OrderField и OrderBy не учитываются
+	Sort []SortField
+	// режим курсоров: страницы не съезжают, если между запросами добавились
+	// пользователи. Первая страница запрашивается с UseCursor, следующие - с
+	// Cursor из SearchResponse.NextCursor
+	UseCursor bool
+	Cursor    string
+	// поля User, которые нужны в ответе, пусто - все. Остальные поля будут нулевыми
+	Fields []string
+}
+
+// cursorPage - ответ сервера в режиме курсоров
+type cursorPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor"`
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+func encodeSort(fields []SortField) string {
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Desc {
+			keys = append(keys, "-"+field.Field)
+		} else {
+			keys = append(keys, field.Field)
+		}
+	}
+	return strings.Join(keys, ",")
 }
 
 type SearchClient struct {
 	// токен, по которому происходит авторизация на внешней системе, уходит туда через хедер
 	AccessToken string
+	// если задан, токен берётся из него, а не из AccessToken
+	TokenSource TokenSource
 	// урл внешней системы, куда идти
 	URL string
+	// если не задан, используется клиент с таймаутом в секунду
+	HTTPClient *http.Client
+	// сколько раз повторить запрос после 5xx или таймаута
+	Retries int
+	// пауза перед первым повтором, дальше удваивается, но не больше maxBackoff
+	Backoff time.Duration
+	// если задан, одинаковые запросы отдаются из кеша
+	Cache *ResponseCache
+
+	// сервер ответил, что не знает пакетного поиска
+	batchUnsupported int32
+}
+
+const (
+	defaultBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+var (
+	ErrBadToken       = errors.New("Bad AccessToken")
+	ErrBadOrderField  = errors.New(ErrorBadOrderField)
+	ErrServerInternal = errors.New("SearchServer fatal error")
+	ErrBadFilter      = errors.New("Filter invalid")
+	ErrBadSort        = errors.New("Sort invalid")
+	ErrBadCursor      = errors.New("Cursor invalid")
+	ErrBadFields      = errors.New("Fields invalid")
+	ErrTokenExpired   = errors.New("AccessToken expired")
+	// токену не хватает права, какого - в тексте ошибки
+	ErrInsufficientScope = errors.New("AccessToken has insufficient scope")
+)
+
+// TimeoutError - внешняя система не ответила вовремя
+type TimeoutError struct {
+	Query string
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout for %s", e.Query)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// BadRequestError - внешняя система отклонила запрос с незнакомым кодом ошибки
+type BadRequestError struct {
+	Code string
+}
+
+func (e *BadRequestError) Error() string {
+	return fmt.Sprintf("unknown bad request error: %s", e.Code)
 }
 
 // FindUsers отправляет запрос во внешнюю систему, которая непосредственно ищет пользоваталей
 func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
+	return srv.FindUsersContext(context.Background(), req)
+}
+
+// FindUsersContext - FindUsers, который прерывается вместе с ctx
+func (srv *SearchClient) FindUsersContext(ctx context.Context, req SearchRequest) (*SearchResponse, error) {
+	call, err := prepareCall(req)
+	if err != nil {
+		return nil, err
+	}
+	req, searcherParams := call.req, call.params
+
+	backoff := srv.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	accessToken, err := srv.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := srv.cacheKey(accessToken, searcherParams)
+	var cached *cacheEntry
+	if srv.Cache != nil {
+		entry, fresh := srv.Cache.get(cacheKey)
+		if fresh {
+			result := copyResponse(&entry.response)
+			return &result, nil
+		}
+		cached = entry
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 || refreshed {
+			accessToken, err = srv.accessToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// с новым токеном ответ кешируется отдельно, запись под старым не подходит
+			if key := srv.cacheKey(accessToken, searcherParams); key != cacheKey {
+				cacheKey, cached = key, nil
+			}
+		}
+
+		etag := ""
+		if cached != nil {
+			etag = cached.etag
+		}
+
+		resp, err := srv.doRequest(ctx, searcherParams, req, accessToken, etag)
+		if err == nil {
+			if resp.notModified {
+				srv.Cache.revalidated(cacheKey, cached)
+				result := copyResponse(&cached.response)
+				return &result, nil
+			}
+
+			result, err := call.decode(resp.body)
+			if err == nil && srv.Cache != nil {
+				srv.Cache.put(cacheKey, result, resp.etag)
+			}
+			return result, err
+		}
+
+		// токен истёк раньше, чем рассчитывал TokenSource: один раз пробуем
+		// новый токен, не тратя на это повторы
+		if expirer, ok := srv.TokenSource.(tokenExpirer); ok && errors.Is(err, ErrTokenExpired) && !refreshed {
+			expirer.Expire(accessToken)
+			refreshed = true
+			attempt--
+			continue
+		}
+
+		if attempt >= srv.Retries || !retryable(err) {
+			return nil, err
+		}
+
+		if err := sleep(ctx, jitter(backoff)); err != nil {
+			return nil, err
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
 
+// searchCall - проверенный запрос и его параметры для сервера
+type searchCall struct {
+	req        SearchRequest
+	params     url.Values
+	cursorMode bool
+}
+
+func prepareCall(req SearchRequest) (*searchCall, error) {
 	searcherParams := url.Values{}
 
 	if req.Limit < 0 {
@@ -78,58 +289,212 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 		return nil, fmt.Errorf("offset must be > 0")
 	}
 
+	cursorMode := req.UseCursor || req.Cursor != ""
+
 	//нужно для получения следующей записи, на основе которой мы скажем - можно показать переключатель следующей страницы или нет
-	req.Limit++
+	//в режиме курсоров о следующей странице говорит сам сервер
+	if !cursorMode {
+		req.Limit++
+	}
 
 	searcherParams.Add("limit", strconv.Itoa(req.Limit))
 	searcherParams.Add("offset", strconv.Itoa(req.Offset))
 	searcherParams.Add("query", req.Query)
 	searcherParams.Add("order_field", req.OrderField)
 	searcherParams.Add("order_by", strconv.Itoa(req.OrderBy))
+	if req.Filter != "" {
+		searcherParams.Add("filter", req.Filter)
+	}
+	if len(req.Sort) > 0 {
+		searcherParams.Add("sort", encodeSort(req.Sort))
+	}
+	if cursorMode {
+		searcherParams.Add("cursor", req.Cursor)
+	}
+	if len(req.Fields) > 0 {
+		searcherParams.Add("fields", strings.Join(req.Fields, ","))
+	}
 
+	return &searchCall{req: req, params: searcherParams, cursorMode: cursorMode}, nil
+}
+
+func (c *searchCall) decode(body []byte) (*SearchResponse, error) {
+	relevance := c.req.OrderField == OrderFieldRelevance && len(c.req.Sort) == 0
+	return decodeResponse(body, c.req.Limit, c.cursorMode, relevance)
+}
+
+// cacheKey включает отпечаток токена: сервер отдаёт разным токенам разные
+// ответы, и один кеш может быть общим у нескольких клиентов
+func (srv *SearchClient) cacheKey(accessToken string, params url.Values) string {
+	fingerprint := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(fingerprint[:8]) + " " + srv.URL + "?" + params.Encode()
+}
+
+func (srv *SearchClient) accessToken(ctx context.Context) (string, error) {
+	if srv.TokenSource == nil {
+		return srv.AccessToken, nil
+	}
+	return srv.TokenSource.Token(ctx)
+}
+
+func retryable(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.Is(err, ErrServerInternal) || errors.As(err, &timeoutErr)
+}
+
+// jitter возвращает случайную паузу от d/2 до d, чтобы клиенты не повторяли запросы хором
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type rawResponse struct {
+	body        []byte
+	etag        string
+	notModified bool
+}
+
+func (srv *SearchClient) doRequest(ctx context.Context, searcherParams url.Values, req SearchRequest, accessToken string, etag string) (*rawResponse, error) {
 	searcherReq, err := http.NewRequest("GET", srv.URL+"?"+searcherParams.Encode(), nil)
-	searcherReq.Header.Add("AccessToken", srv.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("unknown error %s", err)
+	}
+	searcherReq = searcherReq.WithContext(ctx)
+	searcherReq.Header.Add("AccessToken", accessToken)
+	if etag != "" {
+		searcherReq.Header.Set("If-None-Match", etag)
+	}
 
-	resp, err := client.Do(searcherReq)
+	httpClient := srv.HTTPClient
+	if httpClient == nil {
+		httpClient = client
+	}
+
+	resp, err := httpClient.Do(searcherReq)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err, ok := err.(net.Error); ok && err.Timeout() {
-			return nil, fmt.Errorf("timeout for %s", searcherParams.Encode())
+			return nil, &TimeoutError{Query: searcherParams.Encode(), Err: err}
 		}
 		return nil, fmt.Errorf("unknown error %s", err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			return nil, &TimeoutError{Query: searcherParams.Encode(), Err: err}
+		}
+		return nil, fmt.Errorf("cant read response: %s", err)
+	}
 
-	switch resp.StatusCode {
-	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("Bad AccessToken")
-	case http.StatusInternalServerError:
-		return nil, fmt.Errorf("SearchServer fatal error")
-	case http.StatusBadRequest:
+	if resp.StatusCode == http.StatusNotModified {
+		if etag == "" {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return &rawResponse{notModified: true}, nil
+	}
+	if err := responseError(resp.StatusCode, resp.Status, body, req); err != nil {
+		return nil, err
+	}
+
+	return &rawResponse{body: body, etag: resp.Header.Get("ETag")}, nil
+}
+
+// responseError переводит ответ сервера с ошибкой в ошибку клиента
+func responseError(statusCode int, status string, body []byte, req SearchRequest) error {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		// сервер со статическими токенами отвечает без тела
+		errResp := SearchErrorResponse{}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error == "ErrorTokenExpired" {
+			return ErrTokenExpired
+		}
+		return ErrBadToken
+	case statusCode == http.StatusForbidden:
 		errResp := SearchErrorResponse{}
-		err = json.Unmarshal(body, &errResp)
-		if err != nil {
-			return nil, fmt.Errorf("cant unpack error json: %s", err)
+		json.Unmarshal(body, &errResp)
+		return fmt.Errorf("%w: %s", ErrInsufficientScope, errResp.Message)
+	case statusCode == http.StatusInternalServerError:
+		return ErrServerInternal
+	case statusCode > http.StatusInternalServerError:
+		return fmt.Errorf("%w: %s", ErrServerInternal, status)
+	case statusCode == http.StatusBadRequest:
+		errResp := SearchErrorResponse{}
+		if err := json.Unmarshal(body, &errResp); err != nil {
+			return fmt.Errorf("cant unpack error json: %s", err)
 		}
 		if errResp.Error == "ErrorBadOrderField" {
-			return nil, fmt.Errorf("OrderFeld %s invalid", req.OrderField)
+			return fmt.Errorf("OrderFeld %s invalid: %w", req.OrderField, ErrBadOrderField)
+		}
+		if errResp.Error == "ErrorBadFilter" {
+			return fmt.Errorf("%w: %s", ErrBadFilter, errResp.Message)
+		}
+		if errResp.Error == "ErrorBadSort" {
+			return fmt.Errorf("%w: %s", ErrBadSort, errResp.Message)
+		}
+		if errResp.Error == "ErrorBadCursor" {
+			return fmt.Errorf("%w: %s", ErrBadCursor, errResp.Message)
 		}
-		return nil, fmt.Errorf("unknown bad request error: %s", errResp.Error)
+		if errResp.Error == "ErrorBadFields" {
+			return fmt.Errorf("%w: %s", ErrBadFields, errResp.Message)
+		}
+		return &BadRequestError{Code: errResp.Error}
+	}
+
+	return nil
+}
+
+func decodeResponse(body []byte, limit int, cursorMode bool, relevance bool) (*SearchResponse, error) {
+	if cursorMode {
+		page := cursorPage{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("cant unpack result json: %s", err)
+		}
+		return &SearchResponse{
+			Users:      page.Users,
+			NextPage:   page.NextCursor != "",
+			NextCursor: page.NextCursor,
+		}, nil
 	}
 
 	data := []User{}
-	err = json.Unmarshal(body, &data)
-	if err != nil {
+	var scores []Relevance
+	if relevance {
+		scored := []scoredUser{}
+		if err := json.Unmarshal(body, &scored); err != nil {
+			return nil, fmt.Errorf("cant unpack result json: %s", err)
+		}
+		data = make([]User, len(scored))
+		scores = make([]Relevance, len(scored))
+		for i := range scored {
+			data[i], scores[i] = scored[i].User, scored[i].Relevance
+		}
+	} else if err := json.Unmarshal(body, &data); err != nil {
 		return nil, fmt.Errorf("cant unpack result json: %s", err)
 	}
 
 	result := SearchResponse{}
-	if len(data) == req.Limit {
+	if len(data) == limit {
 		result.NextPage = true
 		result.Users = data[0 : len(data)-1]
 	} else {
 		result.Users = data[0:len(data)]
 	}
-
-	return &result, err
+	if relevance {
+		result.Relevance = scores[:len(result.Users)]
+	}
+	return &result, nil
 }
@@ -1,22 +1,23 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
-	"fmt"
-	"io/ioutil"
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"sort"
-	"strconv"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/faultproxy"
+	"github.com/akurin/golang-webservices/hw4_test_coverage/searcher"
 )
 
 func Test_Client_Finds_Users(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(xmlDatasetSearchServer))
+	server := newDatasetServer(t)
 	defer server.Close()
 
 	tests := []struct {
@@ -54,7 +55,8 @@ func Test_Client_Finds_Users(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			client := &SearchClient{
-				URL: server.URL,
+				AccessToken: testAccessToken,
+				URL:         server.URL,
 			}
 			got, err := client.FindUsers(test.req)
 			if err != nil {
@@ -70,181 +72,18 @@ func Test_Client_Finds_Users(t *testing.T) {
 	}
 }
 
-func xmlDatasetSearchServer(w http.ResponseWriter, r *http.Request) {
-	usersBytes, err := searchInXMLDataSet(r)
-	if err != nil {
-		w.Write([]byte(err.Error()))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(usersBytes)
-}
-
-func searchInXMLDataSet(r *http.Request) ([]byte, error) {
-	dataset, err := readDataSet()
-	if err != nil {
-		return nil, err
-	}
-	request, err := readSearchRequest(*r.URL)
-	if err != nil {
-		return nil, err
-	}
-
-	users, err := applyRequest(dataset, request)
-	if err != nil {
-		return nil, err
-	}
-
-	usersBytes, err := json.Marshal(users)
-	if err != nil {
-		return nil, err
-	}
-
-	return usersBytes, nil
-}
-
-func readDataSet() ([]User, error) {
-	datasetBytes, err := ioutil.ReadFile("dataset.xml")
-	if err != nil {
-		return nil, err
-	}
-
-	var dataset Dataset
-	if err = xml.Unmarshal(datasetBytes, &dataset); err != nil {
-		return nil, err
-	}
-
-	result := make([]User, 0, len(dataset.Rows))
-	for _, row := range dataset.Rows {
-		user := User{
-			Id:     row.Id,
-			Name:   row.FirstName + " " + row.LastName,
-			Age:    row.Age,
-			About:  row.About,
-			Gender: row.Gender,
-		}
-		result = append(result, user)
-	}
-
-	return result, nil
-}
-
-type Dataset struct {
-	XMLName xml.Name `xml:"root"`
-	Rows    []Row    `xml:"row"`
-}
-
-type Row struct {
-	XMLName   xml.Name `xml:"row"̀`
-	Id        int      `xml:"id"`
-	Age       int      `xml:"age"`
-	FirstName string   `xml:"first_name"`
-	LastName  string   `xml:"last_name"`
-	Gender    string   `xml:"gender"`
-	About     string   `xml:"about"`
-}
-
-func readSearchRequest(u url.URL) (SearchRequest, error) {
-	query := u.Query()
-
-	limitString := query.Get("limit")
-	limit, err := strconv.Atoi(limitString)
-	if err != nil {
-		return SearchRequest{}, err
-	}
-
-	offsetString := query.Get("offset")
-	offset, err := strconv.Atoi(offsetString)
-	if err != nil {
-		return SearchRequest{}, err
-	}
-
-	q := query.Get("query")
-
-	orderField := query.Get("order_field")
-
-	orderByString := query.Get("order_by")
-	orderBy, err := strconv.Atoi(orderByString)
-	if err != nil {
-		return SearchRequest{}, err
-	}
-
-	return SearchRequest{
-		Limit:      limit,
-		Offset:     offset,
-		Query:      q,
-		OrderField: orderField,
-		OrderBy:    orderBy,
-	}, nil
-}
+const testAccessToken = "test-token"
 
-func applyRequest(users []User, request SearchRequest) ([]User, error) {
-	sortFunc, err := sortFunc(users, request.OrderField)
+func newDatasetHandler(t *testing.T) http.Handler {
+	users, err := searcher.LoadDataset("dataset.xml")
 	if err != nil {
-		return nil, err
-	}
-	var result []User
-
-	switch request.OrderBy {
-	case OrderByAsc:
-		sort.Slice(users, sortFunc)
-	case OrderByDesc:
-		sort.Slice(users, sortFunc)
-		reverse(users)
-	case OrderByAsIs:
-	default:
-		panic(fmt.Sprintf("Unexpected OrderBy value: %d", request.OrderBy))
-	}
-
-	usersMeetFilterCount := 0
-	for _, user := range users {
-		if len(result) == request.Limit {
-			break
-		}
-
-		userMeetsFilter := strings.Contains(user.Name, request.Query) ||
-			strings.Contains(user.About, request.Query)
-
-		if userMeetsFilter {
-			if usersMeetFilterCount >= request.Offset {
-				result = append(result, user)
-			}
-
-			usersMeetFilterCount++
-		}
-	}
-
-	return result, nil
-}
-
-func sortFunc(users []User, orderField string) (func(i, j int) bool, error) {
-	switch orderField {
-	case "Id":
-		return func(i, j int) bool {
-			return users[i].Id < users[j].Id
-		}, nil
-	case "Age":
-		return func(i, j int) bool {
-			return users[i].Age < users[j].Age
-		}, nil
-	case "Name":
-		fallthrough
-	case "":
-		return func(i, j int) bool {
-			return users[i].Name < users[j].Name
-		}, nil
-	default:
-		return nil, fmt.Errorf("Unknown field '%s'", orderField)
+		t.Fatalf("cant load dataset: %s", err)
 	}
+	return searcher.NewServer(users, []string{testAccessToken})
 }
 
-func reverse(users []User) {
-	last := len(users) - 1
-	for i := 0; i < len(users)/2; i++ {
-		users[i], users[last-i] = users[last-i], users[i]
-	}
+func newDatasetServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(newDatasetHandler(t))
 }
 
 func Test_Client_Validates_Arguments(t *testing.T) {
@@ -385,3 +224,475 @@ func Test_Returns_Error_When_Invalid_Url(t *testing.T) {
 		t.Errorf("Expected error")
 	}
 }
+
+func Test_Returns_Typed_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		check      func(err error) bool
+	}{
+		{
+			name:       "bad token",
+			statusCode: http.StatusUnauthorized,
+			check:      func(err error) bool { return errors.Is(err, ErrBadToken) },
+		},
+		{
+			name:       "bad order field",
+			statusCode: http.StatusBadRequest,
+			body:       `{"Error": "ErrorBadOrderField"}`,
+			check:      func(err error) bool { return errors.Is(err, ErrBadOrderField) },
+		},
+		{
+			name:       "other bad request",
+			statusCode: http.StatusBadRequest,
+			body:       `{"Error": "ErrorBadLimit"}`,
+			check: func(err error) bool {
+				var badRequest *BadRequestError
+				return errors.As(err, &badRequest) && badRequest.Code == "ErrorBadLimit"
+			},
+		},
+		{
+			name:       "internal server error",
+			statusCode: http.StatusInternalServerError,
+			check:      func(err error) bool { return errors.Is(err, ErrServerInternal) },
+		},
+		{
+			name:       "bad gateway",
+			statusCode: http.StatusBadGateway,
+			check:      func(err error) bool { return errors.Is(err, ErrServerInternal) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(test.statusCode)
+					w.Write([]byte(test.body))
+				}))
+			defer server.Close()
+
+			client := &SearchClient{URL: server.URL}
+			_, err := client.FindUsers(SearchRequest{})
+
+			if err == nil || !test.check(err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func Test_Returns_TimeoutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+	defer server.Close()
+
+	client := &SearchClient{
+		URL:        server.URL,
+		HTTPClient: &http.Client{Timeout: 10 * time.Millisecond},
+	}
+	_, err := client.FindUsers(SearchRequest{})
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected TimeoutError, got: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "timeout for ") {
+		t.Errorf("Unexpected message: %s", err)
+	}
+}
+
+// flakyServer отвечает failures раз ответом fail, а потом как dataset-сервер
+func flakyServer(t *testing.T, failures int32, fail http.HandlerFunc) (*httptest.Server, *int32) {
+	dataset := newDatasetHandler(t)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) <= failures {
+				fail(w, r)
+				return
+			}
+			dataset.ServeHTTP(w, r)
+		}))
+	return server, &calls
+}
+
+func Test_Client_Retries(t *testing.T) {
+	internalError := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	unauthorized := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	tests := []struct {
+		name      string
+		fail      http.HandlerFunc
+		failures  int32
+		retries   int
+		wantErr   bool
+		wantCalls int32
+	}{
+		{
+			name:      "recovers after 5xx",
+			fail:      internalError,
+			failures:  2,
+			retries:   2,
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after retries",
+			fail:      internalError,
+			failures:  2,
+			retries:   1,
+			wantErr:   true,
+			wantCalls: 2,
+		},
+		{
+			name:      "recovers after timeout",
+			fail:      slow,
+			failures:  1,
+			retries:   1,
+			wantCalls: 2,
+		},
+		{
+			name:      "does not retry bad token",
+			fail:      unauthorized,
+			failures:  1,
+			retries:   3,
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := flakyServer(t, test.failures, test.fail)
+			defer server.Close()
+
+			client := &SearchClient{
+				AccessToken: testAccessToken,
+				URL:         server.URL,
+				HTTPClient:  &http.Client{Timeout: 50 * time.Millisecond},
+				Retries:     test.retries,
+				Backoff:     time.Millisecond,
+			}
+			got, err := client.FindUsers(SearchRequest{Limit: 5})
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err == nil && len(got.Users) != 5 {
+				t.Errorf("len = %v, want 5", len(got.Users))
+			}
+			if got := atomic.LoadInt32(calls); got != test.wantCalls {
+				t.Errorf("calls = %v, want %v", got, test.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_Client_Retries_Through_Faults(t *testing.T) {
+	dataset := newDatasetServer(t)
+	defer dataset.Close()
+
+	faults, _ := faultproxy.ParseFaults("status=0.3:503,latency=0.2:100ms")
+	proxy, err := faultproxy.New(dataset.URL, faults, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	direct := &SearchClient{AccessToken: testAccessToken, URL: dataset.URL}
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+		HTTPClient:  &http.Client{Timeout: 50 * time.Millisecond},
+		Backoff:     time.Millisecond,
+	}
+
+	// без повторов сбои доходят до вызывающего как ошибки, после которых стоит повторить
+	failed := 0
+	for offset := 0; offset < 20; offset++ {
+		_, err := client.FindUsers(SearchRequest{Limit: 3, Offset: offset})
+		if err != nil {
+			if !retryable(err) {
+				t.Fatalf("Expected retryable error, got: %v", err)
+			}
+			failed++
+		}
+	}
+	if failed == 0 {
+		t.Error("Expected some requests to fail without retries")
+	}
+
+	client.Retries = 20
+	for offset := 0; offset < 20; offset++ {
+		req := SearchRequest{Limit: 3, Offset: offset, OrderField: "Id", OrderBy: OrderByAsc}
+		got, err := client.FindUsers(req)
+		if err != nil {
+			t.Fatalf("offset %d: Unexpected error: %s", offset, err)
+		}
+		want, _ := direct.FindUsers(req)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("offset %d: got %+v, want %+v", offset, got, want)
+		}
+	}
+
+	injected := proxy.Injected()
+	if injected[faultproxy.Status] == 0 || injected[faultproxy.Latency] == 0 {
+		t.Errorf("Expected both faults, got %v", injected)
+	}
+}
+
+func Test_Client_Stops_On_Context_Cancel(t *testing.T) {
+	server, calls := flakyServer(t, 100, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := &SearchClient{
+		URL:     server.URL,
+		Retries: 100,
+		Backoff: 20 * time.Millisecond,
+	}
+	_, err := client.FindUsersContext(ctx, SearchRequest{})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got >= 100 {
+		t.Errorf("Expected retries to stop, got %d calls", got)
+	}
+}
+
+func Test_Client_Filter_And_Sort(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+	got, err := client.FindUsers(SearchRequest{
+		Limit:  25,
+		Filter: "age>30 and (gender=female or name~Wolf)",
+		Sort:   []SortField{{Field: "Age", Desc: true}, {Field: "name"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(got.Users) == 0 {
+		t.Fatalf("Expected users")
+	}
+	for i, user := range got.Users {
+		if user.Age <= 30 || user.Gender != "female" && !strings.Contains(user.Name, "Wolf") {
+			t.Errorf("%+v does not match filter", user)
+		}
+		if i > 0 {
+			prev := got.Users[i-1]
+			if prev.Age < user.Age || prev.Age == user.Age && prev.Name > user.Name {
+				t.Errorf("%+v is before %+v", prev, user)
+			}
+		}
+	}
+
+	_, err = client.FindUsers(SearchRequest{Filter: "age>"})
+	if !errors.Is(err, ErrBadFilter) {
+		t.Errorf("Expected ErrBadFilter, got: %v", err)
+	}
+	_, err = client.FindUsers(SearchRequest{Sort: []SortField{{Field: "Salary"}}})
+	if !errors.Is(err, ErrBadSort) {
+		t.Errorf("Expected ErrBadSort, got: %v", err)
+	}
+}
+
+func Test_Client_Sends_Old_Style_Params(t *testing.T) {
+	var params url.Values
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			params = r.URL.Query()
+			w.Write([]byte("[]"))
+		}))
+	defer server.Close()
+
+	client := &SearchClient{URL: server.URL}
+	if _, err := client.FindUsers(SearchRequest{Query: "a", OrderField: "Age", OrderBy: OrderByAsc}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := url.Values{
+		"limit":       {"1"},
+		"offset":      {"0"},
+		"query":       {"a"},
+		"order_field": {"Age"},
+		"order_by":    {"-1"},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %v, want %v", params, want)
+	}
+}
+
+func Test_Client_Cursor_Pages_Stay_Consistent(t *testing.T) {
+	users, err := searcher.LoadDataset("dataset.xml")
+	if err != nil {
+		t.Fatalf("cant load dataset: %s", err)
+	}
+	dataset := searcher.NewServer(users, []string{testAccessToken})
+	server := httptest.NewServer(dataset)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	req := SearchRequest{Limit: 5, OrderField: "Age", OrderBy: OrderByAsc, UseCursor: true}
+	seen := make(map[int]bool)
+	for page := 0; ; page++ {
+		resp, err := client.FindUsers(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		for _, user := range resp.Users {
+			if seen[user.Id] {
+				t.Errorf("user %d returned twice", user.Id)
+			}
+			seen[user.Id] = true
+		}
+		if !resp.NextPage {
+			break
+		}
+		req.Cursor = resp.NextCursor
+
+		// самый молодой пользователь попадает на уже пройденные страницы
+		if err := dataset.Insert(searcher.User{Id: 100 + page, Name: "New User", Age: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, user := range users {
+		if !seen[user.Id] {
+			t.Errorf("user %d was skipped", user.Id)
+		}
+	}
+
+	req.Cursor = "bad"
+	if _, err := client.FindUsers(req); !errors.Is(err, ErrBadCursor) {
+		t.Errorf("Expected ErrBadCursor, got: %v", err)
+	}
+}
+
+func Test_Paginator_With_Cursor(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	req := SearchRequest{Limit: 4, Offset: 2, OrderField: "Name", OrderBy: OrderByDesc}
+	want := allUsers(t, client, req)
+
+	req.UseCursor = true
+	got, err := client.CollectUsers(context.Background(), req, 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d users, want %d", len(got), len(want))
+	}
+}
+
+func Test_Client_Fields(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	full, err := client.FindUsers(SearchRequest{Limit: 3, OrderField: "Id", OrderBy: OrderByAsc})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if full.Users[0].Email == "" || full.Users[0].Registered.IsZero() || full.Users[0].Balance == 0 {
+		t.Errorf("expected full model, got %+v", full.Users[0])
+	}
+
+	got, err := client.FindUsers(SearchRequest{Limit: 3, OrderField: "Id", OrderBy: OrderByAsc, Fields: []string{"Id", "Email"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !got.NextPage || len(got.Users) != 3 {
+		t.Errorf("NextPage = %v, len = %d", got.NextPage, len(got.Users))
+	}
+	for i, user := range got.Users {
+		want := User{Id: full.Users[i].Id, Email: full.Users[i].Email}
+		if !reflect.DeepEqual(user, want) {
+			t.Errorf("got %+v, want %+v", user, want)
+		}
+	}
+
+	_, err = client.FindUsers(SearchRequest{Fields: []string{"Salary"}})
+	if !errors.Is(err, ErrBadFields) {
+		t.Errorf("Expected ErrBadFields, got: %v", err)
+	}
+}
+
+func Test_Client_Relevance(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	got, err := client.FindUsers(SearchRequest{Limit: 5, Query: "Boyd cillum", OrderField: OrderFieldRelevance})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !got.NextPage || len(got.Users) != 5 || len(got.Relevance) != 5 {
+		t.Fatalf("NextPage = %v, len = %d, relevance = %d", got.NextPage, len(got.Users), len(got.Relevance))
+	}
+	if got.Users[0].Name != "Boyd Wolf" || got.Users[0].Email == "" {
+		t.Errorf("unexpected first user %+v", got.Users[0])
+	}
+	for i, relevance := range got.Relevance {
+		if i > 0 && relevance.Score > got.Relevance[i-1].Score {
+			t.Errorf("user %d scored higher than previous", got.Users[i].Id)
+		}
+		if !strings.Contains(relevance.Snippet, "<em>cillum</em>") && !strings.Contains(relevance.Snippet, "<em>Boyd</em>") {
+			t.Errorf("unexpected snippet %q", relevance.Snippet)
+		}
+	}
+
+	projected, err := client.FindUsers(SearchRequest{Limit: 1, Query: "boyd", OrderField: OrderFieldRelevance, Fields: []string{"Id"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(projected.Users, []User{{Id: 0}}) ||
+		projected.Relevance[0].Score <= 0 || projected.Relevance[0].Snippet != "<em>Boyd</em> Wolf" {
+		t.Errorf("got %+v", projected)
+	}
+
+	plain, _ := client.FindUsers(SearchRequest{Limit: 5, Query: "Boyd"})
+	if plain.Relevance != nil {
+		t.Errorf("Relevance without OrderFieldRelevance: %v", plain.Relevance)
+	}
+}
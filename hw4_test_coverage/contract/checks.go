@@ -0,0 +1,227 @@
+package contract
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// firstPage - пользователи в порядке "как встретилось"; для проверок их
+// нужно хотя бы два
+func firstPage(s *session) ([]user, error) {
+	users, err := s.search(request{limit: maxLimit})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) < 2 {
+		return nil, fmt.Errorf("need at least 2 users for the checks, server has %d", len(users))
+	}
+	return users, nil
+}
+
+func checkJSON(s *session) error {
+	_, err := firstPage(s)
+	return err
+}
+
+func checkLimit(s *session) error {
+	all, err := firstPage(s)
+	if err != nil {
+		return err
+	}
+
+	for _, limit := range []int{0, 1, 2, len(all)} {
+		req := request{limit: limit}
+		users, err := s.search(req)
+		if err != nil {
+			return err
+		}
+		if err := samePage(req, users, all[:limit]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkOffset(s *session) error {
+	all, err := firstPage(s)
+	if err != nil {
+		return err
+	}
+
+	for _, offset := range []int{1, len(all) - 1} {
+		req := request{limit: maxLimit - offset, offset: offset}
+		users, err := s.search(req)
+		if err != nil {
+			return err
+		}
+		if err := samePage(req, users, all[offset:]); err != nil {
+			return err
+		}
+	}
+
+	req := request{limit: maxLimit, offset: 1 << 20}
+	users, err := s.search(req)
+	if err != nil {
+		return err
+	}
+	if len(users) != 0 {
+		return fmt.Errorf("%s: got %d users past the end, want 0", req, len(users))
+	}
+	return nil
+}
+
+var orderKeys = map[string]func(u user) interface{}{
+	"Id":   func(u user) interface{} { return u.Id },
+	"Age":  func(u user) interface{} { return u.Age },
+	"Name": func(u user) interface{} { return u.Name },
+	// пустой order_field - сортировка по Name
+	"": func(u user) interface{} { return u.Name },
+}
+
+func checkOrder(s *session) error {
+	for _, field := range []string{"Id", "Age", "Name", ""} {
+		key := orderKeys[field]
+		for _, orderBy := range []int{orderByAsc, orderByDesc} {
+			req := request{limit: maxLimit, orderField: field, orderBy: orderBy}
+			users, err := s.search(req)
+			if err != nil {
+				return err
+			}
+
+			for i := 1; i < len(users); i++ {
+				c := compare(key(users[i-1]), key(users[i]))
+				if orderBy == orderByAsc && c > 0 || orderBy == orderByDesc && c < 0 {
+					return fmt.Errorf("%s: user %d (%v) and user %d (%v) are out of order",
+						req, users[i-1].Id, key(users[i-1]), users[i].Id, key(users[i]))
+				}
+			}
+		}
+	}
+
+	_, err := s.search(request{limit: maxLimit, orderBy: orderByAsIs})
+	return err
+}
+
+func checkQuery(s *session) error {
+	all, err := firstPage(s)
+	if err != nil {
+		return err
+	}
+
+	words := strings.Fields(all[0].Name + " " + all[0].About)
+	if len(words) == 0 {
+		return fmt.Errorf("user %d has neither Name nor About", all[0].Id)
+	}
+	word := words[0]
+	req := request{limit: maxLimit, query: word}
+	users, err := s.search(req)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 || users[0].Id != all[0].Id {
+		return fmt.Errorf("%s: user %d is not found first", req, all[0].Id)
+	}
+	for _, user := range users {
+		if !strings.Contains(user.Name, word) && !strings.Contains(user.About, word) {
+			return fmt.Errorf("%s: user %d has %q neither in Name nor in About", req, user.Id, word)
+		}
+	}
+
+	req = request{limit: maxLimit, query: "no such user: 7f3d9c"}
+	if users, err = s.search(req); err != nil {
+		return err
+	}
+	if len(users) != 0 {
+		return fmt.Errorf("%s: got %d users, want 0", req, len(users))
+	}
+	return nil
+}
+
+func checkBadOrderField(s *session) error {
+	req := request{limit: maxLimit, orderField: "Salary", orderBy: orderByAsc}
+	errResp, err := s.searchError(req.values(), s.token, http.StatusBadRequest)
+	if err != nil {
+		return err
+	}
+	if errResp.Error != "ErrorBadOrderField" {
+		return fmt.Errorf("%s: Error = %q, want ErrorBadOrderField", req, errResp.Error)
+	}
+	return nil
+}
+
+func checkBadParams(s *session) error {
+	bad := []url.Values{
+		{"limit": {"-1"}},
+		{"limit": {"many"}},
+		{"offset": {"-1"}},
+		{"offset": {"first"}},
+		{"order_by": {"2"}},
+		{"order_by": {"asc"}},
+	}
+
+	for _, params := range bad {
+		values := request{limit: maxLimit}.values()
+		for name, value := range params {
+			values[name] = value
+		}
+		if _, err := s.searchError(values, s.token, http.StatusBadRequest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkAuth(s *session) error {
+	values := request{limit: 1}.values()
+	for _, token := range []string{"", s.token + "-wrong"} {
+		if _, err := s.searchError(values, token, http.StatusUnauthorized); err != nil {
+			return fmt.Errorf("token %q: %s", token, err)
+		}
+	}
+	return nil
+}
+
+func checkTimeout(s *session) error {
+	for _, req := range []request{
+		{limit: maxLimit},
+		{limit: maxLimit, offset: 1 << 20, query: "e"},
+		{limit: maxLimit, orderField: "Name", orderBy: orderByDesc},
+	} {
+		started := time.Now()
+		if _, err := s.search(req); err != nil {
+			return err
+		}
+		if elapsed := time.Since(started); elapsed >= s.timeout {
+			return fmt.Errorf("%s: answered in %s, SearchClient waits %s", req, elapsed, s.timeout)
+		}
+	}
+	return nil
+}
+
+func samePage(req request, got, want []user) error {
+	if len(want) > req.limit {
+		want = want[:req.limit]
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("%s: got %d users, want %d", req, len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Id != want[i].Id {
+			return fmt.Errorf("%s: user %d is %d, want %d", req, i, got[i].Id, want[i].Id)
+		}
+	}
+	return nil
+}
+
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		return a - b.(int)
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
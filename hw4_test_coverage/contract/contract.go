@@ -0,0 +1,125 @@
+// Package contract проверяет, что сервер поиска ведёт себя так, как ожидает
+// SearchClient: лимиты, смещения, сортировка, ошибки в запросе, авторизация,
+// время ответа и корректный JSON. Проверки работают на уровне HTTP, поэтому
+// подходят для любой реализации сервера
+package contract
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultTimeout - таймаут http-клиента SearchClient по умолчанию
+const DefaultTimeout = time.Second
+
+// Target - проверяемый сервер: URL или Handler
+type Target struct {
+	URL     string
+	Handler http.Handler
+	// действующий токен для заголовка AccessToken
+	AccessToken string
+	// за сколько сервер должен отвечать, по умолчанию DefaultTimeout
+	Timeout time.Duration
+}
+
+// Check - одно поведение, которого SearchClient ждёт от сервера
+type Check struct {
+	Name string
+	Run  func(s *session) error
+}
+
+// Checks - все проверки в порядке выполнения
+var Checks = []Check{
+	{"json", checkJSON},
+	{"limit", checkLimit},
+	{"offset", checkOffset},
+	{"order", checkOrder},
+	{"query", checkQuery},
+	{"bad order field", checkBadOrderField},
+	{"bad params", checkBadParams},
+	{"auth", checkAuth},
+	{"timeout", checkTimeout},
+}
+
+// Result - итог одной проверки, Err - описание нарушения
+type Result struct {
+	Check string
+	Err   error
+}
+
+type Report []Result
+
+// Violations возвращает только нарушенные проверки
+func (r Report) Violations() Report {
+	var violations Report
+	for _, result := range r {
+		if result.Err != nil {
+			violations = append(violations, result)
+		}
+	}
+	return violations
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	for _, result := range r {
+		if result.Err != nil {
+			fmt.Fprintf(&b, "FAIL %s: %s\n", result.Check, result.Err)
+		} else {
+			fmt.Fprintf(&b, "ok   %s\n", result.Check)
+		}
+	}
+	return b.String()
+}
+
+// Run выполняет все Checks против target
+func Run(target Target) Report {
+	s, stop := start(target)
+	defer stop()
+
+	report := make(Report, 0, len(Checks))
+	for _, check := range Checks {
+		report = append(report, Result{check.Name, check.Run(s)})
+	}
+	return report
+}
+
+// Test выполняет Checks как подтесты t
+func Test(t *testing.T, target Target) {
+	s, stop := start(target)
+	defer stop()
+
+	for _, check := range Checks {
+		check := check
+		t.Run(check.Name, func(t *testing.T) {
+			if err := check.Run(s); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func start(target Target) (*session, func()) {
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	s := &session{
+		url:     target.URL,
+		token:   target.AccessToken,
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
+	}
+	if target.Handler == nil {
+		return s, func() {}
+	}
+
+	server := httptest.NewServer(target.Handler)
+	s.url = server.URL
+	return s, server.Close
+}
@@ -0,0 +1,162 @@
+package contract
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/searcher"
+)
+
+const testAccessToken = "test-token"
+
+func newSearcher(t *testing.T) http.Handler {
+	users, err := searcher.LoadDataset("../dataset.xml")
+	if err != nil {
+		t.Fatalf("cant load dataset: %s", err)
+	}
+	return searcher.NewServer(users, []string{testAccessToken})
+}
+
+func TestSearcherConforms(t *testing.T) {
+	Test(t, Target{Handler: newSearcher(t), AccessToken: testAccessToken})
+}
+
+// recorded отдаёт ответ next после изменения
+func recorded(next http.Handler, w http.ResponseWriter, r *http.Request, change func(status int, body []byte) (int, []byte)) {
+	rec := httptest.NewRecorder()
+	next.ServeHTTP(rec, r)
+	status, body := change(rec.Code, rec.Body.Bytes())
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func TestReportsViolations(t *testing.T) {
+	tests := []struct {
+		name   string
+		broken func(next http.Handler) http.HandlerFunc
+		want   []string
+	}{
+		{
+			name: "ignores offset",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					values := r.URL.Query()
+					values.Del("offset")
+					r.URL.RawQuery = values.Encode()
+					next.ServeHTTP(w, r)
+				}
+			},
+			want: []string{"offset", "bad params"},
+		},
+		{
+			name: "reverse order",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					values := r.URL.Query()
+					if values.Get("order_by") != "0" {
+						values.Set("order_by", strings.TrimPrefix("-"+values.Get("order_by"), "--"))
+					}
+					r.URL.RawQuery = values.Encode()
+					next.ServeHTTP(w, r)
+				}
+			},
+			want: []string{"order"},
+		},
+		{
+			name: "500 instead of 400",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					recorded(next, w, r, func(status int, body []byte) (int, []byte) {
+						if status == http.StatusBadRequest {
+							return http.StatusInternalServerError, body
+						}
+						return status, body
+					})
+				}
+			},
+			want: []string{"bad order field", "bad params"},
+		},
+		{
+			name: "no auth",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					r.Header.Set("AccessToken", testAccessToken)
+					next.ServeHTTP(w, r)
+				}
+			},
+			want: []string{"auth"},
+		},
+		{
+			name: "wrong token reported as expired",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					recorded(next, w, r, func(status int, body []byte) (int, []byte) {
+						if status == http.StatusUnauthorized {
+							return status, []byte(`{"Error": "ErrorTokenExpired"}`)
+						}
+						return status, body
+					})
+				}
+			},
+			want: []string{"auth"},
+		},
+		{
+			name: "truncated json",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					recorded(next, w, r, func(status int, body []byte) (int, []byte) {
+						return status, body[:len(body)/2]
+					})
+				}
+			},
+			want: []string{"json", "limit", "offset", "order", "query", "bad order field", "bad params", "timeout"},
+		},
+		{
+			name: "missing fields",
+			broken: func(next http.Handler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					recorded(next, w, r, func(status int, body []byte) (int, []byte) {
+						return status, bytes.Replace(body, []byte(`"About":`), []byte(`"Bio":`), -1)
+					})
+				}
+			},
+			want: []string{"json", "limit", "offset", "order", "query", "timeout"},
+		},
+	}
+
+	for _, test := range tests {
+		report := Run(Target{Handler: test.broken(newSearcher(t)), AccessToken: testAccessToken})
+
+		var got []string
+		for _, violation := range report.Violations() {
+			got = append(got, violation.Check)
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: violated %v, want %v\n%s", test.name, got, test.want, report)
+		}
+	}
+}
+
+func TestSlowServer(t *testing.T) {
+	handler := newSearcher(t)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("order_by") == "1" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	report := Run(Target{Handler: slow, AccessToken: testAccessToken, Timeout: 50 * time.Millisecond})
+
+	violations := report.Violations()
+	if len(violations) != 2 || violations[0].Check != "order" || violations[1].Check != "timeout" {
+		t.Errorf("unexpected violations:\n%s", report)
+	}
+	if !strings.Contains(violations[0].Err.Error(), "no response within 50ms") {
+		t.Errorf("unexpected error: %s", violations[0].Err)
+	}
+}
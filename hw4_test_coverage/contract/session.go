@@ -0,0 +1,147 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	orderByAsc  = -1
+	orderByAsIs = 0
+	orderByDesc = 1
+)
+
+// maxLimit - SearchClient просит не больше 25 пользователей и ещё одного,
+// чтобы узнать, есть ли следующая страница
+const maxLimit = 26
+
+type session struct {
+	url     string
+	token   string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// request - параметры в том виде, в каком их отправляет SearchClient
+type request struct {
+	limit      int
+	offset     int
+	query      string
+	orderField string
+	orderBy    int
+}
+
+func (r request) values() url.Values {
+	return url.Values{
+		"limit":       {strconv.Itoa(r.limit)},
+		"offset":      {strconv.Itoa(r.offset)},
+		"query":       {r.query},
+		"order_field": {r.orderField},
+		"order_by":    {strconv.Itoa(r.orderBy)},
+	}
+}
+
+func (r request) String() string {
+	return "?" + r.values().Encode()
+}
+
+// user - поля, без которых SearchClient не обходится
+type user struct {
+	Id    int
+	Name  string
+	Age   int
+	About string
+}
+
+var requiredFields = []string{"Id", "Name", "Age", "About"}
+
+type errorResponse struct {
+	Error string
+}
+
+func (s *session) do(params url.Values, token string) (int, []byte, error) {
+	req, err := http.NewRequest("GET", s.url+"?"+params.Encode(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("AccessToken", token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			return 0, nil, fmt.Errorf("no response within %s", s.timeout)
+		}
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("cant read response: %s", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// search ждёт 200 и список пользователей
+func (s *session) search(req request) ([]user, error) {
+	status, body, err := s.do(req.values(), s.token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", req, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d, want 200: %.200s", req, status, body)
+	}
+
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &objects); err != nil {
+		return nil, fmt.Errorf("%s: response is not a JSON array of users: %s", req, err)
+	}
+	for i, object := range objects {
+		for _, field := range requiredFields {
+			if _, ok := object[field]; !ok {
+				return nil, fmt.Errorf("%s: user %d has no field %s", req, i, field)
+			}
+		}
+	}
+
+	users := make([]user, 0, len(objects))
+	if err := json.Unmarshal(body, &users); err != nil {
+		return nil, fmt.Errorf("%s: bad user fields: %s", req, err)
+	}
+	return users, nil
+}
+
+// searchError ждёт status и JSON с непустым Error
+func (s *session) searchError(params url.Values, token string, wantStatus int) (*errorResponse, error) {
+	status, body, err := s.do(params, token)
+	if err != nil {
+		return nil, fmt.Errorf("?%s: %s", params.Encode(), err)
+	}
+	if status != wantStatus {
+		return nil, fmt.Errorf("?%s: status %d, want %d", params.Encode(), status, wantStatus)
+	}
+	if wantStatus == http.StatusUnauthorized {
+		// тело у 401 необязательно, SearchClient смотрит в нём только Error:
+		// ErrorTokenExpired - ErrTokenExpired, всё остальное - ErrBadToken
+		errResp := &errorResponse{}
+		if json.Unmarshal(body, errResp) == nil && errResp.Error == "ErrorTokenExpired" {
+			return nil, fmt.Errorf("?%s: bad token reported as ErrorTokenExpired", params.Encode())
+		}
+		return errResp, nil
+	}
+
+	errResp := &errorResponse{}
+	if err := json.Unmarshal(body, errResp); err != nil {
+		return nil, fmt.Errorf("?%s: error is not JSON: %s", params.Encode(), err)
+	}
+	if errResp.Error == "" {
+		return nil, fmt.Errorf("?%s: no Error in %.200s", params.Encode(), body)
+	}
+	return errResp, nil
+}
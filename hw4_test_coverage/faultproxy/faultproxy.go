@@ -0,0 +1,232 @@
+// Package faultproxy - обратный прокси, который с заданной вероятностью
+// портит ответы сервера поиска: задерживает их, рвёт соединение, подменяет
+// статус, обрезает или портит тело. Нужен, чтобы проверять SearchClient и его
+// повторы на нестабильном сервере
+package faultproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	// задержка ответа на Fault.Latency
+	Latency Kind = "latency"
+	// разрыв соединения без ответа
+	Drop Kind = "drop"
+	// ответ со статусом Fault.Status без обращения к серверу
+	Status Kind = "status"
+	// ответ сервера, обрезанный на середине тела
+	Truncate Kind = "truncate"
+	// ответ сервера с испорченными байтами в теле
+	Corrupt Kind = "corrupt"
+)
+
+type Fault struct {
+	Kind Kind
+	// вероятность от 0 до 1, проверяется для каждого запроса отдельно
+	Probability float64
+	Latency     time.Duration
+	Status      int
+}
+
+func (f Fault) String() string {
+	switch f.Kind {
+	case Latency:
+		return fmt.Sprintf("%s=%g:%s", f.Kind, f.Probability, f.Latency)
+	case Status:
+		return fmt.Sprintf("%s=%g:%d", f.Kind, f.Probability, f.Status)
+	}
+	return fmt.Sprintf("%s=%g", f.Kind, f.Probability)
+}
+
+// ParseFaults разбирает список вида
+//
+//	latency=0.3:2s,drop=0.1,status=0.2:503,truncate=0.1,corrupt=0.05
+func ParseFaults(spec string) ([]Fault, error) {
+	var faults []Fault
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		eq := strings.IndexByte(item, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("fault %q: want kind=probability", item)
+		}
+		fault := Fault{Kind: Kind(item[:eq])}
+		probability, arg := item[eq+1:], ""
+		if colon := strings.IndexByte(probability, ':'); colon >= 0 {
+			probability, arg = probability[:colon], probability[colon+1:]
+		}
+
+		var err error
+		if fault.Probability, err = strconv.ParseFloat(probability, 64); err != nil || fault.Probability < 0 || fault.Probability > 1 {
+			return nil, fmt.Errorf("fault %q: probability must be from 0 to 1", item)
+		}
+
+		switch fault.Kind {
+		case Latency:
+			if fault.Latency, err = time.ParseDuration(arg); err != nil {
+				return nil, fmt.Errorf("fault %q: %s", item, err)
+			}
+		case Status:
+			if fault.Status, err = strconv.Atoi(arg); err != nil || fault.Status < 100 || fault.Status > 599 {
+				return nil, fmt.Errorf("fault %q: bad status %q", item, arg)
+			}
+		case Drop, Truncate, Corrupt:
+			if arg != "" {
+				return nil, fmt.Errorf("fault %q: unexpected argument", item)
+			}
+		default:
+			return nil, fmt.Errorf("fault %q: unknown kind %q", item, fault.Kind)
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+// Proxy пересылает запросы на target, внося сбои из faults
+type Proxy struct {
+	faults []Fault
+	proxy  *httputil.ReverseProxy
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected map[Kind]int
+}
+
+type faultsKey struct{}
+
+// New создаёт прокси. При одинаковом seed и порядке запросов сбои повторяются
+func New(target string, faults []Fault, seed int64) (*Proxy, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if targetURL.Scheme == "" || targetURL.Host == "" {
+		return nil, fmt.Errorf("target %q must be an absolute url", target)
+	}
+
+	p := &Proxy{
+		faults:   faults,
+		proxy:    httputil.NewSingleHostReverseProxy(targetURL),
+		rand:     rand.New(rand.NewSource(seed)),
+		injected: make(map[Kind]int),
+	}
+	p.proxy.ModifyResponse = p.modifyResponse
+	return p, nil
+}
+
+// Injected возвращает, сколько раз был внесён каждый сбой
+func (p *Proxy) Injected() map[Kind]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	injected := make(map[Kind]int, len(p.injected))
+	for kind, n := range p.injected {
+		injected[kind] = n
+	}
+	return injected
+}
+
+// roll выбирает сбои для очередного запроса
+func (p *Proxy) roll() []Fault {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var chosen []Fault
+	for _, fault := range p.faults {
+		if p.rand.Float64() < fault.Probability {
+			chosen = append(chosen, fault)
+			p.injected[fault.Kind]++
+		}
+	}
+	return chosen
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	chosen := p.roll()
+
+	for _, fault := range chosen {
+		switch fault.Kind {
+		case Latency:
+			timer := time.NewTimer(fault.Latency)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		case Drop:
+			// сервер закроет соединение, не отправив ответ
+			panic(http.ErrAbortHandler)
+		case Status:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(fault.Status)
+			fmt.Fprintf(w, `{"Error":"ErrorInjectedFault","Message":"status %d"}`, fault.Status)
+			return
+		}
+	}
+
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), faultsKey{}, chosen)))
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	chosen, _ := resp.Request.Context().Value(faultsKey{}).([]Fault)
+	if len(chosen) == 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	length := len(body)
+
+	for _, fault := range chosen {
+		switch fault.Kind {
+		case Corrupt:
+			body = p.corrupt(body)
+		case Truncate:
+			// Content-Length остаётся прежним, и клиент получит обрыв тела
+			body = body[:len(body)/2]
+		}
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(length)
+	resp.Header.Set("Content-Length", strconv.Itoa(length))
+	return nil
+}
+
+// corrupt заменяет нулевыми байтами примерно каждый сотый байт тела, но не
+// меньше одного: такое тело уже не разобрать как JSON
+func (p *Proxy) corrupt(body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	corrupted := append([]byte(nil), body...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := 0; i <= len(corrupted)/100; i++ {
+		corrupted[p.rand.Intn(len(corrupted))] = 0
+	}
+	return corrupted
+}
@@ -0,0 +1,147 @@
+package faultproxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const upstreamBody = `[{"Id":1,"Name":"Boyd Wolf","Age":22,"About":"Nulla cillum enim voluptate consequat laborum esse excepteur occaecat commodo nostrud excepteur ut cupidatat."}]`
+
+func newProxy(t *testing.T, spec string) (*Proxy, *httptest.Server) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(upstreamBody))
+	}))
+	t.Cleanup(upstream.Close)
+
+	faults, err := ParseFaults(spec)
+	if err != nil {
+		t.Fatalf("%q: %s", spec, err)
+	}
+	proxy, err := New(upstream.URL, faults, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	return proxy, server
+}
+
+func get(url string) (int, []byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+func TestFaults(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		_, server := newProxy(t, "latency=0:1s,drop=0,status=0:500,truncate=0,corrupt=0")
+		status, body, err := get(server.URL)
+		if err != nil || status != http.StatusOK || string(body) != upstreamBody {
+			t.Errorf("got %d %s %v", status, body, err)
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		_, server := newProxy(t, "latency=1:50ms")
+		started := time.Now()
+		status, body, err := get(server.URL)
+		if err != nil || status != http.StatusOK || string(body) != upstreamBody {
+			t.Errorf("got %d %s %v", status, body, err)
+		}
+		if elapsed := time.Since(started); elapsed < 50*time.Millisecond {
+			t.Errorf("answered in %s", elapsed)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		_, server := newProxy(t, "drop=1")
+		if _, _, err := get(server.URL); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		_, server := newProxy(t, "status=1:503")
+		status, body, err := get(server.URL)
+		if err != nil || status != http.StatusServiceUnavailable || !strings.Contains(string(body), "ErrorInjectedFault") {
+			t.Errorf("got %d %s %v", status, body, err)
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		_, server := newProxy(t, "truncate=1")
+		status, body, err := get(server.URL)
+		if err == nil || status != http.StatusOK || len(body) >= len(upstreamBody) {
+			t.Errorf("got %d %s %v", status, body, err)
+		}
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		_, server := newProxy(t, "corrupt=1")
+		status, body, err := get(server.URL)
+		if err != nil || status != http.StatusOK || len(body) != len(upstreamBody) || json.Valid(body) {
+			t.Errorf("got %d %q %v", status, body, err)
+		}
+	})
+}
+
+func TestProbabilities(t *testing.T) {
+	proxy, server := newProxy(t, "status=0.3:500,corrupt=0.5")
+
+	const requests = 200
+	failed := 0
+	for i := 0; i < requests; i++ {
+		status, body, err := get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusOK || !json.Valid(body) {
+			failed++
+		}
+	}
+
+	injected := proxy.Injected()
+	if injected[Status] < 40 || injected[Status] > 80 || injected[Corrupt] < 70 || injected[Corrupt] > 130 {
+		t.Errorf("unexpected injected faults %v", injected)
+	}
+	// испорченное тело ответа 500 не видно: сервер в этом случае не вызывается
+	if failed < injected[Status] || failed > injected[Status]+injected[Corrupt] {
+		t.Errorf("%d of %d requests failed, injected %v", failed, requests, injected)
+	}
+}
+
+func TestParseFaults(t *testing.T) {
+	faults, err := ParseFaults(" latency=0.3:2s, drop=0.1,status=1:503,truncate=0,corrupt=0.05,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Fault{
+		{Kind: Latency, Probability: 0.3, Latency: 2 * time.Second},
+		{Kind: Drop, Probability: 0.1},
+		{Kind: Status, Probability: 1, Status: 503},
+		{Kind: Truncate},
+		{Kind: Corrupt, Probability: 0.05},
+	}
+	if !reflect.DeepEqual(faults, want) {
+		t.Errorf("got %v, want %v", faults, want)
+	}
+
+	for _, bad := range []string{"latency", "latency=0.5", "latency=2:1s", "status=1:999", "drop=1:5s", "smoke=0.5", "drop=x"} {
+		if _, err := ParseFaults(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
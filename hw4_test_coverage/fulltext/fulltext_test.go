@@ -0,0 +1,113 @@
+package fulltext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"cats":        "cat",
+		"classes":     "class",
+		"ponies":      "pony",
+		"class":       "class",
+		"status":      "status",
+		"running":     "run",
+		"walked":      "walk",
+		"quickly":     "quick",
+		"falling":     "fall",
+		"sing":        "sing",
+		"bed":         "bed",
+		"voluptate":   "voluptate",
+		"consequatur": "consequatur",
+	}
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Boyd Wolf, runs\t42 miles!")
+	want := []Token{
+		{"boyd", 0, 4},
+		{"wolf", 5, 9},
+		{"run", 11, 15},
+		{"42", 16, 18},
+		{"mile", 19, 24},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if terms := Terms("Wolves and wolf, WOLF"); !reflect.DeepEqual(terms, []string{"wolve", "and", "wolf"}) {
+		t.Errorf("unexpected terms %v", terms)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ix := NewIndex()
+	docs := []string{
+		"the quick brown fox",
+		"the lazy dog sleeps all day long, the dog dreams",
+		"quick dogs and quick foxes",
+		"nothing relevant here",
+	}
+	for i, doc := range docs {
+		if n := ix.Add(doc); n != i {
+			t.Fatalf("Add = %d, want %d", n, i)
+		}
+	}
+	if ix.Len() != len(docs) {
+		t.Errorf("Len = %d", ix.Len())
+	}
+
+	scores := ix.Search(Terms("quick dog"))
+	if len(scores) != 3 || scores[3] != 0 {
+		t.Fatalf("unexpected documents %v", scores)
+	}
+	// в документе 2 оба слова, и он короче документа 1
+	if !(scores[2] > scores[1] && scores[2] > scores[0]) {
+		t.Errorf("unexpected ranking %v", scores)
+	}
+	for doc, score := range scores {
+		if got := ix.Score(doc, Terms("quick dog")); got != score {
+			t.Errorf("Score(%d) = %v, Search gave %v", doc, got, score)
+		}
+	}
+
+	// редкое слово весит больше частого
+	if rare, common := ix.Score(1, []string{"lazy"}), ix.Score(1, []string{"the"}); rare <= common {
+		t.Errorf("lazy = %v, the = %v", rare, common)
+	}
+	if len(ix.Search(Terms("cat"))) != 0 {
+		t.Error("expected no documents")
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := "Sit commodo consectetur minim amet ex. Elit aute mollit fugiat labore sint ipsum dolor."
+	tests := []struct {
+		query    string
+		maxWords int
+		want     string
+	}{
+		{"commodo", 4, "Sit <em>commodo</em> consectetur minim…"},
+		{"fugiat", 4, "…mollit <em>fugiat</em> labore sint…"},
+		{"dolor sint", 8, "…Elit aute mollit fugiat labore <em>sint</em> ipsum <em>dolor</em>."},
+		{"absent", 4, ""},
+	}
+	for _, test := range tests {
+		if got := Snippet(text, Terms(test.query), test.maxWords); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	// разметка из текста пользователя не должна попасть в сниппет как есть
+	text = `Tom & Jerry <script>alert("x")</script> and friends`
+	want := `Tom &amp; <em>Jerry</em> &lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; and friends`
+	if got := Snippet(text, Terms("jerry"), 10); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+package fulltext
+
+import "math"
+
+// параметры BM25
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Index - инвертированный индекс. Документы нумеруются по порядку Add
+type Index struct {
+	postings map[string]map[int]int
+	lengths  []int
+	total    int
+}
+
+func NewIndex() *Index {
+	return &Index{postings: make(map[string]map[int]int)}
+}
+
+// Add добавляет документ и возвращает его номер
+func (ix *Index) Add(text string) int {
+	doc := len(ix.lengths)
+	tokens := Tokenize(text)
+	for _, token := range tokens {
+		docs, ok := ix.postings[token.Term]
+		if !ok {
+			docs = make(map[int]int)
+			ix.postings[token.Term] = docs
+		}
+		docs[doc]++
+	}
+
+	ix.lengths = append(ix.lengths, len(tokens))
+	ix.total += len(tokens)
+	return doc
+}
+
+func (ix *Index) Len() int {
+	return len(ix.lengths)
+}
+
+// Search возвращает BM25 документов, в которых есть хотя бы одна из основ terms
+func (ix *Index) Search(terms []string) map[int]float64 {
+	scores := make(map[int]float64)
+	for _, term := range terms {
+		idf := ix.idf(term)
+		for doc, freq := range ix.postings[term] {
+			scores[doc] += idf * ix.weight(doc, freq)
+		}
+	}
+	return scores
+}
+
+// Score - BM25 одного документа
+func (ix *Index) Score(doc int, terms []string) float64 {
+	score := 0.0
+	for _, term := range terms {
+		if freq := ix.postings[term][doc]; freq > 0 {
+			score += ix.idf(term) * ix.weight(doc, freq)
+		}
+	}
+	return score
+}
+
+func (ix *Index) idf(term string) float64 {
+	n, df := float64(len(ix.lengths)), float64(len(ix.postings[term]))
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (ix *Index) weight(doc int, freq int) float64 {
+	avgLength := float64(ix.total) / float64(len(ix.lengths))
+	tf := float64(freq)
+	return tf * (k1 + 1) / (tf + k1*(1-b+b*float64(ix.lengths[doc])/avgLength))
+}
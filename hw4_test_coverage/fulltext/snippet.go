@@ -0,0 +1,70 @@
+package fulltext
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+	ellipsis       = "…"
+)
+
+// Snippet возвращает до maxWords слов текста вокруг первого совпадения
+// с terms - готовый HTML: текст экранирован html.EscapeString, совпадения
+// обёрнуты в <em></em>. Если совпадений нет, возвращает ""
+func Snippet(text string, terms []string, maxWords int) string {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	tokens := Tokenize(text)
+	first := -1
+	for i, token := range tokens {
+		if wanted[token.Term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 || maxWords <= 0 {
+		return ""
+	}
+
+	// совпадение ближе к началу окна, чтобы был виден контекст до него
+	from := first - maxWords/4
+	if from < 0 {
+		from = 0
+	}
+	to := from + maxWords
+	if to > len(tokens) {
+		to = len(tokens)
+		// у конца текста окно добирает слова перед совпадением
+		if from = to - maxWords; from < 0 {
+			from = 0
+		}
+	}
+
+	var out strings.Builder
+	if from > 0 {
+		out.WriteString(ellipsis)
+	}
+	pos := tokens[from].Start
+	for _, token := range tokens[from:to] {
+		out.WriteString(html.EscapeString(text[pos:token.Start]))
+		word := html.EscapeString(text[token.Start:token.End])
+		if wanted[token.Term] {
+			out.WriteString(highlightStart + word + highlightEnd)
+		} else {
+			out.WriteString(word)
+		}
+		pos = token.End
+	}
+	if to < len(tokens) {
+		out.WriteString(ellipsis)
+	} else {
+		out.WriteString(html.EscapeString(strings.TrimRight(text[pos:], " \t\n")))
+	}
+	return out.String()
+}
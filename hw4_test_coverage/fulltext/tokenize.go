@@ -0,0 +1,95 @@
+// Package fulltext - полнотекстовый поиск по пользователям: токенизатор,
+// стеммер, инвертированный индекс с оценкой BM25 и сниппеты с подсветкой
+package fulltext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token - слово текста, Start и End - его границы в байтах
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize делит текст на слова из букв и цифр. Term - основа слова в
+// нижнем регистре
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, newToken(text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, newToken(text, start, len(text)))
+	}
+	return tokens
+}
+
+func newToken(text string, start, end int) Token {
+	return Token{Term: Stem(strings.ToLower(text[start:end])), Start: start, End: end}
+}
+
+// Terms возвращает различные основы слов запроса
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range Tokenize(query) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+	return terms
+}
+
+// Stem отрезает у английского слова в нижнем регистре окончания
+// множественного числа, -ed, -ing и -ly - упрощённый первый шаг алгоритма Портера
+func Stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies"):
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ing", "ed", "ly"} {
+		stem := strings.TrimSuffix(word, suffix)
+		if stem != word && len(stem) >= 3 && hasVowel(stem) {
+			return undouble(stem)
+		}
+	}
+	return word
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// undouble убирает удвоенную согласную: running -> runn -> run
+func undouble(stem string) string {
+	n := len(stem)
+	if n >= 2 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeiouylsz", rune(stem[n-1])) {
+		return stem[:n-1]
+	}
+	return stem
+}
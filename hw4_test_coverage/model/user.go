@@ -0,0 +1,155 @@
+// Package model - пользователь из dataset.xml, общий для SearchClient и сервера
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RegisteredLayout - формат поля registered в dataset.xml
+const RegisteredLayout = "2006-01-02T15:04:05 -07:00"
+
+type User struct {
+	Id            int
+	Guid          string
+	IsActive      bool
+	Balance       Money
+	Picture       string
+	Name          string
+	Age           int
+	EyeColor      string
+	Gender        string
+	Company       string
+	Email         string
+	Phone         string
+	Address       string
+	About         string
+	Registered    time.Time
+	FavoriteFruit string
+}
+
+// Fields - поля User в порядке объявления, для проекций
+var Fields = []string{
+	"Id", "Guid", "IsActive", "Balance", "Picture", "Name", "Age", "EyeColor", "Gender",
+	"Company", "Email", "Phone", "Address", "About", "Registered", "FavoriteFruit",
+}
+
+// Field возвращает значение поля по имени из Fields
+func (u *User) Field(name string) (interface{}, bool) {
+	switch name {
+	case "Id":
+		return u.Id, true
+	case "Guid":
+		return u.Guid, true
+	case "IsActive":
+		return u.IsActive, true
+	case "Balance":
+		return u.Balance, true
+	case "Picture":
+		return u.Picture, true
+	case "Name":
+		return u.Name, true
+	case "Age":
+		return u.Age, true
+	case "EyeColor":
+		return u.EyeColor, true
+	case "Gender":
+		return u.Gender, true
+	case "Company":
+		return u.Company, true
+	case "Email":
+		return u.Email, true
+	case "Phone":
+		return u.Phone, true
+	case "Address":
+		return u.Address, true
+	case "About":
+		return u.About, true
+	case "Registered":
+		return u.Registered, true
+	case "FavoriteFruit":
+		return u.FavoriteFruit, true
+	}
+	return nil, false
+}
+
+// Money - сумма в центах. В JSON - число с двумя знаками после точки
+type Money int64
+
+const maxDollars = (math.MaxInt64 - 99) / 100
+
+// ParseMoney разбирает суммы вида "$2,144.93"
+func ParseMoney(s string) (Money, error) {
+	text := strings.TrimSpace(s)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+	text = strings.Replace(strings.TrimPrefix(text, "$"), ",", "", -1)
+
+	whole, fraction := text, "00"
+	if dot := strings.IndexByte(text, '.'); dot >= 0 {
+		whole, fraction = text[:dot], text[dot+1:]
+	}
+	if whole == "" || len(fraction) != 2 {
+		return 0, fmt.Errorf("bad money %q", s)
+	}
+
+	// в центах сумма должна поместиться в int64
+	dollars, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil || dollars > maxDollars {
+		return 0, fmt.Errorf("bad money %q", s)
+	}
+	cents, err := strconv.ParseUint(fraction, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("bad money %q", s)
+	}
+
+	m := Money(dollars*100 + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// String возвращает сумму в формате dataset.xml
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+
+	dollars := strconv.FormatInt(int64(m/100), 10)
+	for i := len(dollars) - 3; i > 0; i -= 3 {
+		dollars = dollars[:i] + "," + dollars[i:]
+	}
+	return fmt.Sprintf("%s$%s.%02d", sign, dollars, int64(m%100))
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return []byte(fmt.Sprintf("%s%d.%02d", sign, int64(m/100), int64(m%100))), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if dot := strings.IndexByte(text, '.'); dot >= 0 && len(text)-dot-1 < 2 {
+		text += strings.Repeat("0", 2-(len(text)-dot-1))
+	}
+
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		text  string
+		money Money
+		json  string
+	}{
+		{"$2,144.93", 214493, "2144.93"},
+		{"$1,047.64", 104764, "1047.64"},
+		{"$0.05", 5, "0.05"},
+		{"$999.00", 99900, "999.00"},
+		{"$1,234,567.89", 123456789, "1234567.89"},
+		{"-$12.30", -1230, "-12.30"},
+		{"$92,233,720,368,547,757.99", 9223372036854775799, "92233720368547757.99"},
+		{"-$92,233,720,368,547,757.99", -9223372036854775799, "-92233720368547757.99"},
+	}
+
+	for _, test := range tests {
+		money, err := ParseMoney(test.text)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.text, err)
+			continue
+		}
+		if money != test.money {
+			t.Errorf("%s: got %d, want %d", test.text, money, test.money)
+		}
+		if money.String() != test.text {
+			t.Errorf("%s: String() = %s", test.text, money.String())
+		}
+
+		data, _ := json.Marshal(money)
+		if string(data) != test.json {
+			t.Errorf("%s: json = %s, want %s", test.text, data, test.json)
+		}
+
+		var decoded Money
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != money {
+			t.Errorf("%s: json roundtrip gave %d, %v", test.text, decoded, err)
+		}
+	}
+
+	for _, bad := range []string{"", "$", "$1.2.3", "$1.234", "$abc", "$.50",
+		// больше не помещается в int64 центов
+		"$92,233,720,368,547,758.00", "$184467440737095516.16", "$99999999999999999999.00"} {
+		if _, err := ParseMoney(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+
+	var short Money
+	if err := json.Unmarshal([]byte("12.5"), &short); err != nil || short != 1250 {
+		t.Errorf("12.5: got %d, %v", short, err)
+	}
+}
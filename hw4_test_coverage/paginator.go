@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+const maxPageSize = 25
+
+var ErrTooManyUsers = errors.New("too many users")
+
+// Paginator лениво обходит все страницы выдачи. Использование:
+//
+//	pages := client.Paginate(ctx, SearchRequest{Query: "a"}, true)
+//	defer pages.Close()
+//	for pages.Next() {
+//		user := pages.User()
+//	}
+//	if err := pages.Err(); err != nil {
+type Paginator struct {
+	client   *SearchClient
+	ctx      context.Context
+	cancel   context.CancelFunc
+	req      SearchRequest
+	prefetch bool
+
+	// страница, которая загружается заранее, пока обходится текущая
+	pending chan pageResult
+
+	users []User
+	user  User
+	last  bool
+	err   error
+}
+
+type pageResult struct {
+	resp *SearchResponse
+	err  error
+}
+
+// Paginate начинает обход с req.Offset страницами по req.Limit пользователей
+// (0 - максимальный размер страницы). С prefetch следующая страница
+// запрашивается сразу, как только получена текущая. С req.UseCursor страницы
+// запрашиваются по курсорам
+func (srv *SearchClient) Paginate(ctx context.Context, req SearchRequest, prefetch bool) *Paginator {
+	if req.Limit <= 0 || req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &Paginator{
+		client:   srv,
+		ctx:      ctx,
+		cancel:   cancel,
+		req:      req,
+		prefetch: prefetch,
+	}
+}
+
+// Next переходит к следующему пользователю. Возвращает false, когда
+// пользователи закончились или произошла ошибка
+func (p *Paginator) Next() bool {
+	for len(p.users) == 0 {
+		if p.err != nil || p.last {
+			return false
+		}
+		p.loadPage()
+	}
+
+	p.user = p.users[0]
+	p.users = p.users[1:]
+	return true
+}
+
+func (p *Paginator) User() User {
+	return p.user
+}
+
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// Close прерывает загрузку страниц. Повторный вызов ничего не делает
+func (p *Paginator) Close() {
+	p.cancel()
+	if p.err == nil && !p.last {
+		p.err = context.Canceled
+	}
+	p.users = nil
+}
+
+func (p *Paginator) loadPage() {
+	var result pageResult
+	if p.pending != nil {
+		result = <-p.pending
+		p.pending = nil
+	} else {
+		result = p.fetch(p.req)
+	}
+
+	if result.err != nil {
+		p.err = result.err
+		p.cancel()
+		return
+	}
+
+	p.users = result.resp.Users
+	if result.resp.NextCursor != "" {
+		// курсор уже учитывает Offset первой страницы
+		p.req.Cursor = result.resp.NextCursor
+		p.req.Offset = 0
+	} else {
+		p.req.Offset += len(result.resp.Users)
+	}
+	// пустая страница с NextPage означала бы бесконечный цикл
+	p.last = !result.resp.NextPage || len(result.resp.Users) == 0
+	if p.last {
+		p.cancel()
+		return
+	}
+
+	if p.prefetch {
+		pending := make(chan pageResult, 1)
+		go func(req SearchRequest) {
+			pending <- p.fetch(req)
+		}(p.req)
+		p.pending = pending
+	}
+}
+
+func (p *Paginator) fetch(req SearchRequest) pageResult {
+	if err := p.ctx.Err(); err != nil {
+		return pageResult{err: err}
+	}
+	resp, err := p.client.FindUsersContext(p.ctx, req)
+	return pageResult{resp, err}
+}
+
+// CollectUsers загружает всю выдачу. Если пользователей больше maxUsers,
+// возвращает первые maxUsers и ErrTooManyUsers. maxUsers <= 0 - без ограничения
+func (srv *SearchClient) CollectUsers(ctx context.Context, req SearchRequest, maxUsers int) ([]User, error) {
+	pages := srv.Paginate(ctx, req, true)
+	defer pages.Close()
+
+	var users []User
+	for pages.Next() {
+		if maxUsers > 0 && len(users) == maxUsers {
+			return users, ErrTooManyUsers
+		}
+		users = append(users, pages.User())
+	}
+
+	return users, pages.Err()
+}
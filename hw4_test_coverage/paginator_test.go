@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func allUsers(t *testing.T, client *SearchClient, req SearchRequest) []User {
+	var users []User
+	for {
+		resp, err := client.FindUsers(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		users = append(users, resp.Users...)
+		if !resp.NextPage {
+			return users
+		}
+		req.Offset += len(resp.Users)
+	}
+}
+
+func Test_Paginator_Walks_All_Pages(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	for _, req := range []SearchRequest{
+		{Limit: 10, OrderField: "Age", OrderBy: OrderByDesc},
+		{Limit: 7, Offset: 3, Query: "a"},
+		{Query: "no such text"},
+		{Limit: 100},
+	} {
+		want := allUsers(t, client, req)
+
+		for _, prefetch := range []bool{false, true} {
+			pages := client.Paginate(context.Background(), req, prefetch)
+
+			var got []User
+			for pages.Next() {
+				got = append(got, pages.User())
+			}
+			pages.Close()
+
+			if err := pages.Err(); err != nil {
+				t.Fatalf("%+v prefetch=%v: unexpected error: %s", req, prefetch, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%+v prefetch=%v: got %d users, want %d", req, prefetch, len(got), len(want))
+			}
+		}
+	}
+}
+
+func Test_Paginator_Stops_On_Error(t *testing.T) {
+	for _, prefetch := range []bool{false, true} {
+		var requests int32
+		dataset := newDatasetHandler(t)
+		// первая страница отдаётся, на второй токен перестаёт подходить
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if r.URL.Query().Get("offset") != "0" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			dataset.ServeHTTP(w, r)
+		}))
+
+		client := &SearchClient{
+			AccessToken: testAccessToken,
+			URL:         server.URL,
+		}
+		pages := client.Paginate(context.Background(), SearchRequest{Limit: 10}, prefetch)
+
+		count := 0
+		for pages.Next() {
+			count++
+		}
+		pages.Close()
+		server.Close()
+
+		if count != 10 {
+			t.Errorf("prefetch=%v: got %d users before error, want 10", prefetch, count)
+		}
+		if !errors.Is(pages.Err(), ErrBadToken) {
+			t.Errorf("prefetch=%v: expected ErrBadToken, got: %v", prefetch, pages.Err())
+		}
+		if pages.Next() {
+			t.Errorf("prefetch=%v: Next after error", prefetch)
+		}
+		if requests := atomic.LoadInt32(&requests); requests != 2 {
+			t.Errorf("prefetch=%v: got %d requests, want 2", prefetch, requests)
+		}
+	}
+}
+
+func Test_Paginator_Stops_On_Context_Cancel(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pages := client.Paginate(ctx, SearchRequest{Limit: 5}, true)
+	defer pages.Close()
+
+	count := 0
+	for pages.Next() {
+		count++
+		if count == 5 {
+			cancel()
+		}
+	}
+
+	if count != 5 {
+		t.Errorf("got %d users, want 5", count)
+	}
+	if !errors.Is(pages.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", pages.Err())
+	}
+}
+
+func Test_CollectUsers(t *testing.T) {
+	server := newDatasetServer(t)
+	defer server.Close()
+
+	client := &SearchClient{
+		AccessToken: testAccessToken,
+		URL:         server.URL,
+	}
+
+	users, err := client.CollectUsers(context.Background(), SearchRequest{}, 35)
+	if err != nil || len(users) != 35 {
+		t.Errorf("got %d users and %v, want 35 users", len(users), err)
+	}
+
+	users, err = client.CollectUsers(context.Background(), SearchRequest{Limit: 10}, 20)
+	if err != ErrTooManyUsers || len(users) != 20 {
+		t.Errorf("got %d users and %v, want 20 users and ErrTooManyUsers", len(users), err)
+	}
+
+	for _, maxUsers := range []int{0, -1} {
+		users, err = client.CollectUsers(context.Background(), SearchRequest{Limit: 10}, maxUsers)
+		if err != nil || len(users) != 35 {
+			t.Errorf("maxUsers %d: got %d users and %v, want all 35 users", maxUsers, len(users), err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var ops = []string{"!=", "!~", "<=", ">=", "=", "<", ">", "~"}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(input); {
+		c := input[pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", pos})
+			pos++
+
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", pos})
+			pos++
+
+		case c == '"':
+			end := closingQuote(input, pos)
+			if end < 0 {
+				return nil, &SyntaxError{pos, "unterminated string"}
+			}
+			text, err := strconv.Unquote(input[pos : end+1])
+			if err != nil {
+				return nil, &SyntaxError{pos, "bad string: " + err.Error()}
+			}
+			tokens = append(tokens, token{tokString, text, pos})
+			pos = end + 1
+
+		case strings.ContainsRune("=!<>~", rune(c)):
+			op := ""
+			for _, candidate := range ops {
+				if strings.HasPrefix(input[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{pos, "unknown operator"}
+			}
+			tokens = append(tokens, token{tokOp, op, pos})
+			pos += len(op)
+
+		default:
+			start := pos
+			for pos < len(input) && isWordChar(input[pos]) {
+				pos++
+			}
+			if pos == start {
+				return nil, &SyntaxError{pos, "unexpected " + strconv.QuoteRune(rune(c))}
+			}
+			tokens = append(tokens, token{tokWord, input[start:pos], start})
+		}
+	}
+
+	return append(tokens, token{tokEOF, "", len(input)}), nil
+}
+
+func closingQuote(input string, start int) int {
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func isWordChar(c byte) bool {
+	if c >= 0x80 {
+		// не-ASCII буквы тоже допустимы в значениях без кавычек
+		return true
+	}
+	return unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte("_.@-+'", c) >= 0
+}
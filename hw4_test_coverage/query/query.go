@@ -0,0 +1,338 @@
+// Package query разбирает фильтры вида
+//
+//	age>30 and (gender=female or not name~"Wolf")
+//
+// и ключи сортировки вида "age,-name".
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Int Kind = iota
+	String
+)
+
+// Schema - поля, по которым можно фильтровать и сортировать, в нижнем регистре
+type Schema map[string]Kind
+
+// Record - запись, к которой применяется фильтр. Имена полей передаются
+// в нижнем регистре, как в Schema
+type Record interface {
+	Int(field string) int
+	String(field string) string
+}
+
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("at %d: %s", e.Pos, e.Msg)
+}
+
+// Filter - разобранный фильтр. Пустой фильтр подходит под любую запись
+type Filter struct {
+	root node
+}
+
+func (f *Filter) Match(r Record) bool {
+	return f.root == nil || f.root.match(r)
+}
+
+// Uses сообщает, упоминается ли поле в фильтре
+func (f *Filter) Uses(field string) bool {
+	return f.root != nil && f.root.uses(field)
+}
+
+// Parse разбирает фильтр. Операции: = != < <= > >= для всех полей,
+// ~ (содержит) и !~ для строковых; and, or, not и скобки
+func Parse(filter string, schema Schema) (*Filter, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, schema: schema}
+	if p.peek().kind == tokEOF {
+		return &Filter{}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &SyntaxError{tok.pos, fmt.Sprintf("unexpected %q", tok.text)}
+	}
+
+	return &Filter{root}, nil
+}
+
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort разбирает список полей через запятую, "-" перед полем означает
+// сортировку по убыванию
+func ParseSort(keys string, schema Schema) ([]SortKey, error) {
+	var result []SortKey
+	pos := 0
+	for _, key := range strings.Split(keys, ",") {
+		field := strings.TrimSpace(key)
+		sortKey := SortKey{}
+
+		switch {
+		case strings.HasPrefix(field, "-"):
+			sortKey.Desc = true
+			field = field[1:]
+		case strings.HasPrefix(field, "+"):
+			field = field[1:]
+		}
+
+		field = strings.ToLower(field)
+		if _, ok := schema[field]; !ok {
+			return nil, &SyntaxError{pos, fmt.Sprintf("unknown field %q", strings.TrimSpace(key))}
+		}
+		sortKey.Field = field
+		result = append(result, sortKey)
+
+		pos += len(key) + 1
+	}
+	return result, nil
+}
+
+// Compare сравнивает записи по ключам сортировки
+func Compare(a, b Record, keys []SortKey, schema Schema) int {
+	for _, key := range keys {
+		var c int
+		if schema[key.Field] == Int {
+			c = compareInts(a.Int(key.Field), b.Int(key.Field))
+		} else {
+			c = strings.Compare(a.String(key.Field), b.String(key.Field))
+		}
+
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type node interface {
+	match(r Record) bool
+	uses(field string) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(r Record) bool { return n.left.match(r) && n.right.match(r) }
+
+func (n andNode) uses(field string) bool { return n.left.uses(field) || n.right.uses(field) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(r Record) bool { return n.left.match(r) || n.right.match(r) }
+
+func (n orNode) uses(field string) bool { return n.left.uses(field) || n.right.uses(field) }
+
+type notNode struct{ operand node }
+
+func (n notNode) match(r Record) bool { return !n.operand.match(r) }
+
+func (n notNode) uses(field string) bool { return n.operand.uses(field) }
+
+type intCompare struct {
+	field string
+	op    string
+	value int
+}
+
+func (n intCompare) match(r Record) bool {
+	return compared(n.op, compareInts(r.Int(n.field), n.value))
+}
+
+func (n intCompare) uses(field string) bool { return n.field == field }
+
+type stringCompare struct {
+	field string
+	op    string
+	value string
+}
+
+func (n stringCompare) match(r Record) bool {
+	switch n.op {
+	case "~":
+		return strings.Contains(r.String(n.field), n.value)
+	case "!~":
+		return !strings.Contains(r.String(n.field), n.value)
+	}
+	return compared(n.op, strings.Compare(r.String(n.field), n.value))
+}
+
+func (n stringCompare) uses(field string) bool { return n.field == field }
+
+func compared(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// maxNestingDepth ограничивает вложенность скобок и not, чтобы разбор
+// длинной цепочки "((((" не переполнил стек
+const maxNestingDepth = 100
+
+type parser struct {
+	tokens []token
+	pos    int
+	schema Schema
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) keyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokWord && strings.EqualFold(tok.text, word)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.keyword("not") || p.peek().kind == tokLParen {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxNestingDepth {
+			return nil, &SyntaxError{p.peek().pos, "exceeded max depth"}
+		}
+	}
+
+	if p.keyword("not") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokRParen {
+			return nil, &SyntaxError{tok.pos, "expected )"}
+		}
+		return inner, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	fieldTok := p.next()
+	if fieldTok.kind != tokWord {
+		return nil, &SyntaxError{fieldTok.pos, "expected field name"}
+	}
+	field := strings.ToLower(fieldTok.text)
+	kind, ok := p.schema[field]
+	if !ok {
+		return nil, &SyntaxError{fieldTok.pos, fmt.Sprintf("unknown field %q", fieldTok.text)}
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokOp {
+		return nil, &SyntaxError{opTok.pos, "expected comparison after " + fieldTok.text}
+	}
+	if kind == Int && (opTok.text == "~" || opTok.text == "!~") {
+		return nil, &SyntaxError{opTok.pos, fmt.Sprintf("%s is not allowed for %s", opTok.text, fieldTok.text)}
+	}
+
+	valueTok := p.next()
+	if valueTok.kind != tokWord && valueTok.kind != tokString {
+		return nil, &SyntaxError{valueTok.pos, "expected value"}
+	}
+
+	if kind == Int {
+		value, err := strconv.Atoi(valueTok.text)
+		if err != nil {
+			return nil, &SyntaxError{valueTok.pos, fmt.Sprintf("%s expects a number", fieldTok.text)}
+		}
+		return intCompare{field, opTok.text, value}, nil
+	}
+	return stringCompare{field, opTok.text, valueTok.text}, nil
+}
@@ -0,0 +1,155 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSchema = Schema{
+	"age":    Int,
+	"name":   String,
+	"gender": String,
+}
+
+type record struct {
+	age    int
+	name   string
+	gender string
+}
+
+func (r record) Int(field string) int {
+	return r.age
+}
+
+func (r record) String(field string) string {
+	if field == "name" {
+		return r.name
+	}
+	return r.gender
+}
+
+func TestFilterMatch(t *testing.T) {
+	boyd := record{22, "Boyd Wolf", "male"}
+	hilda := record{21, "Hilda Mayer", "female"}
+	brooks := record{25, "Brooks Aguilar", "male"}
+
+	tests := []struct {
+		filter string
+		want   []bool
+	}{
+		{"", []bool{true, true, true}},
+		{"  ", []bool{true, true, true}},
+		{"age>21", []bool{true, false, true}},
+		{"AGE >= 21 and age<=22", []bool{true, true, false}},
+		{"gender=female", []bool{false, true, false}},
+		{"gender!=female", []bool{true, false, true}},
+		{`name~"Wolf"`, []bool{true, false, false}},
+		{"name!~Wolf", []bool{false, true, true}},
+		{`name="Hilda Mayer"`, []bool{false, true, false}},
+		{"gender=male and age>22 or name~Hilda", []bool{false, true, true}},
+		{"gender=male and (age>22 or name~Hilda)", []bool{false, false, true}},
+		{"not gender=male or age=22", []bool{true, true, false}},
+		{"not (gender=male or age=22)", []bool{false, true, false}},
+		{"age>20 AND not not name~B", []bool{true, false, true}},
+	}
+
+	for _, test := range tests {
+		filter, err := Parse(test.filter, testSchema)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.filter, err)
+			continue
+		}
+
+		got := []bool{filter.Match(boyd), filter.Match(hilda), filter.Match(brooks)}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		pos    int
+	}{
+		{"height>1", 0},
+		{"age>x", 4},
+		{"age~1", 3},
+		{"age", 3},
+		{"age>", 4},
+		{"(age>1", 6},
+		{"age>1)", 5},
+		{"age>1 and", 9},
+		{`name="Wolf`, 5},
+		{"name=#", 5},
+		{"age>1 age>2", 6},
+		{"name=!x", 5},
+		{strings.Repeat("(", 5000) + "age>1" + strings.Repeat(")", 5000), maxNestingDepth},
+		{strings.Repeat("not ", 5000) + "age>1", 4 * maxNestingDepth},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.filter, testSchema)
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected SyntaxError, got %v", test.filter, err)
+			continue
+		}
+		if syntaxErr.Pos != test.pos {
+			t.Errorf("%q: error at %d, want %d: %s", test.filter, syntaxErr.Pos, test.pos, err)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	keys, err := ParseSort("gender, -AGE,+name", testSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []SortKey{{"gender", false}, {"age", true}, {"name", false}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+
+	a := record{22, "Boyd Wolf", "male"}
+	b := record{25, "Brooks Aguilar", "male"}
+	if c := Compare(a, b, keys, testSchema); c != 1 {
+		t.Errorf("Compare = %d, want 1", c)
+	}
+	if c := Compare(a, a, keys, testSchema); c != 0 {
+		t.Errorf("Compare = %d, want 0", c)
+	}
+
+	for _, bad := range []string{"", "age,", "-", "height"} {
+		if _, err := ParseSort(bad, testSchema); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestFilterUses(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", false},
+		{"age>21", false},
+		{"NAME~Wolf", true},
+		{"age>21 and (gender=male or not name=Boyd)", true},
+		{"age>21 or gender=male", false},
+	}
+
+	for _, test := range tests {
+		filter, err := Parse(test.filter, testSchema)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", test.filter, err)
+		}
+		if got := filter.Uses("name"); got != test.want {
+			t.Errorf("%q: Uses(name) = %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package searcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/token"
+)
+
+const (
+	// пакетный поиск - POST на путь поиска с этим окончанием
+	batchSuffix = "/batch"
+	// MaxBatch - сколько запросов можно прислать в одном пакете
+	MaxBatch     = 50
+	maxBatchBody = 1 << 20
+)
+
+var ErrBadBatch = errors.New("ErrorBadBatch")
+
+// BatchRequest - тело пакетного поиска: параметры запросов в том виде,
+// в каком они были бы в урле GET-запроса
+type BatchRequest struct {
+	Requests []string `json:"requests"`
+}
+
+// BatchResult - статус и тело, которые вернул бы GET-запрос
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// serveBatch выполняет запросы пакета параллельно, ответы идут в том же порядке
+func (srv *Server) serveBatch(w http.ResponseWriter, r *http.Request, claims *token.Claims) {
+	var batch BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&batch); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse(&requestError{ErrBadBatch, err}))
+		return
+	}
+	if len(batch.Requests) == 0 || len(batch.Requests) > MaxBatch {
+		err := fmt.Errorf("batch must have from 1 to %d requests, got %d", MaxBatch, len(batch.Requests))
+		writeJSON(w, http.StatusBadRequest, errorResponse(&requestError{ErrBadBatch, err}))
+		return
+	}
+
+	results := make([]BatchResult, len(batch.Requests))
+	var wg sync.WaitGroup
+	for i, query := range batch.Requests {
+		wg.Add(1)
+		go func(result *BatchResult, query string) {
+			defer wg.Done()
+
+			var status int
+			var body interface{}
+			if values, err := url.ParseQuery(query); err != nil {
+				status, body = http.StatusBadRequest, errorResponse(&requestError{ErrBadBatch, err})
+			} else {
+				status, body = srv.answer(values, claims)
+			}
+
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				status, encoded = http.StatusInternalServerError, []byte("null")
+			}
+			*result = BatchResult{Status: status, Body: encoded}
+		}(&results[i], query)
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, BatchResponse{Results: results})
+}
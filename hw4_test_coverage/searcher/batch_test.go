@@ -0,0 +1,70 @@
+package searcher
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	server := httptest.NewServer(loadTestServer(t))
+	defer server.Close()
+
+	do := func(method, path, body string) (int, []byte) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		req.Header.Set("AccessToken", testToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, respBody
+	}
+
+	queries := []string{
+		"limit=3&order_field=Age&order_by=-1",
+		"limit=1&order_field=Salary",
+		"limit=2&cursor=&fields=Id",
+		"limit=%zz",
+	}
+	encoded, _ := json.Marshal(BatchRequest{Requests: queries})
+
+	status, body := do("POST", "/batch", string(encoded))
+	if status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	var resp BatchResponse
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Results) != len(queries) {
+		t.Fatalf("unexpected response %s", body)
+	}
+
+	for i, query := range queries[:3] {
+		wantStatus, wantBody := do("GET", "/?"+query, "")
+		if resp.Results[i].Status != wantStatus || string(resp.Results[i].Body) != string(wantBody) {
+			t.Errorf("%s: got %d %s, want %d %s", query, resp.Results[i].Status, resp.Results[i].Body, wantStatus, wantBody)
+		}
+	}
+	if last := resp.Results[3]; last.Status != http.StatusBadRequest || !strings.Contains(string(last.Body), "ErrorBadBatch") {
+		t.Errorf("bad query: got %d %s", last.Status, last.Body)
+	}
+
+	tests := []struct {
+		method string
+		body   string
+		status int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "{", http.StatusBadRequest},
+		{"POST", `{"requests":[]}`, http.StatusBadRequest},
+		{"POST", `{"requests":["limit=1"` + strings.Repeat(`,"limit=1"`, MaxBatch) + `]}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if status, body := do(test.method, "/batch", test.body); status != test.status {
+			t.Errorf("%s %.30s: got %d %s, want %d", test.method, test.body, status, body, test.status)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package searcher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/query"
+)
+
+var ErrBadCursor = errors.New("ErrorBadCursor")
+
+// Page - страница выдачи в режиме курсоров
+type Page struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursorSchema - UserSchema и позиция пользователя в выдаче "как встретилось"
+var cursorSchema = func() query.Schema {
+	schema := query.Schema{"pos": query.Int}
+	for field, kind := range UserSchema {
+		schema[field] = kind
+	}
+	return schema
+}()
+
+type positionedUser struct {
+	*User
+	pos int
+}
+
+func (u positionedUser) Int(field string) int {
+	if field == "pos" {
+		return u.pos
+	}
+	return userRecord{u.User}.Int(field)
+}
+
+func (u positionedUser) String(field string) string {
+	return userRecord{u.User}.String(field)
+}
+
+// cursor - ключ сортировки последнего пользователя страницы и отпечаток
+// запроса, для которого он выдан
+type cursor struct {
+	Request []byte            `json:"r"`
+	Ints    map[string]int    `json:"i,omitempty"`
+	Strings map[string]string `json:"s,omitempty"`
+}
+
+func (c *cursor) Int(field string) int {
+	return c.Ints[field]
+}
+
+func (c *cursor) String(field string) string {
+	return c.Strings[field]
+}
+
+// SearchPage - Search в режиме курсоров: вместо Offset выдача продолжается
+// после пользователя, закодированного в req.Cursor, поэтому вставки между
+// запросами не сдвигают страницы. Offset пропускает пользователей после курсора
+func (srv *Server) SearchPage(req Request) (*Page, error) {
+	if req.Limit < 0 {
+		return nil, ErrBadLimit
+	}
+	if req.Offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	matches, err := matcher(req)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := cursorKeys(req)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := requestFingerprint(req, keys)
+
+	var after *cursor
+	if req.Cursor != "" {
+		if after, err = srv.decodeCursor(req.Cursor); err != nil {
+			return nil, &requestError{ErrBadCursor, err}
+		}
+		if !bytes.Equal(after.Request, fingerprint) {
+			return nil, &requestError{ErrBadCursor, errors.New("cursor was issued for another request")}
+		}
+	}
+
+	var found []positionedUser
+	for i := range srv.users {
+		user := positionedUser{&srv.users[i], i}
+		if !matches(user.User) {
+			continue
+		}
+		if after != nil && compareUsers(user, after, keys) <= 0 {
+			continue
+		}
+		found = append(found, user)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return compareUsers(found[i], found[j], keys) < 0
+	})
+
+	if req.Offset < len(found) {
+		found = found[req.Offset:]
+	} else {
+		found = nil
+	}
+
+	page := &Page{Users: make([]User, 0, srv.pageCapacity(req.Limit))}
+	for i := 0; i < len(found) && i < req.Limit; i++ {
+		page.Users = append(page.Users, *found[i].User)
+	}
+	if req.Limit > 0 && len(found) > req.Limit {
+		page.NextCursor = srv.encodeCursor(newCursor(fingerprint, found[req.Limit-1], keys))
+	}
+
+	return page, nil
+}
+
+func compareUsers(a, b query.Record, keys []query.SortKey) int {
+	return query.Compare(a, b, keys, cursorSchema)
+}
+
+// cursorKeys задаёт полный порядок выдачи, чтобы ключ последнего пользователя
+// однозначно определял, откуда продолжать. При равенстве полей порядок тот же,
+// что и в Search
+func cursorKeys(req Request) ([]query.SortKey, error) {
+	if req.Sort != "" {
+		keys, err := parseSort(req)
+		if err != nil {
+			return nil, err
+		}
+		return append(keys, query.SortKey{Field: "id"}), nil
+	}
+
+	field := req.OrderField
+	if field == "" {
+		field = "Name"
+	}
+	if field == OrderFieldRelevance {
+		return nil, errRelevanceCursor
+	}
+	if _, ok := orderLess[field]; !ok {
+		return nil, ErrBadOrderField
+	}
+	field = strings.ToLower(field)
+
+	switch req.OrderBy {
+	case OrderByAsc:
+		return []query.SortKey{{Field: field}, {Field: "id"}}, nil
+	case OrderByDesc:
+		return []query.SortKey{{Field: field, Desc: true}, {Field: "id", Desc: true}}, nil
+	case OrderByAsIs:
+		return []query.SortKey{{Field: "pos"}}, nil
+	}
+	return nil, ErrBadOrderBy
+}
+
+func requestFingerprint(req Request, keys []query.SortKey) []byte {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q %q %v %v", req.Query, req.Filter, keys, req.HideAbout)
+	return hash.Sum(nil)[:8]
+}
+
+func newCursor(fingerprint []byte, last positionedUser, keys []query.SortKey) *cursor {
+	c := &cursor{
+		Request: fingerprint,
+		Ints:    make(map[string]int),
+		Strings: make(map[string]string),
+	}
+	for _, key := range keys {
+		if cursorSchema[key.Field] == query.Int {
+			c.Ints[key.Field] = last.Int(key.Field)
+		} else {
+			c.Strings[key.Field] = last.String(key.Field)
+		}
+	}
+	return c
+}
+
+func (srv *Server) encodeCursor(c *cursor) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(srv.signCursor(payload))
+}
+
+func (srv *Server) decodeCursor(token string) (*cursor, error) {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return nil, errors.New("malformed cursor")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(token[:dot])
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	if !hmac.Equal(signature, srv.signCursor(payload)) {
+		return nil, errors.New("bad cursor signature")
+	}
+
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	return &c, nil
+}
+
+func (srv *Server) signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, srv.cursorSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
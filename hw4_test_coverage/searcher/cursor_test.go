@@ -0,0 +1,189 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSearchPageMatchesSearch(t *testing.T) {
+	srv := loadTestServer(t)
+
+	for _, req := range []Request{
+		{OrderField: "Age", OrderBy: OrderByAsc},
+		{OrderField: "Age", OrderBy: OrderByDesc, Query: "a"},
+		{OrderBy: OrderByAsIs, Filter: "gender=male"},
+		{Sort: "gender,-name"},
+		{Offset: 3, OrderField: "Id", OrderBy: OrderByDesc},
+	} {
+		req.Limit = 100
+		want, err := srv.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Limit = 4
+		var got []User
+		for {
+			page, err := srv.SearchPage(req)
+			if err != nil {
+				t.Fatalf("%+v: %s", req, err)
+			}
+			got = append(got, page.Users...)
+			if page.NextCursor == "" {
+				break
+			}
+			req.Cursor = page.NextCursor
+			req.Offset = 0
+		}
+
+		if !reflect.DeepEqual(ids(got), ids(want)) {
+			t.Errorf("%+v:\ngot  %v\nwant %v", req, ids(got), ids(want))
+		}
+	}
+}
+
+// walkWithInserts проходит выдачу страницами по 4, добавляя перед каждой
+// следующей страницей пользователей, часть которых попадает в уже
+// пройденную часть выдачи
+func walkWithInserts(t *testing.T, srv *Server, req Request, cursorMode bool) []User {
+	req.Limit = 4
+	newId := 1000
+
+	var result []User
+	for page := 0; page < 100; page++ {
+		var users []User
+		var next string
+		if cursorMode {
+			resp, err := srv.SearchPage(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			users, next = resp.Users, resp.NextCursor
+		} else {
+			var err error
+			if users, err = srv.Search(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result = append(result, users...)
+
+		if cursorMode && next == "" || !cursorMode && len(users) < req.Limit {
+			return result
+		}
+		if cursorMode {
+			req.Cursor = next
+		} else {
+			req.Offset += len(users)
+		}
+
+		for _, user := range []User{
+			{Id: newId, Name: "Aaron Aardvark", Age: 1, Gender: "female"},
+			{Id: newId + 1, Name: "Zed Zulu", Age: 99, Gender: "male"},
+		} {
+			if err := srv.Insert(user); err != nil {
+				t.Fatal(err)
+			}
+		}
+		newId += 2
+	}
+
+	t.Fatal("too many pages")
+	return nil
+}
+
+func TestSearchPageConsistentWithInserts(t *testing.T) {
+	for _, req := range []Request{
+		{OrderField: "Age", OrderBy: OrderByAsc},
+		{OrderField: "Name", OrderBy: OrderByDesc},
+		{OrderBy: OrderByAsIs},
+		{Sort: "gender,-age"},
+	} {
+		t.Run(fmt.Sprintf("%+v", req), func(t *testing.T) {
+			srv := loadTestServer(t)
+			original := append([]User(nil), srv.users...)
+
+			got := walkWithInserts(t, srv, req, true)
+
+			seen := make(map[int]int)
+			for _, user := range got {
+				seen[user.Id]++
+				if seen[user.Id] > 1 {
+					t.Errorf("user %d returned twice", user.Id)
+				}
+			}
+			for _, user := range original {
+				if seen[user.Id] != 1 {
+					t.Errorf("user %d was skipped", user.Id)
+				}
+			}
+
+			// пользователи выдаются в порядке, который был бы у выдачи целиком
+			keys, _ := cursorKeys(req)
+			positions := make(map[int]int)
+			for i, user := range srv.users {
+				positions[user.Id] = i
+			}
+			for i := 1; i < len(got); i++ {
+				a := positionedUser{&got[i-1], positions[got[i-1].Id]}
+				b := positionedUser{&got[i], positions[got[i].Id]}
+				if c := compareUsers(a, b, keys); c >= 0 {
+					t.Errorf("user %d is before %d", a.Id, b.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestOffsetPagesShiftWithInserts(t *testing.T) {
+	srv := loadTestServer(t)
+	got := walkWithInserts(t, srv, Request{OrderField: "Age", OrderBy: OrderByAsc}, false)
+
+	seen := make(map[int]bool)
+	duplicates := 0
+	for _, user := range got {
+		if seen[user.Id] {
+			duplicates++
+		}
+		seen[user.Id] = true
+	}
+	if duplicates == 0 {
+		t.Errorf("expected offset pagination to repeat users, so the cursor test means something")
+	}
+}
+
+func TestBadCursor(t *testing.T) {
+	srv := loadTestServer(t)
+
+	page, err := srv.SearchPage(Request{Limit: 2, OrderField: "Age", OrderBy: OrderByAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected next cursor")
+	}
+
+	other := loadTestServer(t)
+	other.SetCursorSecret([]byte("other secret"))
+
+	tampered := []byte(page.NextCursor)
+	tampered[3] ^= 1
+
+	tests := []struct {
+		name string
+		srv  *Server
+		req  Request
+	}{
+		{"tampered", srv, Request{Limit: 2, OrderField: "Age", OrderBy: OrderByAsc, Cursor: string(tampered)}},
+		{"garbage", srv, Request{Limit: 2, OrderField: "Age", OrderBy: OrderByAsc, Cursor: "garbage"}},
+		{"another order", srv, Request{Limit: 2, OrderField: "Age", OrderBy: OrderByDesc, Cursor: page.NextCursor}},
+		{"another query", srv, Request{Limit: 2, OrderField: "Age", OrderBy: OrderByAsc, Query: "a", Cursor: page.NextCursor}},
+		{"another secret", other, Request{Limit: 2, OrderField: "Age", OrderBy: OrderByAsc, Cursor: page.NextCursor}},
+	}
+	for _, test := range tests {
+		if _, err := test.srv.SearchPage(test.req); !errors.Is(err, ErrBadCursor) {
+			t.Errorf("%s: expected ErrBadCursor, got %v", test.name, err)
+		}
+	}
+}
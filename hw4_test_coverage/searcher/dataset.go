@@ -0,0 +1,89 @@
+package searcher
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/model"
+)
+
+type User = model.User
+
+type dataset struct {
+	XMLName xml.Name `xml:"root"`
+	Rows    []row    `xml:"row"`
+}
+
+type row struct {
+	Id            int    `xml:"id"`
+	Guid          string `xml:"guid"`
+	IsActive      bool   `xml:"isActive"`
+	Balance       string `xml:"balance"`
+	Picture       string `xml:"picture"`
+	Age           int    `xml:"age"`
+	EyeColor      string `xml:"eyeColor"`
+	FirstName     string `xml:"first_name"`
+	LastName      string `xml:"last_name"`
+	Gender        string `xml:"gender"`
+	Company       string `xml:"company"`
+	Email         string `xml:"email"`
+	Phone         string `xml:"phone"`
+	Address       string `xml:"address"`
+	About         string `xml:"about"`
+	Registered    string `xml:"registered"`
+	FavoriteFruit string `xml:"favoriteFruit"`
+}
+
+// LoadDataset читает пользователей из dataset.xml
+func LoadDataset(path string) ([]User, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadDataset(file)
+}
+
+func ReadDataset(r io.Reader) ([]User, error) {
+	var dataset dataset
+	if err := xml.NewDecoder(r).Decode(&dataset); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(dataset.Rows))
+	for _, row := range dataset.Rows {
+		balance, err := model.ParseMoney(row.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("user %d: %s", row.Id, err)
+		}
+		registered, err := time.Parse(model.RegisteredLayout, row.Registered)
+		if err != nil {
+			return nil, fmt.Errorf("user %d: bad registered: %s", row.Id, err)
+		}
+
+		users = append(users, User{
+			Id:            row.Id,
+			Guid:          row.Guid,
+			IsActive:      row.IsActive,
+			Balance:       balance,
+			Picture:       row.Picture,
+			Name:          row.FirstName + " " + row.LastName,
+			Age:           row.Age,
+			EyeColor:      row.EyeColor,
+			Gender:        row.Gender,
+			Company:       row.Company,
+			Email:         row.Email,
+			Phone:         row.Phone,
+			Address:       row.Address,
+			About:         row.About,
+			Registered:    registered,
+			FavoriteFruit: row.FavoriteFruit,
+		})
+	}
+
+	return users, nil
+}
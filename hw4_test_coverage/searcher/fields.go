@@ -0,0 +1,91 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/model"
+)
+
+var ErrBadFields = errors.New("ErrorBadFields")
+
+// parseFields разбирает параметр fields: имена полей User через запятую,
+// без учёта регистра
+func parseFields(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		field, ok := canonicalField(strings.TrimSpace(name))
+		if !ok {
+			return nil, &requestError{ErrBadFields, fmt.Errorf("unknown field %q", name)}
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func canonicalField(name string) (string, bool) {
+	for _, field := range model.Fields {
+		if strings.EqualFold(field, name) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// project оставляет у пользователей только поля fields
+func project(users []User, fields []string) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(users))
+	for i := range users {
+		projected := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			projected[field], _ = users[i].Field(field)
+		}
+		result = append(result, projected)
+	}
+	return result
+}
+
+// responseBody - тело ответа: список пользователей или, в режиме курсоров,
+// страница с next_cursor. Для OrderFieldRelevance у пользователей есть ещё
+// Score и Snippet
+func responseBody(users []User, page *Page, fields []string, relevance []Relevance) interface{} {
+	var body interface{} = users
+	switch {
+	case len(fields) > 0:
+		projected := project(users, fields)
+		for i := range relevance {
+			projected[i]["Score"] = relevance[i].Score
+			projected[i]["Snippet"] = relevance[i].Snippet
+		}
+		body = projected
+	case relevance != nil:
+		scored := make([]scoredUser, len(users))
+		for i := range users {
+			scored[i] = scoredUser{&users[i], relevance[i]}
+		}
+		body = scored
+	}
+	if page == nil {
+		return body
+	}
+
+	return struct {
+		Users      interface{} `json:"users"`
+		NextCursor string      `json:"next_cursor,omitempty"`
+	}{body, page.NextCursor}
+}
+
+// scoredUser - пользователь с полями Relevance в одном JSON-объекте
+type scoredUser struct {
+	*User
+	Relevance
+}
@@ -0,0 +1,132 @@
+package searcher
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/fulltext"
+)
+
+// OrderFieldRelevance - сортировка по BM25 слов Query в Name и About, лучшие
+// первыми. Query в этом режиме - слова, а не подстрока: подходят пользователи,
+// у которых есть хотя бы одно из них. OrderBy не учитывается
+const OrderFieldRelevance = "relevance"
+
+const (
+	// совпадение в Name весит больше, чем в длинном About
+	nameBoost    = 2
+	snippetWords = 12
+)
+
+// Relevance - оценка пользователя и HTML-фрагмент текста с подсвеченными словами
+// Query, см. fulltext.Snippet
+type Relevance struct {
+	Score   float64
+	Snippet string
+}
+
+// textIndex - индексы Name и About, документ - позиция пользователя в Server.users
+type textIndex struct {
+	name  *fulltext.Index
+	about *fulltext.Index
+}
+
+func newTextIndex(users []User) *textIndex {
+	text := &textIndex{name: fulltext.NewIndex(), about: fulltext.NewIndex()}
+	for i := range users {
+		text.add(&users[i])
+	}
+	return text
+}
+
+func (t *textIndex) add(user *User) {
+	t.name.Add(user.Name)
+	t.about.Add(user.About)
+}
+
+// SearchRelevance ищет как Search с OrderFieldRelevance и возвращает вместе
+// со страницей оценки и сниппеты, посчитанные под той же блокировкой, что и
+// порядок: Insert между ними поменял бы статистику BM25
+func (srv *Server) SearchRelevance(req Request) ([]User, []Relevance, error) {
+	if req.Limit < 0 {
+		return nil, nil, ErrBadLimit
+	}
+	if req.Offset < 0 {
+		return nil, nil, ErrBadOffset
+	}
+
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.searchRelevance(req)
+}
+
+// searchRelevance ищет по словам Query, вызывается под srv.mu
+func (srv *Server) searchRelevance(req Request) ([]User, []Relevance, error) {
+	// Query здесь - слова для индекса, а не подстрока для matcher
+	filterReq := req
+	filterReq.Query = ""
+	matches, err := matcher(filterReq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type hit struct {
+		user  *User
+		score float64
+	}
+	var hits []hit
+
+	terms := fulltext.Terms(req.Query)
+	if len(terms) == 0 {
+		// без слов все подходят одинаково
+		for i := range srv.users {
+			if matches(&srv.users[i]) {
+				hits = append(hits, hit{&srv.users[i], 0})
+			}
+		}
+	} else {
+		scores := srv.text.name.Search(terms)
+		for doc, score := range scores {
+			scores[doc] = nameBoost * score
+		}
+		if !req.HideAbout {
+			for doc, score := range srv.text.about.Search(terms) {
+				scores[doc] += score
+			}
+		}
+		for doc, score := range scores {
+			if matches(&srv.users[doc]) {
+				hits = append(hits, hit{&srv.users[doc], score})
+			}
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].user.Id < hits[j].user.Id
+	})
+
+	users := make([]User, 0, srv.pageCapacity(req.Limit))
+	relevance := make([]Relevance, 0, cap(users))
+	for i := req.Offset; i < len(hits) && len(users) < req.Limit; i++ {
+		users = append(users, *hits[i].user)
+		relevance = append(relevance, Relevance{Score: hits[i].score, Snippet: snippet(hits[i].user, terms, req.HideAbout)})
+	}
+	return users, relevance, nil
+}
+
+// snippet строит сниппет из About, если слова Query есть там и он доступен,
+// иначе из Name
+func snippet(user *User, terms []string, hideAbout bool) string {
+	if !hideAbout {
+		if text := fulltext.Snippet(user.About, terms, snippetWords); text != "" {
+			return text
+		}
+	}
+	return fulltext.Snippet(user.Name, terms, snippetWords)
+}
+
+var errRelevanceCursor = &requestError{ErrBadOrderField, errors.New("relevance order does not support cursors")}
@@ -0,0 +1,114 @@
+package searcher
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/fulltext"
+)
+
+func TestSearchRelevance(t *testing.T) {
+	srv := loadTestServer(t)
+
+	req := Request{Limit: 35, Query: "Boyd cillum", OrderField: OrderFieldRelevance}
+	users, err := srv.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Boyd есть в имени и cillum в About только у первого пользователя
+	if len(users) != 18 || users[0].Id != 0 {
+		t.Fatalf("got %d users, first %d", len(users), users[0].Id)
+	}
+
+	scored, relevance, err := srv.SearchRelevance(req)
+	if err != nil || !equalIds(scored, users) || len(relevance) != len(users) {
+		t.Fatalf("SearchRelevance: got %v, %d scores, want %v (%v)", ids(scored), len(relevance), ids(users), err)
+	}
+	terms := fulltext.Terms(req.Query)
+	for i, user := range users {
+		if i > 0 && relevance[i].Score > relevance[i-1].Score {
+			t.Errorf("user %d scored %v after %v", user.Id, relevance[i].Score, relevance[i-1].Score)
+		}
+		if relevance[i].Score <= 0 || !strings.Contains(relevance[i].Snippet, "<em>") {
+			t.Errorf("user %d: %+v", user.Id, relevance[i])
+		}
+		if fulltext.Snippet(user.Name+" "+user.About, terms, 1000) == "" {
+			t.Errorf("user %d has no query words", user.Id)
+		}
+	}
+	if !strings.HasPrefix(relevance[0].Snippet, "<em>Nulla cillum</em>") && !strings.HasPrefix(relevance[0].Snippet, "Nulla <em>cillum</em>") {
+		t.Errorf("unexpected snippet %q", relevance[0].Snippet)
+	}
+
+	page, err := srv.Search(Request{Limit: 5, Offset: 3, Query: req.Query, OrderField: OrderFieldRelevance})
+	if err != nil || !equalIds(page, users[3:8]) {
+		t.Errorf("page: got %v, want %v (%v)", ids(page), ids(users[3:8]), err)
+	}
+
+	filtered, _ := srv.Search(Request{Limit: 35, Query: req.Query, OrderField: OrderFieldRelevance, Filter: "gender=female"})
+	for _, user := range filtered {
+		if user.Gender != "female" {
+			t.Errorf("user %d is %s", user.Id, user.Gender)
+		}
+	}
+
+	// без About находится только Boyd
+	hidden, _ := srv.Search(Request{Limit: 35, Query: req.Query, OrderField: OrderFieldRelevance, HideAbout: true})
+	if len(hidden) != 1 || hidden[0].Id != 0 {
+		t.Errorf("HideAbout: got %v", ids(hidden))
+	}
+
+	all, _ := srv.Search(Request{Limit: 35, OrderField: OrderFieldRelevance})
+	byId, _ := srv.Search(Request{Limit: 35, OrderField: "Id", OrderBy: OrderByAsc})
+	if !equalIds(all, byId) {
+		t.Errorf("empty query: got %v", ids(all))
+	}
+
+	if err := srv.Insert(User{Id: 100, Name: "Zelda Quux", About: "cillum cillum cillum"}); err != nil {
+		t.Fatal(err)
+	}
+	inserted, _ := srv.Search(Request{Limit: 1, Query: "zelda", OrderField: OrderFieldRelevance})
+	if len(inserted) != 1 || inserted[0].Id != 100 {
+		t.Errorf("inserted: got %v", ids(inserted))
+	}
+
+	if _, err := srv.SearchPage(Request{Limit: 1, OrderField: OrderFieldRelevance}); !errors.Is(err, ErrBadOrderField) {
+		t.Errorf("cursor: got %v", err)
+	}
+}
+
+func TestRelevanceResponse(t *testing.T) {
+	server := httptest.NewServer(loadTestServer(t))
+	defer server.Close()
+
+	for _, test := range []struct {
+		query string
+		want  string
+	}{
+		{"limit=1&query=boyd&order_field=relevance", `"Name":"Boyd Wolf",`},
+		{"limit=1&query=boyd&order_field=relevance&fields=Id", `[{"Id":0,"Score":`},
+	} {
+		req, _ := http.NewRequest("GET", server.URL+"?"+test.query, nil)
+		req.Header.Set("AccessToken", testToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), test.want) ||
+			!strings.Contains(string(body), `"Snippet":"\u003cem\u003eBoyd\u003c/em\u003e Wolf"`) {
+			t.Errorf("%s: %d %s", test.query, resp.StatusCode, body)
+		}
+	}
+}
+
+func equalIds(a, b []User) bool {
+	return reflect.DeepEqual(ids(a), ids(b))
+}
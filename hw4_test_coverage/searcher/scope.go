@@ -0,0 +1,58 @@
+package searcher
+
+import (
+	"errors"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/model"
+	"github.com/akurin/golang-webservices/hw4_test_coverage/query"
+	"github.com/akurin/golang-webservices/hw4_test_coverage/token"
+)
+
+// ErrInsufficientScope - токену запроса не хватает права из Message
+var ErrInsufficientScope = errors.New("ErrorInsufficientScope")
+
+var errNoAboutScope = &requestError{ErrInsufficientScope, errors.New(token.ScopeReadAbout)}
+
+// fieldsWithoutAbout - поля ответа для токена без read:about
+var fieldsWithoutAbout = func() []string {
+	var fields []string
+	for _, field := range model.Fields {
+		if field != "About" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}()
+
+// hideAbout ограничивает запрос токена без read:about: About не попадает
+// в ответ, а явный запрос этого поля - ошибка
+func hideAbout(req *Request) error {
+	req.HideAbout = true
+
+	for _, field := range req.Fields {
+		if field == "About" {
+			return errNoAboutScope
+		}
+	}
+	if len(req.Fields) == 0 {
+		req.Fields = fieldsWithoutAbout
+	}
+	return nil
+}
+
+// parseSort разбирает Sort с учётом HideAbout
+func parseSort(req Request) ([]query.SortKey, error) {
+	keys, err := query.ParseSort(req.Sort, UserSchema)
+	if err != nil {
+		return nil, &requestError{ErrBadSort, err}
+	}
+
+	if req.HideAbout {
+		for _, key := range keys {
+			if key.Field == "about" {
+				return nil, errNoAboutScope
+			}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,523 @@
+package searcher
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/query"
+	"github.com/akurin/golang-webservices/hw4_test_coverage/token"
+)
+
+const (
+	OrderByAsc  = -1
+	OrderByAsIs = 0
+	OrderByDesc = 1
+)
+
+// коды ошибок в SearchErrorResponse, которые понимает SearchClient
+var (
+	ErrBadOrderField = errors.New("ErrorBadOrderField")
+	ErrBadOrderBy    = errors.New("ErrorBadOrderBy")
+	ErrBadLimit      = errors.New("ErrorBadLimit")
+	ErrBadOffset     = errors.New("ErrorBadOffset")
+	ErrBadFilter     = errors.New("ErrorBadFilter")
+	ErrBadSort       = errors.New("ErrorBadSort")
+)
+
+// requestError - код ошибки с подробностями для SearchErrorResponse.Message
+type requestError struct {
+	code   error
+	detail error
+}
+
+func (e *requestError) Error() string {
+	return e.code.Error() + ": " + e.detail.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.code
+}
+
+type Request struct {
+	Limit      int
+	Offset     int
+	Query      string
+	OrderField string
+	OrderBy    int
+	// фильтр на языке пакета query, применяется вместе с Query
+	Filter string
+	// ключи сортировки вида "age,-name", если заданы, OrderField и OrderBy не учитываются
+	Sort string
+	// курсор из Page.NextCursor для SearchPage
+	Cursor string
+	// поля пользователей в ответе сервера, пусто - все. Search их не учитывает
+	Fields []string
+	// запрос без права read:about: Query ищет только в Name, а about нельзя
+	// упоминать в Filter и Sort
+	HideAbout bool
+}
+
+type SearchErrorResponse struct {
+	Error   string
+	Message string `json:",omitempty"`
+}
+
+// UserSchema - поля User, доступные в Filter и Sort
+var UserSchema = query.Schema{
+	"id":            query.Int,
+	"age":           query.Int,
+	"name":          query.String,
+	"about":         query.String,
+	"gender":        query.String,
+	"eyecolor":      query.String,
+	"company":       query.String,
+	"email":         query.String,
+	"favoritefruit": query.String,
+}
+
+type userRecord struct {
+	*User
+}
+
+func (r userRecord) Int(field string) int {
+	switch field {
+	case "id":
+		return r.Id
+	case "age":
+		return r.Age
+	}
+	return 0
+}
+
+func (r userRecord) String(field string) string {
+	switch field {
+	case "name":
+		return r.Name
+	case "about":
+		return r.About
+	case "gender":
+		return r.Gender
+	case "eyecolor":
+		return r.EyeColor
+	case "company":
+		return r.Company
+	case "email":
+		return r.Email
+	case "favoritefruit":
+		return r.FavoriteFruit
+	}
+	return ""
+}
+
+// Server ищет по пользователям, загруженным один раз. Для каждого поля
+// сортировки порядок пользователей посчитан заранее
+type Server struct {
+	mu    sync.RWMutex
+	users []User
+	// Id -> позиция в users
+	ids    map[int]int
+	orders map[string][]int
+	text   *textIndex
+
+	accessTokens [][]byte
+	cursorSecret []byte
+
+	// версия данных для ETag: хеш исходного набора и число вставок
+	datasetHash string
+	version     int
+}
+
+var ErrDuplicateId = errors.New("user with this Id already exists")
+
+var orderLess = map[string]func(a, b *User) bool{
+	"Id":   func(a, b *User) bool { return a.Id < b.Id },
+	"Age":  func(a, b *User) bool { return a.Age < b.Age },
+	"Name": func(a, b *User) bool { return a.Name < b.Name },
+}
+
+func NewServer(users []User, accessTokens []string) *Server {
+	srv := &Server{
+		users:        users,
+		ids:          make(map[int]int, len(users)),
+		text:         newTextIndex(users),
+		cursorSecret: make([]byte, 32),
+	}
+	for i, user := range users {
+		srv.ids[user.Id] = i
+	}
+	srv.buildOrders()
+
+	hash := sha256.New()
+	json.NewEncoder(hash).Encode(users)
+	srv.datasetHash = hex.EncodeToString(hash.Sum(nil)[:8])
+
+	for _, token := range accessTokens {
+		if token == "" {
+			continue
+		}
+		srv.accessTokens = append(srv.accessTokens, []byte(token))
+	}
+
+	if _, err := rand.Read(srv.cursorSecret); err != nil {
+		panic(err)
+	}
+
+	return srv
+}
+
+// SetCursorSecret задаёт ключ подписи курсоров. Нужен, если курсор, выданный
+// одним экземпляром сервера, должен приниматься другим
+func (srv *Server) SetCursorSecret(secret []byte) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.cursorSecret = append([]byte(nil), secret...)
+}
+
+// Insert добавляет пользователя в конец выдачи "как встретилось"
+func (srv *Server) Insert(user User) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if _, ok := srv.ids[user.Id]; ok {
+		return ErrDuplicateId
+	}
+	srv.ids[user.Id] = len(srv.users)
+	srv.users = append(srv.users, user)
+	srv.text.add(&srv.users[len(srv.users)-1])
+	srv.version++
+	srv.buildOrders()
+	return nil
+}
+
+// ETag - версия данных, меняется при каждом их изменении. ETag ответа
+// зависит ещё и от запроса, см. queryETag
+func (srv *Server) ETag() string {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return fmt.Sprintf(`"%s-%d"`, srv.datasetHash, srv.version)
+}
+
+// queryETag - ETag ответа на запрос: версия данных, параметры запроса и то,
+// скрыт ли About. Отдаётся только с 200, так что совпадение с ним значит, что
+// тот же запрос по тем же данным уже был успешным
+func queryETag(version string, values url.Values, claims *token.Claims) string {
+	hideAbout := claims != nil && !claims.HasScope(token.ScopeReadAbout)
+	// Encode сортирует параметры, их порядок в URL не важен
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%t\n%s", version, hideAbout, values.Encode())))
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:12]))
+}
+
+// etagMatches проверяет If-None-Match, wildcard - учитывать ли "*"
+func etagMatches(ifNoneMatch string, etag string, wildcard bool) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || wildcard && candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (srv *Server) buildOrders() {
+	users := srv.users
+	srv.orders = make(map[string][]int, len(orderLess))
+
+	for field, less := range orderLess {
+		order := make([]int, len(users))
+		for i := range order {
+			order[i] = i
+		}
+		// при равенстве поля порядок определяет Id, чтобы страницы не перемешивались
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := &users[order[i]], &users[order[j]]
+			if less(a, b) {
+				return true
+			}
+			if less(b, a) {
+				return false
+			}
+			return a.Id < b.Id
+		})
+		srv.orders[field] = order
+	}
+}
+
+// Search возвращает страницу пользователей, у которых Query встречается в Name
+// или About и которые подходят под Filter. Пустой OrderField означает
+// сортировку по Name, OrderFieldRelevance - полнотекстовый поиск
+func (srv *Server) Search(req Request) ([]User, error) {
+	if req.Limit < 0 {
+		return nil, ErrBadLimit
+	}
+	if req.Offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if req.Sort == "" && req.OrderField == OrderFieldRelevance {
+		users, _, err := srv.searchRelevance(req)
+		return users, err
+	}
+
+	matches, err := matcher(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Sort != "" {
+		keys, err := parseSort(req)
+		if err != nil {
+			return nil, err
+		}
+		return srv.searchSorted(matches, keys, req), nil
+	}
+
+	field := req.OrderField
+	if field == "" {
+		field = "Name"
+	}
+	order, ok := srv.orders[field]
+	if !ok {
+		return nil, ErrBadOrderField
+	}
+
+	var next func(i int) int
+	switch req.OrderBy {
+	case OrderByAsc:
+		next = func(i int) int { return order[i] }
+	case OrderByDesc:
+		next = func(i int) int { return order[len(order)-1-i] }
+	case OrderByAsIs:
+		next = func(i int) int { return i }
+	default:
+		return nil, ErrBadOrderBy
+	}
+
+	result := make([]User, 0, srv.pageCapacity(req.Limit))
+	skipped := 0
+	for i := 0; i < len(srv.users) && len(result) < req.Limit; i++ {
+		user := &srv.users[next(i)]
+		if !matches(user) {
+			continue
+		}
+		if skipped < req.Offset {
+			skipped++
+			continue
+		}
+		result = append(result, *user)
+	}
+
+	return result, nil
+}
+
+// matcher возвращает проверку Query и Filter
+func matcher(req Request) (func(user *User) bool, error) {
+	filter, err := query.Parse(req.Filter, UserSchema)
+	if err != nil {
+		return nil, &requestError{ErrBadFilter, err}
+	}
+	if req.HideAbout && filter.Uses("about") {
+		return nil, errNoAboutScope
+	}
+
+	return func(user *User) bool {
+		found := strings.Contains(user.Name, req.Query) ||
+			!req.HideAbout && strings.Contains(user.About, req.Query)
+		return found && filter.Match(userRecord{user})
+	}, nil
+}
+
+// searchSorted сортирует всех подходящих пользователей по ключам из Sort,
+// при равенстве - по Id
+func (srv *Server) searchSorted(matches func(user *User) bool, keys []query.SortKey, req Request) []User {
+	var found []*User
+	for i := range srv.users {
+		if matches(&srv.users[i]) {
+			found = append(found, &srv.users[i])
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if c := query.Compare(userRecord{found[i]}, userRecord{found[j]}, keys, UserSchema); c != 0 {
+			return c < 0
+		}
+		return found[i].Id < found[j].Id
+	})
+
+	result := make([]User, 0, srv.pageCapacity(req.Limit))
+	for i := req.Offset; i < len(found) && len(result) < req.Limit; i++ {
+		result = append(result, *found[i])
+	}
+	return result
+}
+
+func (srv *Server) pageCapacity(limit int) int {
+	if limit > len(srv.users) {
+		return len(srv.users)
+	}
+	return limit
+}
+
+func (srv *Server) authorized(token string) bool {
+	found := 0
+	for _, expected := range srv.accessTokens {
+		found |= subtle.ConstantTimeCompare(expected, []byte(token))
+	}
+	return found == 1
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	batch := strings.HasSuffix(r.URL.Path, batchSuffix)
+	if (!batch && r.Method != http.MethodGet) || (batch && r.Method != http.MethodPost) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// запрос, прошедший token.Middleware, авторизован его токеном
+	claims, _ := token.FromContext(r.Context())
+	if claims == nil && !srv.authorized(r.Header.Get("AccessToken")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if batch {
+		srv.serveBatch(w, r, claims)
+		return
+	}
+
+	// ETag берётся до поиска: если данные изменятся между ними, ответ окажется
+	// новее своего ETag и клиент лишний раз перезапросит его, но не наоборот.
+	// Совпавший ETag отвечается 304 без поиска, "*" - только после успешного
+	values := r.URL.Query()
+	etag := queryETag(srv.ETag(), values, claims)
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if etagMatches(ifNoneMatch, etag, false) {
+		writeNotModified(w, etag)
+		return
+	}
+
+	status, body := srv.answer(values, claims)
+	if status == http.StatusOK {
+		if etagMatches(ifNoneMatch, etag, true) {
+			writeNotModified(w, etag)
+			return
+		}
+		setCacheHeaders(w, etag)
+	}
+	writeJSON(w, status, body)
+}
+
+func setCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+func writeNotModified(w http.ResponseWriter, etag string) {
+	setCacheHeaders(w, etag)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// answer ищет по параметрам запроса и возвращает статус и тело ответа.
+// claims - права токена, nil для статических токенов
+func (srv *Server) answer(values url.Values, claims *token.Claims) (int, interface{}) {
+	req, err := readRequest(values)
+	if err == nil && claims != nil && !claims.HasScope(token.ScopeReadAbout) {
+		err = hideAbout(&req)
+	}
+	if err != nil {
+		return errorStatus(err), errorResponse(err)
+	}
+
+	var users []User
+	var page *Page
+	var relevance []Relevance
+	// с параметром cursor, даже пустым, отвечаем страницей с next_cursor
+	if _, cursorMode := values["cursor"]; cursorMode {
+		if page, err = srv.SearchPage(req); err == nil {
+			users = page.Users
+		}
+	} else if req.Sort == "" && req.OrderField == OrderFieldRelevance {
+		users, relevance, err = srv.SearchRelevance(req)
+	} else {
+		users, err = srv.Search(req)
+	}
+	if err != nil {
+		return errorStatus(err), errorResponse(err)
+	}
+	return http.StatusOK, responseBody(users, page, req.Fields, relevance)
+}
+
+func errorStatus(err error) int {
+	if errors.Is(err, ErrInsufficientScope) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+func errorResponse(err error) SearchErrorResponse {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return SearchErrorResponse{Error: reqErr.code.Error(), Message: reqErr.detail.Error()}
+	}
+	return SearchErrorResponse{Error: err.Error()}
+}
+
+func readRequest(values url.Values) (Request, error) {
+	req := Request{
+		Query:      values.Get("query"),
+		OrderField: values.Get("order_field"),
+		Filter:     values.Get("filter"),
+		Sort:       values.Get("sort"),
+		Cursor:     values.Get("cursor"),
+	}
+
+	var err error
+	if req.Fields, err = parseFields(values.Get("fields")); err != nil {
+		return req, err
+	}
+	if req.Limit, err = intParam(values.Get("limit")); err != nil {
+		return req, ErrBadLimit
+	}
+	if req.Offset, err = intParam(values.Get("offset")); err != nil {
+		return req, ErrBadOffset
+	}
+	if req.OrderBy, err = intParam(values.Get("order_by")); err != nil {
+		return req, ErrBadOrderBy
+	}
+
+	return req, nil
+}
+
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,431 @@
+package searcher
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "token"
+
+func loadTestServer(t *testing.T) *Server {
+	users, err := LoadDataset("../dataset.xml")
+	if err != nil {
+		t.Fatalf("cant load dataset: %s", err)
+	}
+	if len(users) != 35 {
+		t.Fatalf("expected 35 users, got %d", len(users))
+	}
+	return NewServer(users, []string{testToken})
+}
+
+// bruteSearch - прямолинейная реализация, с которой сверяется Server.Search
+func bruteSearch(users []User, req Request) []User {
+	var filtered []User
+	for _, user := range users {
+		if strings.Contains(user.Name, req.Query) || strings.Contains(user.About, req.Query) {
+			filtered = append(filtered, user)
+		}
+	}
+
+	field := req.OrderField
+	if field == "" {
+		field = "Name"
+	}
+	less := orderLess[field]
+	if req.OrderBy != OrderByAsIs {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, b := &filtered[i], &filtered[j]
+			if req.OrderBy == OrderByDesc {
+				a, b = b, a
+			}
+			if less(a, b) || less(b, a) {
+				return less(a, b)
+			}
+			return a.Id < b.Id
+		})
+	}
+
+	result := []User{}
+	for i := req.Offset; i < len(filtered) && len(result) < req.Limit; i++ {
+		result = append(result, filtered[i])
+	}
+	return result
+}
+
+func TestSearchMatchesBruteForce(t *testing.T) {
+	srv := loadTestServer(t)
+
+	for _, field := range []string{"", "Id", "Age", "Name"} {
+		for _, orderBy := range []int{OrderByAsc, OrderByAsIs, OrderByDesc} {
+			for _, query := range []string{"", "a", "Boyd", "nulla", "no such text"} {
+				for _, page := range [][2]int{{0, 0}, {0, 1}, {0, 26}, {5, 10}, {30, 26}, {100, 5}} {
+					req := Request{
+						Limit:      page[1],
+						Offset:     page[0],
+						Query:      query,
+						OrderField: field,
+						OrderBy:    orderBy,
+					}
+
+					got, err := srv.Search(req)
+					if err != nil {
+						t.Fatalf("%+v: %s", req, err)
+					}
+					if want := bruteSearch(srv.users, req); !reflect.DeepEqual(got, want) {
+						t.Errorf("%+v:\ngot  %v\nwant %v", req, ids(got), ids(want))
+					}
+				}
+			}
+		}
+	}
+}
+
+func ids(users []User) []int {
+	result := make([]int, 0, len(users))
+	for _, user := range users {
+		result = append(result, user.Id)
+	}
+	return result
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := httptest.NewServer(loadTestServer(t))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		token      string
+		query      string
+		wantStatus int
+		wantError  string
+		wantIds    []int
+	}{
+		{
+			name:       "no token",
+			query:      "limit=1",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "bad token",
+			token:      "bad",
+			query:      "limit=1",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "by age desc",
+			token:      testToken,
+			query:      "limit=3&offset=1&query=&order_field=Age&order_by=1",
+			wantStatus: http.StatusOK,
+			wantIds:    []int{13, 26, 6},
+		},
+		{
+			name:       "as is",
+			token:      testToken,
+			query:      "limit=2&offset=3&order_field=Id&order_by=0",
+			wantStatus: http.StatusOK,
+			wantIds:    []int{3, 4},
+		},
+		{
+			name:       "bad order field",
+			token:      testToken,
+			query:      "limit=1&order_field=About",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "ErrorBadOrderField",
+		},
+		{
+			name:       "bad order by",
+			token:      testToken,
+			query:      "limit=1&order_by=2",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "ErrorBadOrderBy",
+		},
+		{
+			name:       "bad limit",
+			token:      testToken,
+			query:      "limit=-1",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "ErrorBadLimit",
+		},
+		{
+			name:       "bad offset",
+			token:      testToken,
+			query:      "limit=1&offset=x",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "ErrorBadOffset",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", server.URL+"?"+test.query, nil)
+			if test.token != "" {
+				req.Header.Set("AccessToken", test.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+
+			switch {
+			case test.wantError != "":
+				var errResp SearchErrorResponse
+				if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+					t.Fatal(err)
+				}
+				if errResp.Error != test.wantError {
+					t.Errorf("Error = %q, want %q", errResp.Error, test.wantError)
+				}
+			case test.wantIds != nil:
+				var users []User
+				if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+					t.Fatal(err)
+				}
+				if got := ids(users); !reflect.DeepEqual(got, test.wantIds) {
+					t.Errorf("ids = %v, want %v", got, test.wantIds)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchFilterAndSort(t *testing.T) {
+	srv := loadTestServer(t)
+
+	tests := []struct {
+		name  string
+		req   Request
+		match func(u User) bool
+		less  func(a, b User) bool
+	}{
+		{
+			name:  "filter keeps old order",
+			req:   Request{Limit: 100, Filter: "age>30 and gender=female", OrderField: "Age", OrderBy: OrderByAsc},
+			match: func(u User) bool { return u.Age > 30 && u.Gender == "female" },
+			less:  func(a, b User) bool { return a.Age < b.Age || a.Age == b.Age && a.Id < b.Id },
+		},
+		{
+			name: "filter with query",
+			req:  Request{Limit: 100, Query: "a", Filter: `not name~"Wolf" or id<3`, OrderBy: OrderByAsIs},
+			match: func(u User) bool {
+				return strings.Contains(u.Name+u.About, "a") && (!strings.Contains(u.Name, "Wolf") || u.Id < 3)
+			},
+			less: func(a, b User) bool { return a.Id < b.Id },
+		},
+		{
+			name:  "multi-field sort",
+			req:   Request{Limit: 100, Sort: "gender,-age", OrderField: "Id", OrderBy: OrderByDesc},
+			match: func(u User) bool { return true },
+			less: func(a, b User) bool {
+				if a.Gender != b.Gender {
+					return a.Gender < b.Gender
+				}
+				if a.Age != b.Age {
+					return a.Age > b.Age
+				}
+				return a.Id < b.Id
+			},
+		},
+		{
+			name:  "sort with filter and page",
+			req:   Request{Limit: 4, Offset: 2, Sort: "-name", Filter: "age<=30"},
+			match: func(u User) bool { return u.Age <= 30 },
+			less:  func(a, b User) bool { return a.Name > b.Name || a.Name == b.Name && a.Id < b.Id },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var want []User
+			for _, user := range srv.users {
+				if test.match(user) {
+					want = append(want, user)
+				}
+			}
+			sort.SliceStable(want, func(i, j int) bool { return test.less(want[i], want[j]) })
+			if test.req.Offset < len(want) {
+				want = want[test.req.Offset:]
+			}
+			if len(want) > test.req.Limit {
+				want = want[:test.req.Limit]
+			}
+
+			got, err := srv.Search(test.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(want) == 0 || !reflect.DeepEqual(ids(got), ids(want)) {
+				t.Errorf("got %v, want %v", ids(got), ids(want))
+			}
+		})
+	}
+}
+
+func TestSearchFilterErrors(t *testing.T) {
+	srv := loadTestServer(t)
+
+	for _, test := range []struct {
+		req  Request
+		code error
+	}{
+		{Request{Limit: 1, Filter: "age>"}, ErrBadFilter},
+		{Request{Limit: 1, Filter: "salary>1"}, ErrBadFilter},
+		{Request{Limit: 1, Sort: "age,salary"}, ErrBadSort},
+	} {
+		req, code := test.req, test.code
+		_, err := srv.Search(req)
+		if !errors.Is(err, code) {
+			t.Errorf("%+v: expected %s, got %v", req, code, err)
+		}
+
+		resp := errorResponse(err)
+		if resp.Error != code.Error() || resp.Message == "" {
+			t.Errorf("%+v: bad error response %+v", req, resp)
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	srv := loadTestServer(t)
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	get := func(ifNoneMatch string) *http.Response {
+		return getWithETag(t, server.URL+"?limit=5&order_field=Age&order_by=-1", ifNoneMatch)
+	}
+
+	first := get("")
+	etag := first.Header.Get("ETag")
+	if first.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q", first.StatusCode, etag)
+	}
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		if resp := get(ifNoneMatch); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status = %d, want 304", ifNoneMatch, resp.StatusCode)
+		}
+	}
+
+	if err := srv.Insert(User{Id: 100, Name: "New User", Age: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Insert(User{Id: 100}); err != ErrDuplicateId {
+		t.Errorf("expected ErrDuplicateId, got %v", err)
+	}
+
+	resp := get(etag)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Errorf("after insert: status = %d, ETag = %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	// ETag другого запроса не подходит, порядок параметров не важен
+	other := getWithETag(t, server.URL+"?limit=6&order_field=Age&order_by=-1", resp.Header.Get("ETag"))
+	if other.StatusCode != http.StatusOK || other.Header.Get("ETag") == resp.Header.Get("ETag") {
+		t.Errorf("other query: status = %d, ETag = %q", other.StatusCode, other.Header.Get("ETag"))
+	}
+	reordered := getWithETag(t, server.URL+"?order_by=-1&order_field=Age&limit=5", resp.Header.Get("ETag"))
+	if reordered.StatusCode != http.StatusNotModified {
+		t.Errorf("reordered query: status = %d, want 304", reordered.StatusCode)
+	}
+
+	// на совпавший ETag сервер отвечает без поиска, иначе здесь был бы 400
+	values := url.Values{"limit": {"-1"}}
+	unsearched := getWithETag(t, server.URL+"?"+values.Encode(), queryETag(srv.ETag(), values, nil))
+	if unsearched.StatusCode != http.StatusNotModified {
+		t.Errorf("matching ETag: status = %d, want 304", unsearched.StatusCode)
+	}
+	if resp := getWithETag(t, server.URL+"?"+values.Encode(), "*"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad request with *: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func getWithETag(t *testing.T, target string, ifNoneMatch string) *http.Response {
+	req, _ := http.NewRequest("GET", target, nil)
+	req.Header.Set("AccessToken", testToken)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestDatasetFullModel(t *testing.T) {
+	users, err := LoadDataset("../dataset.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := users[0]
+	registered := time.Date(2017, 2, 5, 6, 23, 27, 0, time.FixedZone("", -3*60*60))
+	if user.Guid != "1a6fa827-62f1-45f6-b579-aaead2b47169" || user.IsActive || user.Balance != 214493 ||
+		user.EyeColor != "green" || user.Company != "HOPELI" || user.Email != "boydwolf@hopeli.com" ||
+		user.Phone != "+1 (956) 593-2402" || user.FavoriteFruit != "apple" ||
+		!user.Registered.Equal(registered) || user.Name != "Boyd Wolf" || user.Age != 22 {
+		t.Errorf("unexpected first user: %+v", user)
+	}
+
+	active := 0
+	for _, user := range users {
+		if user.IsActive {
+			active++
+		}
+	}
+	if active != 17 {
+		t.Errorf("active = %d, want 17", active)
+	}
+}
+
+func TestFieldsProjection(t *testing.T) {
+	server := httptest.NewServer(loadTestServer(t))
+	defer server.Close()
+
+	get := func(query string) (int, []byte) {
+		req, _ := http.NewRequest("GET", server.URL+"?"+query, nil)
+		req.Header.Set("AccessToken", testToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+
+	status, body := get("limit=2&order_field=Id&order_by=-1&fields=id,Balance,email,id")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	want := `[{"Balance":2144.93,"Email":"boydwolf@hopeli.com","Id":0},` +
+		`{"Balance":2705.71,"Email":"hildamayer@quintity.com","Id":1}]`
+	if string(body) != want {
+		t.Errorf("got  %s\nwant %s", body, want)
+	}
+
+	status, body = get("limit=1&cursor=&fields=Name")
+	if status != http.StatusOK || !strings.HasPrefix(string(body), `{"users":[{"Name":"`) {
+		t.Errorf("cursor page: status = %d, body %s", status, body)
+	}
+
+	status, body = get("limit=1&fields=Name,Salary")
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "ErrorBadFields") {
+		t.Errorf("bad fields: status = %d, body %s", status, body)
+	}
+}
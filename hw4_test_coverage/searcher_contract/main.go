@@ -0,0 +1,33 @@
+package main
+
+// проверка сервера поиска на совместимость с SearchClient:
+//
+//	go run ./searcher_contract -url http://localhost:8080/ -token secret
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/contract"
+)
+
+func main() {
+	url := flag.String("url", "", "search server url")
+	token := flag.String("token", os.Getenv("SEARCH_ACCESS_TOKEN"), "valid access token, defaults to $SEARCH_ACCESS_TOKEN")
+	timeout := flag.Duration("timeout", contract.DefaultTimeout, "how fast the server must answer")
+	flag.Parse()
+
+	if *url == "" {
+		log.Fatal("set -url")
+	}
+
+	report := contract.Run(contract.Target{URL: *url, AccessToken: *token, Timeout: *timeout})
+	fmt.Print(report)
+
+	if violations := report.Violations(); len(violations) > 0 {
+		fmt.Printf("%d of %d checks failed\n", len(violations), len(report))
+		os.Exit(1)
+	}
+}
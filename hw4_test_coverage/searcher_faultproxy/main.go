@@ -0,0 +1,36 @@
+package main
+
+// прокси со сбоями перед сервером поиска:
+//
+//	go run ./searcher_faultproxy -target http://localhost:8080 -faults 'latency=0.3:2s,status=0.1:503,truncate=0.05'
+//	curl -H 'AccessToken: secret' 'localhost:8081/?limit=5&offset=0&query=&order_field=Age&order_by=-1'
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/faultproxy"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	target := flag.String("target", "http://localhost:8080", "search server url")
+	spec := flag.String("faults", "", "faults as kind=probability[:arg],...; kinds: latency=p:duration, drop=p, status=p:code, truncate=p, corrupt=p")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed to reproduce a run")
+	flag.Parse()
+
+	faults, err := faultproxy.ParseFaults(*spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	proxy, err := faultproxy.New(*target, faults, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("proxying %s at %s with faults %v, seed %d\n", *target, *addr, faults, *seed)
+	log.Fatal(http.ListenAndServe(*addr, proxy))
+}
@@ -0,0 +1,72 @@
+package main
+
+// сервер поиска пользователей из dataset.xml для SearchClient:
+//
+//	go run ./searcher_server -dataset dataset.xml -tokens secret
+//	curl -H 'AccessToken: secret' 'localhost:8080/?limit=5&offset=0&query=&order_field=Age&order_by=-1'
+//
+// или с токенами, которые выдаёт /token по client_credentials:
+//
+//	SEARCH_TOKEN_CLIENTS='{"ui": {"secret": "ui-secret", "scopes": ["read:users"]}}' \
+//		go run ./searcher_server -dataset dataset.xml -token-secret key
+//	curl -d grant_type=client_credentials -d client_id=ui -d client_secret=ui-secret localhost:8080/token
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/searcher"
+	"github.com/akurin/golang-webservices/hw4_test_coverage/token"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	datasetPath := flag.String("dataset", "dataset.xml", "path to dataset.xml")
+	tokens := flag.String("tokens", os.Getenv("SEARCH_ACCESS_TOKENS"), "comma separated access tokens, defaults to $SEARCH_ACCESS_TOKENS")
+	cursorSecret := flag.String("cursor-secret", os.Getenv("SEARCH_CURSOR_SECRET"), "key to sign page cursors, random if empty, defaults to $SEARCH_CURSOR_SECRET")
+	tokenSecret := flag.String("token-secret", os.Getenv("SEARCH_TOKEN_SECRET"), "key to sign issued tokens, enables /token, defaults to $SEARCH_TOKEN_SECRET")
+	tokenClients := flag.String("token-clients", os.Getenv("SEARCH_TOKEN_CLIENTS"), `JSON {"client_id": {"secret": "...", "scopes": ["read:users"]}}, defaults to $SEARCH_TOKEN_CLIENTS`)
+	tokenTTL := flag.Duration("token-ttl", time.Hour, "lifetime of issued tokens")
+	flag.Parse()
+
+	if *tokens == "" && *tokenSecret == "" {
+		log.Fatal("no access tokens, set -tokens or SEARCH_ACCESS_TOKENS, or -token-secret or SEARCH_TOKEN_SECRET")
+	}
+	if *tokens != "" && *tokenSecret != "" {
+		log.Fatal("-tokens and -token-secret are mutually exclusive")
+	}
+
+	users, err := searcher.LoadDataset(*datasetPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := searcher.NewServer(users, strings.Split(*tokens, ","))
+	if *cursorSecret != "" {
+		server.SetCursorSecret([]byte(*cursorSecret))
+	}
+
+	if *tokenSecret == "" {
+		http.Handle("/", server)
+	} else {
+		clients := make(map[string]token.Client)
+		if *tokenClients != "" {
+			if err := json.Unmarshal([]byte(*tokenClients), &clients); err != nil {
+				log.Fatal("bad -token-clients: ", err)
+			}
+		}
+
+		signer := token.NewSigner([]byte(*tokenSecret))
+		http.Handle("/token", &token.Endpoint{Signer: signer, Clients: clients, TTL: *tokenTTL})
+		http.Handle("/", token.Middleware(signer, []string{token.ScopeReadUsers}, server))
+	}
+
+	fmt.Println("starting server at", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
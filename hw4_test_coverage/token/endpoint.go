@@ -0,0 +1,99 @@
+package token
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client - приложение, которому выдаются токены по client_credentials
+type Client struct {
+	Secret string   `json:"secret"`
+	Scopes []string `json:"scopes"`
+}
+
+// Endpoint выдаёт токены по OAuth 2.0 client_credentials (RFC 6749, 4.4):
+//
+//	POST /token
+//	grant_type=client_credentials&client_id=ui&client_secret=...&scope=read:users
+//
+// Идентификатор и секрет можно передать и через Basic-авторизацию. Если scope
+// не указан, выдаются все права клиента
+type Endpoint struct {
+	Signer  *Signer
+	Clients map[string]Client
+	TTL     time.Duration
+}
+
+// TokenResponse - успешный ответ Endpoint
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// TokenErrorResponse - ошибка Endpoint, error - код из RFC 6749, 5.2
+type TokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func (e *Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenJSON(w, http.StatusBadRequest, TokenErrorResponse{"invalid_request", err.Error()})
+		return
+	}
+
+	if grantType := r.PostForm.Get("grant_type"); grantType != "client_credentials" {
+		writeTokenJSON(w, http.StatusBadRequest, TokenErrorResponse{"unsupported_grant_type", grantType})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	client, known := e.Clients[clientID]
+	if !known || subtle.ConstantTimeCompare([]byte(client.Secret), []byte(clientSecret)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		writeTokenJSON(w, http.StatusUnauthorized, TokenErrorResponse{Error: "invalid_client"})
+		return
+	}
+
+	scopes := client.Scopes
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		allowed := &Claims{Scopes: client.Scopes}
+		for _, scope := range requested {
+			if !allowed.HasScope(scope) {
+				writeTokenJSON(w, http.StatusBadRequest, TokenErrorResponse{"invalid_scope", scope})
+				return
+			}
+		}
+		scopes = requested
+	}
+
+	token, claims := e.Signer.Issue(clientID, scopes, e.TTL)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeTokenJSON(w, http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(claims.ExpiresAt.Sub(claims.IssuedAt) / time.Second),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+func writeTokenJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,78 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// FromContext возвращает права запроса, прошедшего Middleware
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
+
+// errorResponse повторяет SearchErrorResponse, чтобы SearchClient понимал ошибки
+type errorResponse struct {
+	Error   string
+	Message string `json:",omitempty"`
+}
+
+// Middleware пропускает в next только запросы с действующим токеном, в
+// котором есть все scopes. Токен берётся из заголовка AccessToken или
+// Authorization: Bearer. Права токена доступны в next через FromContext
+func Middleware(signer *Signer, scopes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := signer.Verify(requestToken(r))
+
+		switch err {
+		case nil:
+		case ErrExpired:
+			writeError(w, http.StatusUnauthorized, errorResponse{"ErrorTokenExpired", err.Error()})
+			return
+		default:
+			writeError(w, http.StatusUnauthorized, errorResponse{"ErrorBadToken", err.Error()})
+			return
+		}
+
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				WriteInsufficientScope(w, scope)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, claims)))
+	})
+}
+
+// WriteInsufficientScope отвечает 403, если токену не хватает scope
+func WriteInsufficientScope(w http.ResponseWriter, scope string) {
+	writeError(w, http.StatusForbidden, errorResponse{"ErrorInsufficientScope", scope})
+}
+
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get("AccessToken"); token != "" {
+		return token
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return auth[len("Bearer "):]
+	}
+	return ""
+}
+
+func writeError(w http.ResponseWriter, status int, resp errorResponse) {
+	body, _ := json.Marshal(resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,109 @@
+// Package token выдаёт и проверяет подписанные HMAC токены доступа к API поиска
+// со сроком жизни и набором прав (scopes)
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeReadUsers = "read:users"
+	ScopeReadAbout = "read:about"
+)
+
+var (
+	ErrMalformed    = errors.New("malformed token")
+	ErrBadSignature = errors.New("bad token signature")
+	ErrExpired      = errors.New("token expired")
+)
+
+type Claims struct {
+	Subject   string    `json:"sub"`
+	Scopes    []string  `json:"scp"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Signer подписывает и проверяет токены общим секретом
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{
+		secret: append([]byte(nil), secret...),
+		now:    time.Now,
+	}
+}
+
+// Issue выдаёт токен, действующий ttl
+func (s *Signer) Issue(subject string, scopes []string, ttl time.Duration) (string, *Claims) {
+	now := s.now()
+	claims := &Claims{
+		Subject:   subject,
+		Scopes:    scopes,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload)), claims
+}
+
+// Verify проверяет подпись и срок действия токена
+func (s *Signer) Verify(token string) (*Claims, error) {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return nil, ErrMalformed
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(token[:dot])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if !hmac.Equal(signature, s.sign(payload)) {
+		return nil, ErrBadSignature
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if !s.now().Before(claims.ExpiresAt) {
+		return &claims, ErrExpired
+	}
+	return &claims, nil
+}
+
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
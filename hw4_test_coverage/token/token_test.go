@@ -0,0 +1,138 @@
+package token
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueVerify(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+
+	token, _ := signer.Issue("ui", []string{ScopeReadUsers}, time.Minute)
+
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if claims.Subject != "ui" || !claims.HasScope(ScopeReadUsers) || claims.HasScope(ScopeReadAbout) {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if _, err := NewSigner([]byte("other")).Verify(token); err != ErrBadSignature {
+		t.Errorf("other secret: got %v, want %v", err, ErrBadSignature)
+	}
+
+	dot := strings.IndexByte(token, '.')
+	forged, _ := signer.Issue("ui", []string{ScopeReadUsers, ScopeReadAbout}, time.Minute)
+	if _, err := signer.Verify(forged[:strings.IndexByte(forged, '.')] + token[dot:]); err != ErrBadSignature {
+		t.Errorf("swapped payload: got %v, want %v", err, ErrBadSignature)
+	}
+
+	for _, bad := range []string{"", "no-dot", "!!!." + token[dot+1:], token[:dot] + ".!!!"} {
+		if _, err := signer.Verify(bad); err != ErrMalformed {
+			t.Errorf("%q: got %v, want %v", bad, err, ErrMalformed)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := signer.Verify(token); err != ErrExpired {
+		t.Errorf("after ttl: got %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	handler := Middleware(signer, []string{ScopeReadUsers}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := FromContext(r.Context())
+		w.Write([]byte(claims.Subject))
+	}))
+
+	valid, _ := signer.Issue("ui", []string{ScopeReadUsers}, time.Minute)
+	expired, _ := signer.Issue("ui", []string{ScopeReadUsers}, -time.Minute)
+	underScoped, _ := signer.Issue("ui", []string{ScopeReadAbout}, time.Minute)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+		body   string
+	}{
+		{"access token", "AccessToken", valid, http.StatusOK, "ui"},
+		{"bearer", "Authorization", "Bearer " + valid, http.StatusOK, "ui"},
+		{"no token", "", "", http.StatusUnauthorized, `"Error":"ErrorBadToken"`},
+		{"tampered", "AccessToken", valid[:len(valid)-2] + "AA", http.StatusUnauthorized, `"Error":"ErrorBadToken"`},
+		{"expired", "AccessToken", expired, http.StatusUnauthorized, `"Error":"ErrorTokenExpired"`},
+		{"scope", "AccessToken", underScoped, http.StatusForbidden, `"Message":"read:users"`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.status || !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("%s: got %d %s, want %d with %s", test.name, w.Code, w.Body, test.status, test.body)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	endpoint := &Endpoint{
+		Signer:  signer,
+		Clients: map[string]Client{"ui": {Secret: "ui-secret", Scopes: []string{ScopeReadUsers, ScopeReadAbout}}},
+		TTL:     time.Hour,
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		endpoint.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post(url.Values{"grant_type": {"client_credentials"}, "client_id": {"ui"}, "client_secret": {"ui-secret"}, "scope": {"read:users"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	var resp TokenResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.TokenType != "Bearer" || resp.ExpiresIn != 3600 || resp.Scope != "read:users" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	claims, err := signer.Verify(resp.AccessToken)
+	if err != nil || claims.Subject != "ui" || claims.HasScope(ScopeReadAbout) {
+		t.Errorf("unexpected claims %+v, %v", claims, err)
+	}
+
+	errors := []struct {
+		form   url.Values
+		status int
+		code   string
+	}{
+		{url.Values{"grant_type": {"password"}}, http.StatusBadRequest, "unsupported_grant_type"},
+		{url.Values{"grant_type": {"client_credentials"}, "client_id": {"ui"}, "client_secret": {"wrong"}}, http.StatusUnauthorized, "invalid_client"},
+		{url.Values{"grant_type": {"client_credentials"}, "client_id": {"nobody"}}, http.StatusUnauthorized, "invalid_client"},
+		{url.Values{"grant_type": {"client_credentials"}, "client_id": {"ui"}, "client_secret": {"ui-secret"}, "scope": {"write:users"}}, http.StatusBadRequest, "invalid_scope"},
+	}
+	for _, test := range errors {
+		w := post(test.form)
+		var errResp TokenErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &errResp)
+		if w.Code != test.status || errResp.Error != test.code {
+			t.Errorf("%v: got %d %s, want %d %s", test.form, w.Code, w.Body, test.status, test.code)
+		}
+	}
+}
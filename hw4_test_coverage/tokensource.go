@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource выдаёт токен для запросов SearchClient вместо AccessToken
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// tokenExpirer - TokenSource, которому можно сообщить, что сервер счёл токен
+// истёкшим, например из-за расхождения часов
+type tokenExpirer interface {
+	Expire(token string)
+}
+
+const (
+	defaultRefreshBefore = 10 * time.Second
+	// срок токена, если сервер не прислал expires_in: если токен истечёт
+	// раньше, SearchClient получит ErrTokenExpired и запросит новый
+	defaultExpiresIn = 5 * time.Minute
+)
+
+// ClientCredentials получает токены у сервера по OAuth 2.0 client_credentials
+// и обновляет их заранее, до истечения срока. Одновременные вызовы Token ждут
+// одного запроса к серверу, но не дольше своего ctx
+type ClientCredentials struct {
+	// урл выдачи токенов, например http://localhost:8080/token
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// права токена, пусто - все права клиента
+	Scopes []string
+	// если не задан, используется клиент с таймаутом в секунду
+	HTTPClient *http.Client
+	// за сколько до истечения обновлять токен, по умолчанию 10 секунд
+	RefreshBefore time.Duration
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	// идущий запрос токена, nil - никто не запрашивает
+	refresh *tokenRefresh
+	now     func() time.Time
+}
+
+// tokenRefresh - запрос токена, результат которого ждут остальные вызовы Token
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// TokenEndpointError - сервер отказал в выдаче токена, Code - код из RFC 6749, 5.2
+type TokenEndpointError struct {
+	Code        string
+	Description string
+}
+
+func (e *TokenEndpointError) Error() string {
+	if e.Description == "" {
+		return "token endpoint: " + e.Code
+	}
+	return fmt.Sprintf("token endpoint: %s: %s", e.Code, e.Description)
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	for {
+		c.mu.Lock()
+		refreshBefore := c.RefreshBefore
+		if refreshBefore <= 0 {
+			refreshBefore = defaultRefreshBefore
+		}
+		if c.token != "" && c.clock().Add(refreshBefore).Before(c.expiresAt) {
+			token := c.token
+			c.mu.Unlock()
+			return token, nil
+		}
+
+		refresh := c.refresh
+		if refresh == nil {
+			refresh = &tokenRefresh{done: make(chan struct{})}
+			c.refresh = refresh
+			c.mu.Unlock()
+			return c.fetchShared(ctx, refresh)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-refresh.done:
+			// запрос шёл с чужим ctx: если прервался он, запрашиваем сами
+			if refresh.err == nil || !isContextError(refresh.err) {
+				return refresh.token, refresh.err
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// fetchShared запрашивает токен и отдаёт результат всем, кто ждёт refresh
+func (c *ClientCredentials) fetchShared(ctx context.Context, refresh *tokenRefresh) (string, error) {
+	now := c.clock()
+	token, expiresIn, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	if err == nil {
+		c.token, c.expiresAt = token, now.Add(expiresIn)
+	}
+	c.refresh = nil
+	c.mu.Unlock()
+
+	refresh.token, refresh.err = token, err
+	close(refresh.done)
+	return token, err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Expire забывает токен, следующий Token получит новый
+func (c *ClientCredentials) Expire(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *ClientCredentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.ClientID)
+	form.Set("client_secret", c.ClientSecret)
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	req, err := http.NewRequest("POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("unknown error %s", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = client
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", 0, ctx.Err()
+		}
+		return "", 0, fmt.Errorf("token endpoint: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("token endpoint: cant read response: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}{}
+		if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == "" {
+			return "", 0, fmt.Errorf("token endpoint: unexpected status %s", resp.Status)
+		}
+		return "", 0, &TokenEndpointError{Code: errResp.Error, Description: errResp.ErrorDescription}
+	}
+
+	tokenResp := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, fmt.Errorf("token endpoint: cant unpack token json: %s", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("token endpoint: no access_token in response")
+	}
+	if tokenResp.ExpiresIn <= 0 {
+		return tokenResp.AccessToken, defaultExpiresIn, nil
+	}
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
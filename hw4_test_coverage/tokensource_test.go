@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akurin/golang-webservices/hw4_test_coverage/searcher"
+	"github.com/akurin/golang-webservices/hw4_test_coverage/token"
+)
+
+var testTokenClients = map[string]token.Client{
+	"full":  {Secret: "full-secret", Scopes: []string{token.ScopeReadUsers, token.ScopeReadAbout}},
+	"users": {Secret: "users-secret", Scopes: []string{token.ScopeReadUsers}},
+}
+
+// newTokenServer - сервер поиска, как его запускает searcher_server с -token-secret
+func newTokenServer(t *testing.T, signer *token.Signer) (*httptest.Server, *int32) {
+	users, err := searcher.LoadDataset("dataset.xml")
+	if err != nil {
+		t.Fatalf("cant load dataset: %s", err)
+	}
+
+	issued := new(int32)
+	endpoint := &token.Endpoint{Signer: signer, Clients: testTokenClients, TTL: time.Hour}
+
+	mux := http.NewServeMux()
+	mux.Handle("/token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(issued, 1)
+		endpoint.ServeHTTP(w, r)
+	}))
+	mux.Handle("/", token.Middleware(signer, []string{token.ScopeReadUsers}, searcher.NewServer(users, nil)))
+	return httptest.NewServer(mux), issued
+}
+
+func Test_Client_Token_Errors(t *testing.T) {
+	signer := token.NewSigner([]byte("token-secret"))
+	server, _ := newTokenServer(t, signer)
+	defer server.Close()
+
+	full, _ := signer.Issue("full", []string{token.ScopeReadUsers, token.ScopeReadAbout}, time.Hour)
+	usersOnly, _ := signer.Issue("users", []string{token.ScopeReadUsers}, time.Hour)
+	aboutOnly, _ := signer.Issue("about", []string{token.ScopeReadAbout}, time.Hour)
+	expired, _ := signer.Issue("full", []string{token.ScopeReadUsers}, -time.Minute)
+	forged, _ := token.NewSigner([]byte("guess")).Issue("full", []string{token.ScopeReadUsers}, time.Hour)
+
+	tests := []struct {
+		name  string
+		token string
+		req   SearchRequest
+		err   error
+	}{
+		{"full", full, SearchRequest{Limit: 1, Fields: []string{"About"}}, nil},
+		{"users only", usersOnly, SearchRequest{Limit: 1, Filter: "age>30"}, nil},
+		{"expired", expired, SearchRequest{Limit: 1}, ErrTokenExpired},
+		{"forged", forged, SearchRequest{Limit: 1}, ErrBadToken},
+		{"tampered", full[:len(full)-2] + "AA", SearchRequest{Limit: 1}, ErrBadToken},
+		{"no read:users", aboutOnly, SearchRequest{Limit: 1}, ErrInsufficientScope},
+		{"about field", usersOnly, SearchRequest{Limit: 1, Fields: []string{"About"}}, ErrInsufficientScope},
+		{"about filter", usersOnly, SearchRequest{Limit: 1, Filter: "about~dolor"}, ErrInsufficientScope},
+		{"about sort", usersOnly, SearchRequest{Limit: 1, Sort: []SortField{{Field: "about"}}}, ErrInsufficientScope},
+	}
+
+	for _, test := range tests {
+		client := &SearchClient{AccessToken: test.token, URL: server.URL}
+		_, err := client.FindUsers(test.req)
+		if !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+
+	client := &SearchClient{AccessToken: usersOnly, URL: server.URL}
+	resp, err := client.FindUsers(SearchRequest{Limit: 25})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, user := range resp.Users {
+		if user.About != "" || user.Name == "" || user.Email == "" {
+			t.Errorf("expected user without About, got %+v", user)
+		}
+	}
+
+	// слово есть только в About первого пользователя, без read:about по нему не ищется
+	const aboutWord = "Nulla cillum enim"
+	for _, test := range []struct {
+		token string
+		want  int
+	}{{full, 1}, {usersOnly, 0}} {
+		client := &SearchClient{AccessToken: test.token, URL: server.URL}
+		resp, err := client.FindUsers(SearchRequest{Limit: 25, Query: aboutWord})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(resp.Users) != test.want {
+			t.Errorf("query %q: got %d users, want %d", aboutWord, len(resp.Users), test.want)
+		}
+	}
+}
+
+func Test_ClientCredentials_Refreshes_Token(t *testing.T) {
+	server, issued := newTokenServer(t, token.NewSigner([]byte("token-secret")))
+	defer server.Close()
+
+	now := time.Now()
+	source := &ClientCredentials{
+		TokenURL:     server.URL + "/token",
+		ClientID:     "users",
+		ClientSecret: "users-secret",
+		now:          func() time.Time { return now },
+	}
+	client := &SearchClient{URL: server.URL, TokenSource: source}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.FindUsers(SearchRequest{Limit: 1}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if got := atomic.LoadInt32(issued); got != 1 {
+		t.Errorf("issued %d tokens, want 1", got)
+	}
+
+	now = now.Add(time.Hour - defaultRefreshBefore)
+	if _, err := client.FindUsers(SearchRequest{Limit: 1}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := atomic.LoadInt32(issued); got != 2 {
+		t.Errorf("issued %d tokens, want 2", got)
+	}
+
+	source.Scopes = []string{token.ScopeReadAbout}
+	source.Expire(source.token)
+	_, err := client.FindUsers(SearchRequest{Limit: 1})
+	var endpointErr *TokenEndpointError
+	if !errors.As(err, &endpointErr) || endpointErr.Code != "invalid_scope" {
+		t.Errorf("Expected invalid_scope, got: %v", err)
+	}
+
+	source = &ClientCredentials{TokenURL: server.URL + "/token", ClientID: "users", ClientSecret: "wrong"}
+	if _, err := source.Token(context.Background()); !errors.As(err, &endpointErr) || endpointErr.Code != "invalid_client" {
+		t.Errorf("Expected invalid_client, got: %v", err)
+	}
+}
+
+func Test_Client_Replaces_Token_Expired_Early(t *testing.T) {
+	signer := token.NewSigner([]byte("token-secret"))
+	server, _ := newTokenServer(t, signer)
+	defer server.Close()
+
+	// токены истекли по часам сервера, хотя клиент считает их свежими
+	fetched, expiredTokens := int32(0), int32(1)
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl := time.Hour
+		if atomic.AddInt32(&fetched, 1) <= atomic.LoadInt32(&expiredTokens) {
+			ttl = -time.Minute
+		}
+		value, _ := signer.Issue("users", []string{token.ScopeReadUsers}, ttl)
+		json.NewEncoder(w).Encode(token.TokenResponse{AccessToken: value, TokenType: "Bearer", ExpiresIn: 3600})
+	}))
+	defer tokenServer.Close()
+
+	client := &SearchClient{URL: server.URL, TokenSource: &ClientCredentials{TokenURL: tokenServer.URL}}
+	if _, err := client.FindUsers(SearchRequest{Limit: 1}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := atomic.LoadInt32(&fetched); got != 2 {
+		t.Errorf("fetched %d tokens, want 2", got)
+	}
+
+	// если и новый токен истёк, это ошибка, а не бесконечный цикл
+	atomic.StoreInt32(&fetched, 0)
+	atomic.StoreInt32(&expiredTokens, 100)
+	client.TokenSource = &ClientCredentials{TokenURL: tokenServer.URL}
+	if _, err := client.FindUsers(SearchRequest{Limit: 1}); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got: %v", err)
+	}
+	if got := atomic.LoadInt32(&fetched); got != 2 {
+		t.Errorf("fetched %d tokens, want 2", got)
+	}
+}
+
+func Test_ClientCredentials_Waiters_Honor_Context(t *testing.T) {
+	release := make(chan struct{})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		// expires_in нет
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "slow-token", "token_type": "bearer"})
+	}))
+	defer server.Close()
+
+	source := &ClientCredentials{TokenURL: server.URL}
+
+	leader := make(chan error, 1)
+	go func() {
+		_, err := source.Token(context.Background())
+		leader <- err
+	}()
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// медленный сервер не держит ни тех, чей ctx истёк, ни Expire
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := source.Token(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Token error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	expired := make(chan struct{})
+	go func() {
+		source.Expire("other-token")
+		close(expired)
+	}()
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("Expire waits for the token request")
+	}
+
+	waiters := make(chan string, 5)
+	for i := 0; i < cap(waiters); i++ {
+		go func() {
+			token, _ := source.Token(context.Background())
+			waiters <- token
+		}()
+	}
+
+	close(release)
+	if err := <-leader; err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for i := 0; i < cap(waiters); i++ {
+		if token := <-waiters; token != "slow-token" {
+			t.Errorf("waiter got %q, want slow-token", token)
+		}
+	}
+
+	// без expires_in токен живёт defaultExpiresIn, а не запрашивается каждый раз
+	if token, err := source.Token(context.Background()); err != nil || token != "slow-token" {
+		t.Errorf("Token = %q, %v, want slow-token", token, err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("requested %d tokens, want 1", got)
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
-
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var _ = strconv.Atoi // build fails if strconv is imported and not used
+var _ = time.Parse   // same for time
 
 func writeHeader(w http.ResponseWriter, result handleResult) {
 	if result.err == nil {
@@ -44,21 +50,240 @@ type Body struct {
 	Response interface{} "json:\"response,omitempty\""
 }
 
+// requestParams - параметры запроса из формы или из JSON-тела
+type requestParams struct {
+	values url.Values
+	// в форме список можно передать одной строкой через запятую
+	commaLists bool
+}
+
+// readParams читает JSON-тело, если Content-Type - application/json, иначе форму.
+// Вложенные объекты JSON дают параметры через точку: {"period": {"from": ...}} - period.from.
+// lists - параметры структуры, true у списков: значение JSON другой формы -
+// ошибка, а не пропущенный параметр
+func readParams(r *http.Request, lists map[string]bool) (requestParams, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		r.FormValue("") // разбирает форму, в том числе multipart
+		return requestParams{values: r.Form, commaLists: true}, nil
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return requestParams{}, err
+	}
+
+	values := url.Values{}
+	if err := flattenJSON(values, lists, "", body); err != nil {
+		return requestParams{}, err
+	}
+	return requestParams{values: values}, nil
+}
+
+// flattenJSON раскладывает объект в values. Неизвестные ключи пропускаются
+func flattenJSON(values url.Values, lists map[string]bool, prefix string, object map[string]interface{}) error {
+	for key, value := range object {
+		name := prefix + key
+		list, known := lists[name]
+
+		if _, isObject := value.(map[string]interface{}); !isObject && value != nil && hasNested(lists, name) {
+			return fmt.Errorf("%s: expected object", name)
+		}
+
+		switch value := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			if known {
+				return fmt.Errorf("%s: unexpected object", name)
+			}
+			if err := flattenJSON(values, lists, name+".", value); err != nil {
+				return err
+			}
+		case []interface{}:
+			if !known {
+				continue
+			}
+			if !list {
+				return fmt.Errorf("%s: unexpected array", name)
+			}
+			for _, item := range value {
+				text, ok := jsonScalar(item)
+				if !ok {
+					return fmt.Errorf("%s: list item must be scalar", name)
+				}
+				values.Add(name, text)
+			}
+		default:
+			if text, ok := jsonScalar(value); ok && known {
+				values.Add(name, text)
+			}
+		}
+	}
+	return nil
+}
+
+// hasNested сообщает, есть ли параметры вложенной структуры name
+func hasNested(lists map[string]bool, name string) bool {
+	for param := range lists {
+		if strings.HasPrefix(param, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonScalar возвращает значение JSON так, как оно пришло бы в форме; null - параметра нет
+func jsonScalar(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	return "", false
+}
+
+func (p requestParams) value(name string) string {
+	return p.values.Get(name)
+}
+
+// list собирает элементы списка. Массив JSON берётся как есть. В форме
+// значения name делятся по запятым, а значения name[] берутся как есть -
+// так список передаёт сгенерированный клиент
+func (p requestParams) list(name string) []string {
+	if !p.commaLists {
+		return p.values[name]
+	}
+
+	var result []string
+	for _, value := range p.values[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return append(result, p.values[name+"[]"]...)
+}
+
+func parseInts(values []string) ([]int, error) {
+	result := make([]int, 0, len(values))
+	for _, value := range values {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, parsed)
+	}
+	return result, nil
+}
+
+func allOf(values []string, valid map[string]bool) bool {
+	for _, value := range values {
+		if !valid[value] {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientOptions - настройки сгенерированных клиентов
+type ClientOptions struct {
+	// если не задан, используется http.DefaultClient
+	HTTPClient *http.Client
+	// значение заголовка X-Auth для методов с авторизацией
+	AuthToken string
+}
+
+// callAPI выполняет запрос к методу API и раскладывает ответ {"error", "response"}:
+// ошибка сервера возвращается как ApiError с кодом ответа, результат - в response
+func callAPI(ctx context.Context, baseURL string, opts ClientOptions, method, path string, auth bool, values url.Values, response interface{}) error {
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, baseURL+path+"?"+values.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(method, baseURL+path, strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if auth {
+		req.Header.Set("X-Auth", opts.AuthToken)
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error    string          "json:\"error\""
+		Response json.RawMessage "json:\"response\""
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return ApiError{
+			HTTPStatus: resp.StatusCode,
+			Err:        fmt.Errorf("cant unpack response: %s", err),
+		}
+	}
+	if body.Error != "" {
+		return ApiError{
+			HTTPStatus: resp.StatusCode,
+			Err:        errors.New(body.Error),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ApiError{
+			HTTPStatus: resp.StatusCode,
+			Err:        fmt.Errorf("unexpected status %s", resp.Status),
+		}
+	}
+	if len(body.Response) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body.Response, response)
+}
 
 func (api *MyApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var handleResult handleResult
 	switch r.URL.Path {
+	case "/openapi.json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(openAPIMyApi))
+		return
 	case "/user/profile":
 		var param ProfileParams
-		formValueLogin := r.FormValue("login")
-		if formValueLogin == "" {
+		params, err := readParams(r, map[string]bool{"login": false})
+		if err != nil {
+			handleResult.err = ApiError{
+				HTTPStatus: 400,
+				Err:        fmt.Errorf("bad json body"),
+			}
+			break
+		}
+		valueLogin := params.value("login")
+		if valueLogin == "" {
 			handleResult.err = ApiError{
 				HTTPStatus: http.StatusBadRequest,
 				Err:        fmt.Errorf("login must me not empty"),
 			}
 			break
 		}
-		param.Login = formValueLogin
+		param.Login = valueLogin
 
 		response, err := api.Profile(r.Context(), param)
 
@@ -76,53 +301,61 @@ func (api *MyApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if r.Header.Get("X-Auth") != "100500" {
-					handleResult.err = ApiError{
-						HTTPStatus: 403,
-						Err:        fmt.Errorf("unauthorized"),
-					}
-					break
-				}
+			handleResult.err = ApiError{
+				HTTPStatus: 403,
+				Err:        fmt.Errorf("unauthorized"),
+			}
+			break
+		}
 		var param CreateParams
-		formValueLogin := r.FormValue("login")
-		if formValueLogin == "" {
+		params, err := readParams(r, map[string]bool{"login": false, "full_name": false, "status": false, "age": false})
+		if err != nil {
+			handleResult.err = ApiError{
+				HTTPStatus: 400,
+				Err:        fmt.Errorf("bad json body"),
+			}
+			break
+		}
+		valueLogin := params.value("login")
+		if valueLogin == "" {
 			handleResult.err = ApiError{
 				HTTPStatus: http.StatusBadRequest,
 				Err:        fmt.Errorf("login must me not empty"),
 			}
 			break
 		}
-		if len(formValueLogin) < 10 {
+		if len(valueLogin) < 10 {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
 				Err:        fmt.Errorf("login len must be >= 10"),
 			}
 			break
 		}
-		param.Login = formValueLogin
+		param.Login = valueLogin
 
-		formValueName := r.FormValue("full_name")
-		param.Name = formValueName
+		valueName := params.value("full_name")
+		param.Name = valueName
 
-		formValueStatus := r.FormValue("status")
-		if formValueStatus == "" {
-			formValueStatus = "user"
+		valueStatus := params.value("status")
+		if valueStatus == "" {
+			valueStatus = "user"
 		}
-		statusValidEnumValues := map[string]bool{
-			"user": true,
+		validEnumValuesStatus := map[string]bool{
+			"user":      true,
 			"moderator": true,
-			"admin": true,
-}
-		if !statusValidEnumValues[formValueStatus] {
+			"admin":     true,
+		}
+		if !validEnumValuesStatus[valueStatus] {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
 				Err:        fmt.Errorf("status must be one of [user, moderator, admin]"),
 			}
 			break
 		}
-		param.Status = formValueStatus
+		param.Status = valueStatus
 
-		formValueAge := r.FormValue("age")
-		parsedAge, err := strconv.Atoi(formValueAge)
+		valueAge := params.value("age")
+		parsedAge, err := strconv.Atoi(valueAge)
 		if err != nil {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
@@ -165,9 +398,385 @@ func (api *MyApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	writeBody(w, handleResult)
 }
 
+// openAPIMyApi - описание API MyApi в формате OpenAPI 3, отдаётся по /openapi.json
+const openAPIMyApi = `{
+  "components": {
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "error"
+        ]
+      },
+      "NewUser": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "integer",
+            "format": "uint64",
+            "minimum": 0
+          }
+        },
+        "required": [
+          "id"
+        ]
+      },
+      "User": {
+        "type": "object",
+        "properties": {
+          "full_name": {
+            "type": "string"
+          },
+          "id": {
+            "type": "integer",
+            "format": "uint64",
+            "minimum": 0
+          },
+          "login": {
+            "type": "string"
+          },
+          "status": {
+            "type": "integer"
+          }
+        },
+        "required": [
+          "id",
+          "login",
+          "full_name",
+          "status"
+        ]
+      }
+    },
+    "securitySchemes": {
+      "auth": {
+        "in": "header",
+        "name": "X-Auth",
+        "type": "apiKey"
+      }
+    }
+  },
+  "info": {
+    "title": "MyApi",
+    "version": "1.0.0"
+  },
+  "openapi": "3.0.3",
+  "paths": {
+    "/user/create": {
+      "post": {
+        "operationId": "Create",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "age": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "maximum": 128
+                  },
+                  "full_name": {
+                    "type": "string"
+                  },
+                  "login": {
+                    "type": "string",
+                    "minLength": 10
+                  },
+                  "status": {
+                    "type": "string",
+                    "enum": [
+                      "user",
+                      "moderator",
+                      "admin"
+                    ],
+                    "default": "user"
+                  }
+                },
+                "required": [
+                  "login"
+                ]
+              }
+            },
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "age": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "maximum": 128
+                  },
+                  "full_name": {
+                    "type": "string"
+                  },
+                  "login": {
+                    "type": "string",
+                    "minLength": 10
+                  },
+                  "status": {
+                    "type": "string",
+                    "enum": [
+                      "user",
+                      "moderator",
+                      "admin"
+                    ],
+                    "default": "user"
+                  }
+                },
+                "required": [
+                  "login"
+                ]
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "auth": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "error": {
+                      "type": "string"
+                    },
+                    "response": {
+                      "$ref": "#/components/schemas/NewUser"
+                    }
+                  },
+                  "required": [
+                    "error",
+                    "response"
+                  ]
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "bad params",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "403": {
+            "description": "unauthorized",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/user/profile": {
+      "get": {
+        "operationId": "ProfileGet",
+        "parameters": [
+          {
+            "name": "login",
+            "in": "query",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "error": {
+                      "type": "string"
+                    },
+                    "response": {
+                      "$ref": "#/components/schemas/User"
+                    }
+                  },
+                  "required": [
+                    "error",
+                    "response"
+                  ]
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "bad params",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          }
+        }
+      },
+      "post": {
+        "operationId": "ProfilePost",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "login": {
+                    "type": "string"
+                  }
+                },
+                "required": [
+                  "login"
+                ]
+              }
+            },
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "login": {
+                    "type": "string"
+                  }
+                },
+                "required": [
+                  "login"
+                ]
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "error": {
+                      "type": "string"
+                    },
+                    "response": {
+                      "$ref": "#/components/schemas/User"
+                    }
+                  },
+                  "required": [
+                    "error",
+                    "response"
+                  ]
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "bad params",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// MyApiClient - клиент API MyApi
+type MyApiClient struct {
+	baseURL string
+	opts    ClientOptions
+}
+
+// NewMyApiClient создаёт клиент API, baseURL - адрес сервера, например http://127.0.0.1:8080
+func NewMyApiClient(baseURL string, opts ClientOptions) *MyApiClient {
+	return &MyApiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		opts:    opts,
+	}
+}
+
+func (c *MyApiClient) Profile(ctx context.Context, in ProfileParams) (*User, error) {
+	values := url.Values{}
+	values.Set("login", in.Login)
+	var response *User
+	err := callAPI(ctx, c.baseURL, c.opts, "GET", "/user/profile", false, values, &response)
+	return response, err
+}
+
+func (c *MyApiClient) Create(ctx context.Context, in CreateParams) (*NewUser, error) {
+	values := url.Values{}
+	values.Set("login", in.Login)
+	values.Set("full_name", in.Name)
+	values.Set("status", in.Status)
+	values.Set("age", strconv.Itoa(in.Age))
+	var response *NewUser
+	err := callAPI(ctx, c.baseURL, c.opts, "POST", "/user/create", true, values, &response)
+	return response, err
+}
+
 func (api *OtherApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var handleResult handleResult
 	switch r.URL.Path {
+	case "/openapi.json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(openAPIOtherApi))
+		return
 	case "/user/create":
 		if r.Method != "POST" {
 			handleResult.err = ApiError{
@@ -177,53 +786,61 @@ func (api *OtherApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if r.Header.Get("X-Auth") != "100500" {
-					handleResult.err = ApiError{
-						HTTPStatus: 403,
-						Err:        fmt.Errorf("unauthorized"),
-					}
-					break
-				}
+			handleResult.err = ApiError{
+				HTTPStatus: 403,
+				Err:        fmt.Errorf("unauthorized"),
+			}
+			break
+		}
 		var param OtherCreateParams
-		formValueUsername := r.FormValue("username")
-		if formValueUsername == "" {
+		params, err := readParams(r, map[string]bool{"username": false, "account_name": false, "class": false, "level": false})
+		if err != nil {
+			handleResult.err = ApiError{
+				HTTPStatus: 400,
+				Err:        fmt.Errorf("bad json body"),
+			}
+			break
+		}
+		valueUsername := params.value("username")
+		if valueUsername == "" {
 			handleResult.err = ApiError{
 				HTTPStatus: http.StatusBadRequest,
 				Err:        fmt.Errorf("username must me not empty"),
 			}
 			break
 		}
-		if len(formValueUsername) < 3 {
+		if len(valueUsername) < 3 {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
 				Err:        fmt.Errorf("username len must be >= 3"),
 			}
 			break
 		}
-		param.Username = formValueUsername
+		param.Username = valueUsername
 
-		formValueName := r.FormValue("account_name")
-		param.Name = formValueName
+		valueName := params.value("account_name")
+		param.Name = valueName
 
-		formValueClass := r.FormValue("class")
-		if formValueClass == "" {
-			formValueClass = "warrior"
+		valueClass := params.value("class")
+		if valueClass == "" {
+			valueClass = "warrior"
 		}
-		classValidEnumValues := map[string]bool{
-			"warrior": true,
+		validEnumValuesClass := map[string]bool{
+			"warrior":  true,
 			"sorcerer": true,
-			"rouge": true,
-}
-		if !classValidEnumValues[formValueClass] {
+			"rouge":    true,
+		}
+		if !validEnumValuesClass[valueClass] {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
 				Err:        fmt.Errorf("class must be one of [warrior, sorcerer, rouge]"),
 			}
 			break
 		}
-		param.Class = formValueClass
+		param.Class = valueClass
 
-		formValueLevel := r.FormValue("level")
-		parsedLevel, err := strconv.Atoi(formValueLevel)
+		valueLevel := params.value("level")
+		parsedLevel, err := strconv.Atoi(valueLevel)
 		if err != nil {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
@@ -265,3 +882,217 @@ func (api *OtherApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	writeHeader(w, handleResult)
 	writeBody(w, handleResult)
 }
+
+// openAPIOtherApi - описание API OtherApi в формате OpenAPI 3, отдаётся по /openapi.json
+const openAPIOtherApi = `{
+  "components": {
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "error"
+        ]
+      },
+      "OtherUser": {
+        "type": "object",
+        "properties": {
+          "full_name": {
+            "type": "string"
+          },
+          "id": {
+            "type": "integer",
+            "format": "uint64",
+            "minimum": 0
+          },
+          "level": {
+            "type": "integer"
+          },
+          "login": {
+            "type": "string"
+          }
+        },
+        "required": [
+          "id",
+          "login",
+          "full_name",
+          "level"
+        ]
+      }
+    },
+    "securitySchemes": {
+      "auth": {
+        "in": "header",
+        "name": "X-Auth",
+        "type": "apiKey"
+      }
+    }
+  },
+  "info": {
+    "title": "OtherApi",
+    "version": "1.0.0"
+  },
+  "openapi": "3.0.3",
+  "paths": {
+    "/user/create": {
+      "post": {
+        "operationId": "Create",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "account_name": {
+                    "type": "string"
+                  },
+                  "class": {
+                    "type": "string",
+                    "enum": [
+                      "warrior",
+                      "sorcerer",
+                      "rouge"
+                    ],
+                    "default": "warrior"
+                  },
+                  "level": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "maximum": 50
+                  },
+                  "username": {
+                    "type": "string",
+                    "minLength": 3
+                  }
+                },
+                "required": [
+                  "username"
+                ]
+              }
+            },
+            "application/x-www-form-urlencoded": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "account_name": {
+                    "type": "string"
+                  },
+                  "class": {
+                    "type": "string",
+                    "enum": [
+                      "warrior",
+                      "sorcerer",
+                      "rouge"
+                    ],
+                    "default": "warrior"
+                  },
+                  "level": {
+                    "type": "integer",
+                    "minimum": 1,
+                    "maximum": 50
+                  },
+                  "username": {
+                    "type": "string",
+                    "minLength": 3
+                  }
+                },
+                "required": [
+                  "username"
+                ]
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "auth": []
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "error": {
+                      "type": "string"
+                    },
+                    "response": {
+                      "$ref": "#/components/schemas/OtherUser"
+                    }
+                  },
+                  "required": [
+                    "error",
+                    "response"
+                  ]
+                }
+              }
+            }
+          },
+          "400": {
+            "description": "bad params",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "403": {
+            "description": "unauthorized",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Error"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// OtherApiClient - клиент API OtherApi
+type OtherApiClient struct {
+	baseURL string
+	opts    ClientOptions
+}
+
+// NewOtherApiClient создаёт клиент API, baseURL - адрес сервера, например http://127.0.0.1:8080
+func NewOtherApiClient(baseURL string, opts ClientOptions) *OtherApiClient {
+	return &OtherApiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		opts:    opts,
+	}
+}
+
+func (c *OtherApiClient) Create(ctx context.Context, in OtherCreateParams) (*OtherUser, error) {
+	values := url.Values{}
+	values.Set("username", in.Username)
+	values.Set("account_name", in.Name)
+	values.Set("class", in.Class)
+	values.Set("level", strconv.Itoa(in.Level))
+	var response *OtherUser
+	err := callAPI(ctx, c.baseURL, c.opts, "POST", "/user/create", true, values, &response)
+	return response, err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func expectApiError(t *testing.T, err error, status int, message string) {
+	t.Helper()
+	expected := ApiError{HTTPStatus: status, Err: errors.New(message)}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("unexpected error\nGot: %#v\nExpected: %#v", err, expected)
+	}
+}
+
+func TestMyApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+	defer ts.Close()
+
+	ctx := context.Background()
+	api := NewMyApiClient(ts.URL+"/", ClientOptions{HTTPClient: client, AuthToken: "100500"})
+
+	user, err := api.Profile(ctx, ProfileParams{Login: "rvasily"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedUser := &User{ID: 42, Login: "rvasily", FullName: "Vasily Romanov", Status: statusAdmin}
+	if !reflect.DeepEqual(user, expectedUser) {
+		t.Errorf("unexpected user\nGot: %#v\nExpected: %#v", user, expectedUser)
+	}
+
+	_, err = api.Profile(ctx, ProfileParams{})
+	expectApiError(t, err, http.StatusBadRequest, "login must me not empty")
+
+	_, err = api.Profile(ctx, ProfileParams{Login: "not_exist_user"})
+	expectApiError(t, err, http.StatusNotFound, "user not exist")
+
+	_, err = api.Profile(ctx, ProfileParams{Login: "bad_user"})
+	expectApiError(t, err, http.StatusInternalServerError, "bad user")
+
+	// Status не задан - сервер подставляет default
+	created, err := api.Create(ctx, CreateParams{Login: "client_user", Name: "Client User", Age: 0})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.ID != 43 {
+		t.Errorf("expected id 43, got %d", created.ID)
+	}
+
+	user, err = api.Profile(ctx, ProfileParams{Login: "client_user"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedUser = &User{ID: 43, Login: "client_user", FullName: "Client User", Status: statusUser}
+	if !reflect.DeepEqual(user, expectedUser) {
+		t.Errorf("unexpected user\nGot: %#v\nExpected: %#v", user, expectedUser)
+	}
+
+	_, err = api.Create(ctx, CreateParams{Login: "client_user", Status: "admin"})
+	expectApiError(t, err, http.StatusConflict, "user client_user exist")
+
+	_, err = api.Create(ctx, CreateParams{Login: "short"})
+	expectApiError(t, err, http.StatusBadRequest, "login len must be >= 10")
+
+	_, err = api.Create(ctx, CreateParams{Login: "client_user2", Status: "root"})
+	expectApiError(t, err, http.StatusBadRequest, "status must be one of [user, moderator, admin]")
+
+	_, err = api.Create(ctx, CreateParams{Login: "client_user2", Age: 200})
+	expectApiError(t, err, http.StatusBadRequest, "age must be <= 128")
+
+	anonymous := NewMyApiClient(ts.URL, ClientOptions{HTTPClient: client})
+	_, err = anonymous.Create(ctx, CreateParams{Login: "client_user2"})
+	expectApiError(t, err, http.StatusForbidden, "unauthorized")
+
+	// авторизация нужна только методам с auth
+	if _, err := anonymous.Profile(ctx, ProfileParams{Login: "rvasily"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOtherApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewOtherApi())
+	defer ts.Close()
+
+	api := NewOtherApiClient(ts.URL, ClientOptions{HTTPClient: client, AuthToken: "100500"})
+
+	user, err := api.Create(context.Background(), OtherCreateParams{Username: "I3apBap", Name: "Vasily", Level: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &OtherUser{ID: 12, Login: "I3apBap", FullName: "Vasily", Level: 1}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("unexpected user\nGot: %#v\nExpected: %#v", user, expected)
+	}
+
+	_, err = api.Create(context.Background(), OtherCreateParams{Username: "I3apBap", Class: "barbarian", Level: 1})
+	expectApiError(t, err, http.StatusBadRequest, "class must be one of [warrior, sorcerer, rouge]")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// generateClient пишет клиент для методов receiver: <receiver>Client с методами
+// тех же имён, параметры кодируются теми же именами, что читает ServeHTTP
+func generateClient(out io.Writer, receiver string, funcDecls []ast.FuncDecl, structs map[string]ast.TypeSpec) {
+	clientName := receiver + "Client"
+
+	fmt.Fprintf(out, `
+// %s - клиент API %s
+type %s struct {
+	baseURL string
+	opts    ClientOptions
+}
+
+// New%s создаёт клиент API, baseURL - адрес сервера, например http://127.0.0.1:8080
+func New%s(baseURL string, opts ClientOptions) *%s {
+	return &%s{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		opts:    opts,
+	}
+}
+`, clientName, receiver, clientName, clientName, clientName, clientName, clientName)
+
+	for _, funcDecl := range funcDecls {
+		funcTag := parseFuncTag(funcDecl)
+
+		// без метода в метке сервер принимает любой, клиент ходит GET
+		method := http.MethodGet
+		if funcTag.Method != "" {
+			method = strings.ToUpper(funcTag.Method)
+		}
+
+		paramStructName := funcDecl.Type.Params.List[1].Type.(*ast.Ident).Name
+		resultType := typeName(funcDecl.Type.Results.List[0].Type)
+		if resultType == "" {
+			resultType = "interface{}"
+		}
+
+		fmt.Fprintf(out, `
+func (c *%s) %s(ctx context.Context, in %s) (%s, error) {
+	values := url.Values{}
+`, clientName, funcDecl.Name.Name, paramStructName, resultType)
+
+		for _, field := range collectFields(structs[paramStructName], structs, nil, "") {
+			generateParamEncode(out, field)
+		}
+
+		fmt.Fprintf(out, `	var response %s
+	err := callAPI(ctx, c.baseURL, c.opts, %q, %q, %t, values, &response)
+	return response, err
+}
+`, resultType, method, funcTag.Url, funcTag.Auth)
+	}
+}
+
+// generateParamEncode кладёт поле в values. Поле-значение передаётся всегда,
+// даже нулевое, пустой указатель не передаётся - для него сервер подставит
+// default. Элементы списка идут как name[], их сервер не делит по запятым
+func generateParamEncode(out io.Writer, field paramField) {
+	fieldType := strings.TrimPrefix(field.typeName, "*")
+	isPointer := fieldType != field.typeName
+	parser := fieldParsers[fieldType]
+	value := "in." + strings.Join(field.path, ".")
+
+	if parser.list {
+		fmt.Fprintf(out, `	for _, item := range %s {
+		values.Add(%q, %s)
+	}
+`, value, field.paramName+"[]", fmt.Sprintf(parser.format, "item"))
+		return
+	}
+
+	if !isPointer {
+		fmt.Fprintf(out, "	values.Set(%q, %s)\n", field.paramName, fmt.Sprintf(parser.format, value))
+		return
+	}
+	fmt.Fprintf(out, `	if %s != nil {
+		values.Set(%q, %s)
+	}
+`, value, field.paramName, fmt.Sprintf(parser.format, "*"+value))
+}
@@ -1,27 +1,40 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
 	"go/token"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const topDeclarations = `
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var _ = strconv.Atoi // build fails if strconv is imported and not used
+var _ = time.Parse   // same for time
 
 func writeHeader(w http.ResponseWriter, result handleResult) {
 	if result.err == nil {
@@ -56,33 +69,261 @@ type Body struct {
 	Error    string      "json:\"error\""
 	Response interface{} "json:\"response,omitempty\""
 }
-`
 
-func main() {
-	inputPath := os.Args[1]
-	outputPath := os.Args[2]
+// requestParams - параметры запроса из формы или из JSON-тела
+type requestParams struct {
+	values url.Values
+	// в форме список можно передать одной строкой через запятую
+	commaLists bool
+}
 
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, inputPath, nil, parser.ParseComments)
-	if err != nil {
-		log.Fatal(err)
+// readParams читает JSON-тело, если Content-Type - application/json, иначе форму.
+// Вложенные объекты JSON дают параметры через точку: {"period": {"from": ...}} - period.from.
+// lists - параметры структуры, true у списков: значение JSON другой формы -
+// ошибка, а не пропущенный параметр
+func readParams(r *http.Request, lists map[string]bool) (requestParams, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		r.FormValue("") // разбирает форму, в том числе multipart
+		return requestParams{values: r.Form, commaLists: true}, nil
 	}
 
-	funcsToServe := selectFuncsToServe(*node)
-	groupedFuncsByReceiver := groupFuncsByReceiver(funcsToServe)
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return requestParams{}, err
+	}
+
+	values := url.Values{}
+	if err := flattenJSON(values, lists, "", body); err != nil {
+		return requestParams{}, err
+	}
+	return requestParams{values: values}, nil
+}
+
+// flattenJSON раскладывает объект в values. Неизвестные ключи пропускаются
+func flattenJSON(values url.Values, lists map[string]bool, prefix string, object map[string]interface{}) error {
+	for key, value := range object {
+		name := prefix + key
+		list, known := lists[name]
+
+		if _, isObject := value.(map[string]interface{}); !isObject && value != nil && hasNested(lists, name) {
+			return fmt.Errorf("%s: expected object", name)
+		}
+
+		switch value := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			if known {
+				return fmt.Errorf("%s: unexpected object", name)
+			}
+			if err := flattenJSON(values, lists, name+".", value); err != nil {
+				return err
+			}
+		case []interface{}:
+			if !known {
+				continue
+			}
+			if !list {
+				return fmt.Errorf("%s: unexpected array", name)
+			}
+			for _, item := range value {
+				text, ok := jsonScalar(item)
+				if !ok {
+					return fmt.Errorf("%s: list item must be scalar", name)
+				}
+				values.Add(name, text)
+			}
+		default:
+			if text, ok := jsonScalar(value); ok && known {
+				values.Add(name, text)
+			}
+		}
+	}
+	return nil
+}
 
-	structs := selectStructs(*node)
+// hasNested сообщает, есть ли параметры вложенной структуры name
+func hasNested(lists map[string]bool, name string) bool {
+	for param := range lists {
+		if strings.HasPrefix(param, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonScalar возвращает значение JSON так, как оно пришло бы в форме; null - параметра нет
+func jsonScalar(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	return "", false
+}
+
+func (p requestParams) value(name string) string {
+	return p.values.Get(name)
+}
+
+// list собирает элементы списка. Массив JSON берётся как есть. В форме
+// значения name делятся по запятым, а значения name[] берутся как есть -
+// так список передаёт сгенерированный клиент
+func (p requestParams) list(name string) []string {
+	if !p.commaLists {
+		return p.values[name]
+	}
+
+	var result []string
+	for _, value := range p.values[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return append(result, p.values[name+"[]"]...)
+}
+
+func parseInts(values []string) ([]int, error) {
+	result := make([]int, 0, len(values))
+	for _, value := range values {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, parsed)
+	}
+	return result, nil
+}
+
+func allOf(values []string, valid map[string]bool) bool {
+	for _, value := range values {
+		if !valid[value] {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientOptions - настройки сгенерированных клиентов
+type ClientOptions struct {
+	// если не задан, используется http.DefaultClient
+	HTTPClient *http.Client
+	// значение заголовка X-Auth для методов с авторизацией
+	AuthToken string
+}
 
-	out, err := os.Create(outputPath)
+// callAPI выполняет запрос к методу API и раскладывает ответ {"error", "response"}:
+// ошибка сервера возвращается как ApiError с кодом ответа, результат - в response
+func callAPI(ctx context.Context, baseURL string, opts ClientOptions, method, path string, auth bool, values url.Values, response interface{}) error {
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, baseURL+path+"?"+values.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(method, baseURL+path, strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	req = req.WithContext(ctx)
+	if auth {
+		req.Header.Set("X-Auth", opts.AuthToken)
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error    string          "json:\"error\""
+		Response json.RawMessage "json:\"response\""
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return ApiError{
+			HTTPStatus: resp.StatusCode,
+			Err:        fmt.Errorf("cant unpack response: %s", err),
+		}
+	}
+	if body.Error != "" {
+		return ApiError{
+			HTTPStatus: resp.StatusCode,
+			Err:        errors.New(body.Error),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ApiError{
+			HTTPStatus: resp.StatusCode,
+			Err:        fmt.Errorf("unexpected status %s", resp.Status),
+		}
+	}
+	if len(body.Response) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body.Response, response)
+}
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		log.Fatalf("usage: %s api.go [more_api.go ...] api_handlers.go", os.Args[0])
+	}
+	// файлов с API может быть несколько, если это один пакет
+	inputPaths := os.Args[1 : len(os.Args)-1]
+	outputPath := os.Args[len(os.Args)-1]
+
+	var packageName string
+	var funcsToServe []ast.FuncDecl
+	structs := make(map[string]ast.TypeSpec)
+
+	fset := token.NewFileSet()
+	for _, inputPath := range inputPaths {
+		node, err := parser.ParseFile(fset, inputPath, nil, parser.ParseComments)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if packageName != "" && node.Name.Name != packageName {
+			log.Fatalf("%s: package %s, want %s", inputPath, node.Name.Name, packageName)
+		}
+		packageName = node.Name.Name
+
+		funcsToServe = append(funcsToServe, selectFuncsToServe(*node)...)
+		for name, spec := range selectStructs(*node) {
+			structs[name] = spec
+		}
 	}
+	groupedFuncsByReceiver := groupFuncsByReceiver(funcsToServe)
 
-	fmt.Fprintln(out, `package `+node.Name.Name)
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, `package `+packageName)
 	fmt.Fprintln(out)
-	fmt.Fprintln(out, topDeclarations)
+	io.WriteString(out, topDeclarations)
 
 	generateServeHTTP(out, groupedFuncsByReceiver, structs)
+
+	// шаблоны не следят за отступами, их выравнивает gofmt
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("generated code is invalid: %s", err)
+	}
+	if err := ioutil.WriteFile(outputPath, src, 0644); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func selectFuncsToServe(node ast.File) []ast.FuncDecl {
@@ -157,19 +398,30 @@ func selectStructs(node ast.File) map[string]ast.TypeSpec {
 	return result
 }
 
-func generateServeHTTP(out *os.File, groupedFuncsByReceiver map[string][]ast.FuncDecl, structs map[string]ast.TypeSpec) {
-	for receiver, funcDecls := range groupedFuncsByReceiver {
+func generateServeHTTP(out io.Writer, groupedFuncsByReceiver map[string][]ast.FuncDecl, structs map[string]ast.TypeSpec) {
+	// порядок обхода map случаен, а результат генерации должен быть одинаковым
+	var receivers []string
+	for receiver := range groupedFuncsByReceiver {
+		receivers = append(receivers, receiver)
+	}
+	sort.Strings(receivers)
+
+	for _, receiver := range receivers {
+		funcDecls := groupedFuncsByReceiver[receiver]
 		fmt.Fprintf(out, `
 func (api *%s) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var handleResult handleResult
 	switch r.URL.Path {
 `, receiver)
 
-		for _, funcDecl := range funcDecls {
-			_, comment := getFuncComment(funcDecl)
+		fmt.Fprintf(out, `	case "%s":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(openAPI%s))
+		return
+`, openAPIPath, receiver)
 
-			var funcTag FuncTag
-			_ = json.Unmarshal([]byte(comment), &funcTag)
+		for _, funcDecl := range funcDecls {
+			funcTag := parseFuncTag(funcDecl)
 
 			fmt.Fprintf(out, `	case "%s":
 `, funcTag.Url)
@@ -201,7 +453,7 @@ func (api *%s) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 			fmt.Fprintf(out, "		var param %s\n", paramStructName)
 
-			generateParamInit(out, s)
+			generateParamInit(out, s, structs)
 
 			fmt.Fprintf(out, "		response, err := api.%s(r.Context(), param)\n", funcDecl.Name.Name)
 			fmt.Fprintf(out, `
@@ -214,7 +466,7 @@ func (api *%s) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintln(out, `		handleResult.response = response`)
 		}
 
-		fmt.Fprintln(out, `
+		fmt.Fprint(out, `
 	default:
 		handleResult.err = ApiError{
 			HTTPStatus: 404,
@@ -224,152 +476,371 @@ func (api *%s) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	writeHeader(w, handleResult)
 	writeBody(w, handleResult)
-}`)
+}
+`)
+
+		generateOpenAPI(out, receiver, funcDecls, structs)
+		generateClient(out, receiver, funcDecls, structs)
 	}
 }
 
 type FuncTag struct {
-	Url    string `"json:"url"`
-	Auth   bool   `"json:"auth"`
-	Method string `"json:"method"`
+	Url    string `json:"url"`
+	Auth   bool   `json:"auth"`
+	Method string `json:"method"`
 }
 
-func generateParamInit(out *os.File, s ast.TypeSpec) {
-	for _, field := range s.Type.(*ast.StructType).Fields.List {
-		fieldName := field.Names[0].Name
+func parseFuncTag(funcDecl ast.FuncDecl) FuncTag {
+	_, comment := getFuncComment(funcDecl)
+	var funcTag FuncTag
+	_ = json.Unmarshal([]byte(comment), &funcTag)
+	return funcTag
+}
 
-		formValueVarName := "formValue" + fieldName
-		parsedVarName := "parsed" + fieldName
-		assignToVar := formValueVarName
+// fieldParser описывает, как получить значение поля из строки параметра и обратно
+type fieldParser struct {
+	// выражение разбора, %s - строка или список строк; пусто - разбирать не надо
+	parse string
+	// тип в ошибке "<param> must be <typeName>"
+	typeName string
+	// min и max сравниваются со значением, а не с длиной
+	numeric bool
+	// значение - список из повторяющихся параметров или через запятую
+	list bool
+	// для клиента: значение (у списка - элемент) в строку, %s - значение
+	format string
+}
 
-		fieldTag := parseTag(*field)
+var fieldParsers = map[string]fieldParser{
+	"string":        {format: "%s"},
+	"int":           {parse: "strconv.Atoi(%s)", typeName: "int", numeric: true, format: "strconv.Itoa(%s)"},
+	"int64":         {parse: "strconv.ParseInt(%s, 10, 64)", typeName: "int64", numeric: true, format: "strconv.FormatInt(%s, 10)"},
+	"uint64":        {parse: "strconv.ParseUint(%s, 10, 64)", typeName: "uint64", numeric: true, format: "strconv.FormatUint(%s, 10)"},
+	"float64":       {parse: "strconv.ParseFloat(%s, 64)", typeName: "float64", numeric: true, format: "strconv.FormatFloat(%s, 'g', -1, 64)"},
+	"bool":          {parse: "strconv.ParseBool(%s)", typeName: "bool", format: "strconv.FormatBool(%s)"},
+	"time.Time":     {parse: "time.Parse(time.RFC3339, %s)", typeName: "RFC3339 time", format: "%s.Format(time.RFC3339Nano)"},
+	"time.Duration": {parse: "time.ParseDuration(%s)", typeName: "duration", numeric: true, format: "%s.String()"},
+	"[]string":      {list: true, format: "%s"},
+	"[]int":         {parse: "parseInts(%s)", typeName: "list of int", list: true, format: "strconv.Itoa(%s)"},
+}
 
-		var paramName string
-		if fieldTag.paramname == "" {
-			paramName = strings.ToLower(fieldName)
-		} else {
-			paramName = fieldTag.paramname
+// typeName возвращает тип поля так, как он записан в исходнике: int, time.Time, *int, []string
+func typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeName(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + typeName(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + typeName(t.Elt)
 		}
+	}
+	return ""
+}
 
-		fmt.Fprintf(out, `		%s := r.FormValue("%s")
-`, formValueVarName, paramName)
+// paramField - поле структуры параметров, в том числе вложенной
+type paramField struct {
+	// путь от param: Paging.Limit
+	path []string
+	// имя параметра запроса: вложенные получают префикс "<parent>."
+	paramName string
+	typeName  string
+	tag       tagParseResult
+}
 
-		if fieldTag.dflt != "" {
-			fmt.Fprintf(out, `		if %s == "" {
-			%s = "%s"
-		}
-`, formValueVarName, formValueVarName, fieldTag.dflt)
-		}
+func (f paramField) varName(prefix string) string {
+	return prefix + strings.Join(f.path, "")
+}
 
-		if fieldTag.required {
-			fmt.Fprintf(out, `		if %s == "" {
-			handleResult.err = ApiError{
-				HTTPStatus: http.StatusBadRequest,
-				Err:        fmt.Errorf("%s must me not empty"),
+// collectFields раскладывает структуру параметров на поля в порядке следования,
+// встроенные структуры дают свои параметры без префикса, вложенные - с префиксом
+func collectFields(s ast.TypeSpec, structs map[string]ast.TypeSpec, path []string, paramPrefix string) []paramField {
+	var result []paramField
+
+	for _, field := range s.Type.(*ast.StructType).Fields.List {
+		fieldType := typeName(field.Type)
+		fieldTag := parseTag(*field)
+
+		if len(field.Names) == 0 {
+			nested, ok := structs[fieldType]
+			if !ok {
+				log.Fatalf("%s: embedded field %s is not a struct from the same file", s.Name.Name, fieldType)
 			}
-			break
+			result = append(result, collectFields(nested, structs, appendPath(path, fieldType), paramPrefix)...)
+			continue
 		}
-`, formValueVarName, paramName)
+
+		for _, name := range field.Names {
+			paramName := fieldTag.paramname
+			if paramName == "" {
+				paramName = strings.ToLower(name.Name)
+			}
+
+			if nested, ok := structs[fieldType]; ok {
+				result = append(result, collectFields(nested, structs, appendPath(path, name.Name), paramPrefix+paramName+".")...)
+				continue
+			}
+
+			result = append(result, paramField{
+				path:      appendPath(path, name.Name),
+				paramName: paramPrefix + paramName,
+				typeName:  fieldType,
+				tag:       fieldTag,
+			})
 		}
+	}
+
+	return result
+}
 
-		filedIsInt := field.Type.(*ast.Ident).Name == "int"
+func appendPath(path []string, name string) []string {
+	return append(append([]string(nil), path...), name)
+}
 
-		if filedIsInt {
-			fmt.Fprintf(out, `		%s, err := strconv.Atoi(%s)
+func generateParamInit(out io.Writer, s ast.TypeSpec, structs map[string]ast.TypeSpec) {
+	fields := collectFields(s, structs, nil, "")
+	if len(fields) == 0 {
+		return
+	}
+
+	lists := make([]string, 0, len(fields))
+	for _, field := range fields {
+		isList := fieldParsers[strings.TrimPrefix(field.typeName, "*")].list
+		lists = append(lists, fmt.Sprintf("%q: %t", field.paramName, isList))
+	}
+
+	fmt.Fprintf(out, `		params, err := readParams(r, map[string]bool{%s})
 		if err != nil {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
-				Err:        fmt.Errorf("%s must be int"),
+				Err:        fmt.Errorf("bad json body"),
 			}
 			break
 		}
-`, parsedVarName, formValueVarName, paramName)
-			assignToVar = parsedVarName
+`, strings.Join(lists, ", "))
+
+	for _, field := range fields {
+		fieldType := strings.TrimPrefix(field.typeName, "*")
+		isPointer := fieldType != field.typeName
+
+		parser, ok := fieldParsers[fieldType]
+		if !ok || isPointer && parser.list {
+			log.Fatalf("%s.%s: unsupported field type %s", s.Name.Name, strings.Join(field.path, "."), field.typeName)
+		}
+
+		if parser.list {
+			generateListInit(out, field, parser)
+		} else {
+			generateValueInit(out, field, parser, isPointer)
+		}
+	}
+}
+
+func generateValueInit(out io.Writer, field paramField, parser fieldParser, isPointer bool) {
+	valueVarName := field.varName("value")
+	parsedVarName := field.varName("parsed")
+	paramName := field.paramName
+	fieldTag := field.tag
+
+	fmt.Fprintf(out, `		%s := params.value("%s")
+`, valueVarName, paramName)
+
+	if fieldTag.dflt != "" {
+		fmt.Fprintf(out, `		if %s == "" {
+			%s = %q
 		}
+`, valueVarName, valueVarName, fieldTag.dflt)
+	}
+
+	generateRequired(out, valueVarName+` == ""`, paramName, fieldTag)
 
-		if fieldTag.hasMin {
-			if filedIsInt {
-				fmt.Fprintf(out, `		if %s < %d {
+	// необязательный указатель остаётся nil, если параметр не пришёл
+	if isPointer {
+		fmt.Fprintf(out, "		if %s != \"\" {\n", valueVarName)
+	}
+
+	assignToVar := valueVarName
+	if parser.parse != "" {
+		generateParse(out, parsedVarName, fmt.Sprintf(parser.parse, valueVarName), paramName, parser)
+		assignToVar = parsedVarName
+	}
+
+	if parser.numeric {
+		generateBounds(out, field, assignToVar, "", func(bound string) string {
+			if field.typeName == "time.Duration" || field.typeName == "*time.Duration" {
+				d, err := time.ParseDuration(bound)
+				if err != nil {
+					log.Fatalf("%s: bad duration bound %q: %s", paramName, bound, err)
+				}
+				return strconv.FormatInt(int64(d), 10)
+			}
+			return bound
+		})
+	} else if parser.parse == "" {
+		generateBounds(out, field, "len("+assignToVar+")", "len ", nil)
+	} else if fieldTag.min != "" || fieldTag.max != "" {
+		log.Fatalf("%s: min and max are not supported for %s", paramName, field.typeName)
+	}
+
+	if len(fieldTag.enum) > 0 {
+		if parser.parse != "" {
+			log.Fatalf("%s: enum is supported only for strings", paramName)
+		}
+		validEnumValuesVarName := field.varName("validEnumValues")
+		generateEnumValues(out, validEnumValuesVarName, fieldTag.enum)
+		generateEnumCheck(out, "!"+validEnumValuesVarName+"["+valueVarName+"]", paramName, fieldTag.enum)
+	}
+
+	if isPointer {
+		fmt.Fprintf(out, "		param.%s = &%s\n		}\n\n", strings.Join(field.path, "."), assignToVar)
+		return
+	}
+	fmt.Fprintf(out, "		param.%s = %s\n\n", strings.Join(field.path, "."), assignToVar)
+}
+
+func generateListInit(out io.Writer, field paramField, parser fieldParser) {
+	valuesVarName := field.varName("values")
+	parsedVarName := field.varName("parsed")
+	paramName := field.paramName
+	fieldTag := field.tag
+
+	fmt.Fprintf(out, `		%s := params.list("%s")
+`, valuesVarName, paramName)
+
+	// значения по умолчанию у списка разделены |, как у enum
+	if fieldTag.dflt != "" {
+		fmt.Fprintf(out, `		if len(%s) == 0 {
+			%s = %#v
+		}
+`, valuesVarName, valuesVarName, strings.Split(fieldTag.dflt, "|"))
+	}
+
+	generateRequired(out, "len("+valuesVarName+") == 0", paramName, fieldTag)
+	generateBounds(out, field, "len("+valuesVarName+")", "len ", nil)
+
+	if len(fieldTag.enum) > 0 {
+		if parser.parse != "" {
+			log.Fatalf("%s: enum is supported only for strings", paramName)
+		}
+		validEnumValuesVarName := field.varName("validEnumValues")
+		generateEnumValues(out, validEnumValuesVarName, fieldTag.enum)
+		generateEnumCheck(out, "!allOf("+valuesVarName+", "+validEnumValuesVarName+")", paramName, fieldTag.enum)
+	}
+
+	assignToVar := valuesVarName
+	if parser.parse != "" {
+		generateParse(out, parsedVarName, fmt.Sprintf(parser.parse, valuesVarName), paramName, parser)
+		assignToVar = parsedVarName
+	}
+
+	fmt.Fprintf(out, "		param.%s = %s\n\n", strings.Join(field.path, "."), assignToVar)
+}
+
+func generateRequired(out io.Writer, emptyCondition string, paramName string, fieldTag tagParseResult) {
+	if !fieldTag.required {
+		return
+	}
+	fmt.Fprintf(out, `		if %s {
 			handleResult.err = ApiError{
-				HTTPStatus: 400,
-				Err:        fmt.Errorf("%s must be >= %d"),
+				HTTPStatus: http.StatusBadRequest,
+				Err:        fmt.Errorf("%s must me not empty"),
 			}
 			break
 		}
-`, parsedVarName, fieldTag.min, paramName, fieldTag.min)
-			} else {
-				fmt.Fprintf(out, `		if len(%s) < %d {
+`, emptyCondition, paramName)
+}
+
+func generateParse(out io.Writer, parsedVarName string, parseExpr string, paramName string, parser fieldParser) {
+	fmt.Fprintf(out, `		%s, err := %s
+		if err != nil {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
-				Err:        fmt.Errorf("%s len must be >= %d"),
+				Err:        fmt.Errorf("%s must be %s"),
 			}
 			break
 		}
-`, formValueVarName, fieldTag.min, paramName, fieldTag.min)
+`, parsedVarName, parseExpr, paramName, parser.typeName)
+}
+
+// generateBounds проверяет min и max; literal переводит границу из тега в литерал Go
+func generateBounds(out io.Writer, field paramField, value string, what string, literal func(string) string) {
+	if literal == nil {
+		literal = func(bound string) string {
+			if _, err := strconv.Atoi(bound); err != nil {
+				log.Fatalf("%s: bad length bound %q", field.paramName, bound)
 			}
+			return bound
 		}
+	}
 
-		if fieldTag.hasMax {
-			if filedIsInt {
-				fmt.Fprintf(out, `		if %s > %d {
+	if field.tag.min != "" {
+		fmt.Fprintf(out, `		if %s < %s {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
-				Err:        fmt.Errorf("%s must be <= %d"),
+				Err:        fmt.Errorf("%s %smust be >= %s"),
 			}
 			break
 		}
-`, parsedVarName, fieldTag.max, paramName, fieldTag.max)
-			} else {
-				fmt.Fprintf(out, `		if len(%s) > %d {
+`, value, literal(field.tag.min), field.paramName, what, field.tag.min)
+	}
+
+	if field.tag.max != "" {
+		fmt.Fprintf(out, `		if %s > %s {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
-				Err:        fmt.Errorf("%s len must be <= %d"),
+				Err:        fmt.Errorf("%s %smust be <= %s"),
 			}
 			break
 		}
-`, formValueVarName, fieldTag.max, paramName, fieldTag.max)
-			}
-		}
-
-		validEnumValuesVarName := paramName + "ValidEnumValues"
+`, value, literal(field.tag.max), field.paramName, what, field.tag.max)
+	}
+}
 
-		if len(fieldTag.enum) > 0 {
-			fmt.Fprintf(out, "		%s := map[string]bool{", validEnumValuesVarName)
-			fmt.Fprintln(out)
-			for _, enumValue := range fieldTag.enum {
-				fmt.Fprintf(out, `			"%s": true,
+func generateEnumValues(out io.Writer, varName string, enum []string) {
+	fmt.Fprintf(out, "		%s := map[string]bool{\n", varName)
+	for _, enumValue := range enum {
+		fmt.Fprintf(out, `			%q: true,
 `, enumValue)
-			}
-			fmt.Fprintln(out, "}")
+	}
+	fmt.Fprintln(out, "		}")
+}
 
-			fmt.Fprintf(out, `		if !%s[%s] {
+func generateEnumCheck(out io.Writer, invalidCondition string, paramName string, enum []string) {
+	fmt.Fprintf(out, `		if %s {
 			handleResult.err = ApiError{
 				HTTPStatus: 400,
 				Err:        fmt.Errorf("%s must be one of [%s]"),
 			}
 			break
 		}
-`, validEnumValuesVarName, formValueVarName, paramName, strings.Join(fieldTag.enum, ", "))
-		}
-
-		fmt.Fprintf(out, "		param.%s = %s\n\n", fieldName, assignToVar)
-	}
-
+`, invalidCondition, paramName, strings.Join(enum, ", "))
 }
 
 func parseTag(field ast.Field) tagParseResult {
+	result := tagParseResult{}
+	if field.Tag == nil {
+		return result
+	}
+
 	fieldTag := reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1])
 	apivalidator := fieldTag.Get("apivalidator")
 
-	result := tagParseResult{}
-
 	for _, item := range strings.Split(apivalidator, ",") {
+		if item == "" {
+			continue
+		}
 		if item == "required" {
 			result.required = true
 			continue
 		}
 
-		keyValue := strings.Split(item, "=")
+		keyValue := strings.SplitN(item, "=", 2)
+		if len(keyValue) != 2 {
+			log.Fatalf("bad apivalidator item %q", item)
+		}
 		key := keyValue[0]
 		value := keyValue[1]
 
@@ -377,11 +848,9 @@ func parseTag(field ast.Field) tagParseResult {
 		case "paramname":
 			result.paramname = value
 		case "min":
-			result.min, _ = strconv.Atoi(value)
-			result.hasMin = true
+			result.min = value
 		case "max":
-			result.max, _ = strconv.Atoi(value)
-			result.hasMax = true
+			result.max = value
 		case "enum":
 			result.enum = strings.Split(value, "|")
 		case "default":
@@ -391,12 +860,11 @@ func parseTag(field ast.Field) tagParseResult {
 	return result
 }
 
+// min и max хранятся как в теге: для чисел это литерал, для time.Duration - строка вида 1m
 type tagParseResult struct {
 	required  bool
-	min       int
-	hasMin    bool
-	max       int
-	hasMax    bool
+	min       string
+	max       string
 	paramname string
 	enum      []string
 	dflt      string
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"io"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// openAPIPath - по этому адресу ServeHTTP отдаёт описание своего API
+const openAPIPath = "/openapi.json"
+
+// schema - схема OpenAPI 3, только то, что нужно генератору
+type schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+	Minimum     json.Number        `json:"minimum,omitempty"`
+	Maximum     json.Number        `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Style    string  `json:"style,omitempty"`
+	Explode  bool    `json:"explode,omitempty"`
+	Schema   *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+}
+
+// scalarSchemas - схемы параметров по типу поля, как они приходят в запросе
+var scalarSchemas = map[string]schema{
+	"string":        {Type: "string"},
+	"int":           {Type: "integer"},
+	"int64":         {Type: "integer", Format: "int64"},
+	"uint64":        {Type: "integer", Format: "uint64", Minimum: "0"},
+	"float64":       {Type: "number", Format: "double"},
+	"bool":          {Type: "boolean"},
+	"time.Time":     {Type: "string", Format: "date-time"},
+	"time.Duration": {Type: "string", Format: "duration", Description: "Go duration, for example 1h30m"},
+}
+
+// generateOpenAPI пишет константу с описанием API receiver в формате OpenAPI 3
+func generateOpenAPI(out io.Writer, receiver string, funcDecls []ast.FuncDecl, structs map[string]ast.TypeSpec) {
+	components := make(map[string]*schema)
+	components["Error"] = &schema{
+		Type:       "object",
+		Properties: map[string]*schema{"error": {Type: "string"}},
+		Required:   []string{"error"},
+	}
+	hasAuth := false
+
+	paths := make(map[string]map[string]operation)
+	for _, funcDecl := range funcDecls {
+		funcTag := parseFuncTag(funcDecl)
+		if funcTag.Url == openAPIPath {
+			log.Fatalf("%s.%s: %s is reserved for the API description", receiver, funcDecl.Name.Name, openAPIPath)
+		}
+		hasAuth = hasAuth || funcTag.Auth
+
+		paramStructName := funcDecl.Type.Params.List[1].Type.(*ast.Ident).Name
+		fields := collectFields(structs[paramStructName], structs, nil, "")
+		result := responseSchema(funcDecl.Type.Results.List[0].Type, structs, components)
+
+		// без метода в метке обработчик принимает и GET, и POST
+		methods := []string{"get", "post"}
+		if funcTag.Method != "" {
+			methods = []string{strings.ToLower(funcTag.Method)}
+		}
+
+		operations := make(map[string]operation)
+		for _, method := range methods {
+			op := operation{
+				OperationID: funcDecl.Name.Name,
+				Responses:   operationResponses(result, funcTag.Auth),
+			}
+			if len(methods) > 1 {
+				op.OperationID += strings.ToUpper(method[:1]) + method[1:]
+			}
+			if funcTag.Auth {
+				op.Security = []map[string][]string{{"auth": {}}}
+			}
+			if method == "get" {
+				op.Parameters = queryParameters(fields)
+			} else if len(fields) > 0 {
+				op.RequestBody = &requestBody{
+					Required: true,
+					Content: map[string]mediaType{
+						"application/x-www-form-urlencoded": {Schema: formSchema(fields)},
+						"application/json":                  {Schema: jsonSchema(fields)},
+					},
+				}
+			}
+			operations[method] = op
+		}
+		paths[funcTag.Url] = operations
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   receiver,
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+	componentsObject := map[string]interface{}{"schemas": components}
+	if hasAuth {
+		componentsObject["securitySchemes"] = map[string]interface{}{
+			"auth": map[string]string{"type": "apiKey", "in": "header", "name": "X-Auth"},
+		}
+	}
+	document["components"] = componentsObject
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		log.Fatalf("%s: cant marshal openapi document: %s", receiver, err)
+	}
+	data := bytes.TrimSpace(buf.Bytes())
+
+	fmt.Fprintf(out, "\n// openAPI%s - описание API %s в формате OpenAPI 3, отдаётся по %s\n", receiver, receiver, openAPIPath)
+	if strings.Contains(string(data), "`") {
+		fmt.Fprintf(out, "const openAPI%s = %q\n", receiver, data)
+	} else {
+		fmt.Fprintf(out, "const openAPI%s = `%s`\n", receiver, data)
+	}
+}
+
+func operationResponses(result *schema, auth bool) map[string]response {
+	errorContent := map[string]mediaType{
+		"application/json": {Schema: &schema{Ref: "#/components/schemas/Error"}},
+	}
+
+	responses := map[string]response{
+		"200": {
+			Description: "OK",
+			Content: map[string]mediaType{
+				"application/json": {Schema: &schema{
+					Type: "object",
+					Properties: map[string]*schema{
+						"error":    {Type: "string"},
+						"response": result,
+					},
+					Required: []string{"error", "response"},
+				}},
+			},
+		},
+		"400":     {Description: "bad params", Content: errorContent},
+		"default": {Description: "error", Content: errorContent},
+	}
+	if auth {
+		responses["403"] = response{Description: "unauthorized", Content: errorContent}
+	}
+	return responses
+}
+
+func queryParameters(fields []paramField) []parameter {
+	var result []parameter
+	for _, field := range fields {
+		p := parameter{
+			Name:     field.paramName,
+			In:       "query",
+			Required: field.tag.required,
+			Schema:   paramSchema(field),
+		}
+		if p.Schema.Type == "array" {
+			p.Style, p.Explode = "form", true
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// formSchema - тело формы, вложенные параметры называются через точку
+func formSchema(fields []paramField) *schema {
+	result := &schema{Type: "object", Properties: make(map[string]*schema)}
+	for _, field := range fields {
+		result.Properties[field.paramName] = paramSchema(field)
+		if field.tag.required {
+			result.Required = append(result.Required, field.paramName)
+		}
+	}
+	return result
+}
+
+// jsonSchema - JSON-тело, вложенные параметры - вложенные объекты
+func jsonSchema(fields []paramField) *schema {
+	result := &schema{Type: "object", Properties: make(map[string]*schema)}
+	for _, field := range fields {
+		object := result
+		names := strings.Split(field.paramName, ".")
+		for _, name := range names[:len(names)-1] {
+			nested, ok := object.Properties[name]
+			if !ok {
+				nested = &schema{Type: "object", Properties: make(map[string]*schema)}
+				object.Properties[name] = nested
+			}
+			object = nested
+		}
+
+		name := names[len(names)-1]
+		object.Properties[name] = paramSchema(field)
+		if field.tag.required {
+			object.Required = append(object.Required, name)
+		}
+	}
+	return result
+}
+
+// paramSchema - схема параметра с ограничениями из apivalidator
+func paramSchema(field paramField) *schema {
+	fieldType := strings.TrimPrefix(field.typeName, "*")
+	isPointer := fieldType != field.typeName
+	isList := strings.HasPrefix(fieldType, "[]")
+
+	item := scalarSchemas[strings.TrimPrefix(fieldType, "[]")]
+	result := &item
+	if isList {
+		result = &schema{Type: "array", Items: &item}
+	}
+	result.Nullable = isPointer
+
+	tag := field.tag
+	if len(tag.enum) > 0 {
+		item.Enum = tag.enum
+	}
+
+	if tag.dflt != "" {
+		if isList {
+			var values []interface{}
+			for _, value := range strings.Split(tag.dflt, "|") {
+				values = append(values, jsonValue(item, value))
+			}
+			result.Default = values
+		} else {
+			result.Default = jsonValue(item, tag.dflt)
+		}
+	}
+
+	switch {
+	case isList:
+		result.MinItems, result.MaxItems = intBound(tag.min), intBound(tag.max)
+	case item.Type == "string" && item.Format == "":
+		result.MinLength, result.MaxLength = intBound(tag.min), intBound(tag.max)
+	case item.Type == "integer" || item.Type == "number":
+		if tag.min != "" {
+			result.Minimum = json.Number(tag.min)
+		}
+		if tag.max != "" {
+			result.Maximum = json.Number(tag.max)
+		}
+	case tag.min != "" || tag.max != "":
+		// у длительности границы - тоже длительности, числом их не выразить
+		var bounds []string
+		if tag.min != "" {
+			bounds = append(bounds, ">= "+tag.min)
+		}
+		if tag.max != "" {
+			bounds = append(bounds, "<= "+tag.max)
+		}
+		result.Description += "; " + strings.Join(bounds, ", ")
+	}
+
+	return result
+}
+
+// jsonValue переводит значение из тега в значение JSON того же типа, что и параметр
+func jsonValue(s schema, value string) interface{} {
+	switch s.Type {
+	case "integer", "number":
+		return json.Number(value)
+	case "boolean":
+		parsed, _ := strconv.ParseBool(value)
+		return parsed
+	}
+	return value
+}
+
+func intBound(bound string) *int {
+	if bound == "" {
+		return nil
+	}
+	value, _ := strconv.Atoi(bound)
+	return &value
+}
+
+// responseSchema описывает, как результат метода выглядит в JSON. Структуры
+// из разбираемого файла попадают в components
+func responseSchema(expr ast.Expr, structs map[string]ast.TypeSpec, components map[string]*schema) *schema {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		result := responseSchema(t.X, structs, components)
+		// рядом с $ref остальные поля не учитываются, nullable ставится только на значения
+		if result.Ref == "" {
+			result.Nullable = true
+		}
+		return result
+	case *ast.ArrayType:
+		if typeName(t.Elt) == "byte" {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		return &schema{Type: "array", Items: responseSchema(t.Elt, structs, components)}
+	case *ast.MapType:
+		return &schema{Type: "object"}
+	}
+
+	name := typeName(expr)
+	switch name {
+	case "time.Time":
+		return &schema{Type: "string", Format: "date-time"}
+	case "time.Duration":
+		return &schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32":
+		return &schema{Type: "integer"}
+	case "float32":
+		return &schema{Type: "number", Format: "float"}
+	}
+	if s, ok := scalarSchemas[name]; ok {
+		return &s
+	}
+
+	spec, ok := structs[name]
+	if !ok {
+		// тип не из этого файла, про его вид ничего не известно
+		return &schema{}
+	}
+	if _, done := components[name]; !done {
+		// заглушка до обхода полей, чтобы рекурсивные типы не зацикливались
+		components[name] = &schema{}
+		components[name] = structSchema(spec, structs, components)
+	}
+	return &schema{Ref: "#/components/schemas/" + name}
+}
+
+// structSchema описывает структуру по правилам encoding/json: имена из тега json,
+// поля без omitempty обязательны, поля встроенных структур поднимаются наверх
+func structSchema(spec ast.TypeSpec, structs map[string]ast.TypeSpec, components map[string]*schema) *schema {
+	result := &schema{Type: "object", Properties: make(map[string]*schema)}
+
+	for _, field := range spec.Type.(*ast.StructType).Fields.List {
+		var jsonTag string
+		if field.Tag != nil {
+			jsonTag = reflect.StructTag(field.Tag.Value[1 : len(field.Tag.Value)-1]).Get("json")
+		}
+		if jsonTag == "-" {
+			continue
+		}
+		options := strings.Split(jsonTag, ",")
+		omitEmpty := false
+		for _, option := range options[1:] {
+			omitEmpty = omitEmpty || option == "omitempty"
+		}
+
+		if len(field.Names) == 0 && options[0] == "" {
+			if embedded, ok := structs[strings.TrimPrefix(typeName(field.Type), "*")]; ok {
+				nested := structSchema(embedded, structs, components)
+				for name, property := range nested.Properties {
+					result.Properties[name] = property
+				}
+				result.Required = append(result.Required, nested.Required...)
+				continue
+			}
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent(strings.TrimPrefix(typeName(field.Type), "*"))}
+		}
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+			jsonName := options[0]
+			if jsonName == "" {
+				jsonName = name.Name
+			}
+			result.Properties[jsonName] = responseSchema(field.Type, structs, components)
+			if !omitEmpty {
+				result.Required = append(result.Required, jsonName)
+			}
+		}
+	}
+
+	return result
+}
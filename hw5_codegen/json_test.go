@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// JSONCase - запрос с JSON-телом, ошибки должны совпадать с запросом через форму
+type JSONCase struct {
+	Method      string
+	Path        string
+	Body        string
+	ContentType string // application/json, если не указан
+	Auth        bool
+	Status      int
+	Result      interface{}
+}
+
+func TestJSONBody(t *testing.T) {
+	ts := httptest.NewServer(NewMyApi())
+
+	cases := []JSONCase{
+		JSONCase{
+			Path:   ApiUserProfile,
+			Body:   `{"login": "rvasily"}`,
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        42,
+					"login":     "rvasily",
+					"full_name": "Vasily Romanov",
+					"status":    20,
+				},
+			},
+		},
+		JSONCase{ // тело важнее строки запроса
+			Method: http.MethodPost,
+			Path:   ApiUserProfile + "?login=rvasily",
+			Body:   `{"login": ""}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "login must me not empty",
+			},
+		},
+		JSONCase{
+			Method:      http.MethodPost,
+			Path:        ApiUserCreate,
+			Body:        `{"login": "json_moderator", "full_name": "Json Ivanov", "age": 32, "status": "moderator"}`,
+			ContentType: "application/json; charset=utf-8",
+			Auth:        true,
+			Status:      http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id": 43,
+				},
+			},
+		},
+		JSONCase{
+			Path:   ApiUserProfile,
+			Body:   `{"login": "json_moderator"}`,
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"id":        43,
+					"login":     "json_moderator",
+					"full_name": "Json Ivanov",
+					"status":    10,
+				},
+			},
+		},
+		JSONCase{ // метод и авторизация проверяются до чтения тела
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{`,
+			Status: http.StatusForbidden,
+			Result: CR{
+				"error": "unauthorized",
+			},
+		},
+		JSONCase{
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{"login": `,
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{"login": "new_moderator", "age": "ten"}`,
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "age must be int",
+			},
+		},
+		JSONCase{
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{"login": "new_moderator", "age": 32.5}`,
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "age must be int",
+			},
+		},
+		JSONCase{
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{"login": "new_moderator", "age": 256}`,
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "age must be <= 128",
+			},
+		},
+		JSONCase{
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{"login": "new_m", "age": 32}`,
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "login len must be >= 10",
+			},
+		},
+		JSONCase{
+			Method: http.MethodPost,
+			Path:   ApiUserCreate,
+			Body:   `{"login": "new_moderator", "age": 32, "status": "adm"}`,
+			Auth:   true,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "status must be one of [user, moderator, admin]",
+			},
+		},
+	}
+
+	runJSONTests(t, ts, cases)
+}
+
+func runJSONTests(t *testing.T, ts *httptest.Server, cases []JSONCase) {
+	for idx, item := range cases {
+		var (
+			result   interface{}
+			expected interface{}
+		)
+
+		req, err := http.NewRequest(item.Method, ts.URL+item.Path, strings.NewReader(item.Body))
+		if err != nil {
+			t.Fatalf("[%d] cant create request: %v", idx, err)
+		}
+		contentType := item.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+		if item.Auth {
+			req.Header.Add("X-Auth", "100500")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("[%d] request error: %v", idx, err)
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != item.Status {
+			t.Errorf("[%d] expected http status %v, got %v: %s", idx, item.Status, resp.StatusCode, body)
+			continue
+		}
+
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Errorf("[%d] cant unpack json: %v", idx, err)
+			continue
+		}
+
+		// тот же приём, что и в runTests: приводим ожидаемое к типам из json
+		data, _ := json.Marshal(item.Result)
+		json.Unmarshal(data, &expected)
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("[%d] results not match\nGot: %#v\nExpected: %#v", idx, result, item.Result)
+		}
+	}
+}
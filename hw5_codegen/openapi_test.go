@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func fetchOpenAPI(t *testing.T, handler http.Handler) map[string]interface{} {
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resp, err := client.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected http status 200, got %v", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", ct)
+	}
+
+	var document map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		t.Fatalf("cant unpack json: %v", err)
+	}
+	if document["openapi"] != "3.0.3" {
+		t.Errorf("unexpected openapi version %v", document["openapi"])
+	}
+	return document
+}
+
+// lookup идёт по документу по ключам объектов, элемент массива ищется по полю name
+func lookup(document interface{}, keys ...string) (interface{}, bool) {
+	current := document
+	for _, key := range keys {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			found := false
+			for _, item := range node {
+				if object, ok := item.(map[string]interface{}); ok && object["name"] == key {
+					current, found = item, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func expectJSON(t *testing.T, document interface{}, expected interface{}, keys ...string) {
+	t.Helper()
+
+	// тот же приём, что и в runTests: приводим ожидаемое к типам из json
+	var want interface{}
+	data, _ := json.Marshal(expected)
+	json.Unmarshal(data, &want)
+
+	got, ok := lookup(document, keys...)
+	if !ok {
+		t.Errorf("%v: not found", keys)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%v\nGot: %#v\nExpected: %#v", keys, got, want)
+	}
+}
+
+func expectMissing(t *testing.T, document interface{}, keys ...string) {
+	t.Helper()
+	if got, ok := lookup(document, keys...); ok {
+		t.Errorf("%v: expected to be missing, got %#v", keys, got)
+	}
+}
+
+func TestOpenAPIMyApi(t *testing.T) {
+	document := fetchOpenAPI(t, NewMyApi())
+
+	// метод не задан - обработчик принимает и GET, и POST
+	expectJSON(t, document, "ProfileGet", "paths", "/user/profile", "get", "operationId")
+	expectJSON(t, document, "ProfilePost", "paths", "/user/profile", "post", "operationId")
+	expectJSON(t, document, true, "paths", "/user/profile", "get", "parameters", "login", "required")
+	expectMissing(t, document, "paths", "/user/profile", "get", "security")
+	expectJSON(t, document,
+		CR{"$ref": "#/components/schemas/User"},
+		"paths", "/user/profile", "get", "responses", "200", "content", "application/json", "schema", "properties", "response")
+
+	expectMissing(t, document, "paths", "/user/create", "get")
+	create := []string{"paths", "/user/create", "post"}
+	expectJSON(t, document, []CR{{"auth": []string{}}}, append(create, "security")...)
+	expectJSON(t, document, CR{"type": "apiKey", "in": "header", "name": "X-Auth"}, "components", "securitySchemes", "auth")
+	expectMissing(t, document, append(create, "parameters")...)
+
+	form := append(create, "requestBody", "content", "application/x-www-form-urlencoded", "schema")
+	expectJSON(t, document, []string{"login"}, append(form, "required")...)
+	expectJSON(t, document, CR{"type": "string", "minLength": 10}, append(form, "properties", "login")...)
+	expectJSON(t, document, CR{"type": "string"}, append(form, "properties", "full_name")...)
+	expectJSON(t, document,
+		CR{"type": "string", "enum": []string{"user", "moderator", "admin"}, "default": "user"},
+		append(form, "properties", "status")...)
+	expectJSON(t, document, CR{"type": "integer", "minimum": 0, "maximum": 128}, append(form, "properties", "age")...)
+
+	expectJSON(t, document,
+		CR{
+			"type": "object",
+			"properties": CR{
+				"id":        CR{"type": "integer", "format": "uint64", "minimum": 0},
+				"login":     CR{"type": "string"},
+				"full_name": CR{"type": "string"},
+				"status":    CR{"type": "integer"},
+			},
+			"required": []string{"id", "login", "full_name", "status"},
+		},
+		"components", "schemas", "User")
+}
+
+func TestOpenAPIOtherApi(t *testing.T) {
+	// у OtherApi тот же адрес /user/create, но свои параметры и ответ
+	document := fetchOpenAPI(t, NewOtherApi())
+
+	form := []string{"paths", "/user/create", "post", "requestBody", "content", "application/x-www-form-urlencoded", "schema"}
+	expectJSON(t, document, CR{"type": "integer", "minimum": 1, "maximum": 50}, append(form, "properties", "level")...)
+	expectJSON(t, document, CR{"type": "string"}, append(form, "properties", "account_name")...)
+	expectMissing(t, document, "paths", "/user/profile")
+	expectMissing(t, document, "components", "schemas", "User")
+	expectJSON(t, document, CR{"type": "integer"}, "components", "schemas", "OtherUser", "properties", "level")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestGeneratedReportApi собирает кодогенератор, генерирует обработчики для
+// api.go вместе с testdata/report_api.go и запускает тесты ReportApi из
+// testdata/report_api_test.go на копии пакета: api.go менять нельзя, а
+// остальные типы полей проверяются на ReportApi
+func TestGeneratedReportApi(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generator and a copy of the package")
+	}
+
+	dir, err := ioutil.TempDir("", "hw5_report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	generator, err := filepath.Glob(filepath.Join("handlers_gen", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codegen := filepath.Join(dir, "codegen")
+	runCommand(t, "", "go", append([]string{"build", "-o", codegen}, generator...)...)
+
+	sources, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources = append(sources, filepath.Join("testdata", "report_api.go"), filepath.Join("testdata", "report_api_test.go"))
+	for _, source := range sources {
+		if source == "api_handlers.go" || source == "report_test.go" {
+			continue
+		}
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.Base(source)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module hw5_codegen\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runCommand(t, dir, codegen, "api.go", "report_api.go", "api_handlers.go")
+	runCommand(t, dir, "go", "vet", ".")
+	runCommand(t, dir, "go", "test", "-count=1", "-run", "^TestReportApi", ".")
+}
+
+func runCommand(t *testing.T, dir string, name string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s %v: %s\n%s", name, args, err, out)
+	}
+}
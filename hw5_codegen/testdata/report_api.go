@@ -0,0 +1,73 @@
+package main
+
+// типы полей, которых нет в api.go: вложенные и встроенные структуры, списки,
+// указатели для необязательных параметров, время и длительности.
+// генерируется вместе с api.go, см. TestGeneratedReportApi
+
+import (
+	"context"
+	"time"
+)
+
+type ReportApi struct {
+}
+
+func NewReportApi() *ReportApi {
+	return &ReportApi{}
+}
+
+type Paging struct {
+	Limit  int   `apivalidator:"min=1,max=100,default=10"`
+	Offset int64 `apivalidator:"min=0,default=0"`
+}
+
+type Period struct {
+	From time.Time     `apivalidator:"required"`
+	Step time.Duration `apivalidator:"min=1m,max=24h,default=1h"`
+}
+
+type ReportParams struct {
+	Paging
+	Period   Period   `apivalidator:"paramname=period"`
+	Account  uint64   `apivalidator:"required"`
+	Tags     []string `apivalidator:"paramname=tag,enum=red|green|blue,default=red|green"`
+	Labels   []string `apivalidator:"paramname=label"`
+	IDs      []int    `apivalidator:"paramname=id,max=5"`
+	Ratio    *float64 `apivalidator:"min=0,max=1,default=0.5"`
+	Verbose  bool     `apivalidator:"default=false"`
+	MinScore *float64 `apivalidator:"paramname=min_score,min=0"`
+	Owner    *string  `apivalidator:"min=3"`
+}
+
+type Report struct {
+	Limit    int       `json:"limit"`
+	Offset   int64     `json:"offset"`
+	From     time.Time `json:"from"`
+	Step     string    `json:"step"`
+	Account  uint64    `json:"account"`
+	Tags     []string  `json:"tags"`
+	Labels   []string  `json:"labels"`
+	IDs      []int     `json:"ids"`
+	Ratio    *float64  `json:"ratio"`
+	Verbose  bool      `json:"verbose"`
+	MinScore *float64  `json:"min_score"`
+	Owner    *string   `json:"owner"`
+}
+
+// apigen:api {"url": "/report", "auth": false, "method": "GET"}
+func (srv *ReportApi) Report(ctx context.Context, in ReportParams) (*Report, error) {
+	return &Report{
+		Limit:    in.Limit,
+		Offset:   in.Offset,
+		From:     in.Period.From,
+		Step:     in.Period.Step.String(),
+		Account:  in.Account,
+		Tags:     in.Tags,
+		Labels:   in.Labels,
+		IDs:      in.IDs,
+		Ratio:    in.Ratio,
+		Verbose:  in.Verbose,
+		MinScore: in.MinScore,
+		Owner:    in.Owner,
+	}, nil
+}
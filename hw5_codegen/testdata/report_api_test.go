@@ -0,0 +1,447 @@
+package main
+
+// тесты ReportApi из testdata/report_api.go, запускаются TestGeneratedReportApi
+// на копии пакета вместе с остальными тестами и их помощниками
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const ApiReport = "/report"
+
+func TestReportApi(t *testing.T) {
+	ts := httptest.NewServer(NewReportApi())
+
+	cases := []Case{
+		Case{ // все параметры, списки - повторами и через запятую, name[] - как есть
+			Path:   ApiReport,
+			Query:  "limit=5&offset=20&period.from=2020-01-02T03:04:05Z&period.step=30m&account=18446744073709551615&tag=blue&tag=red,green&label=a&label[]=b,+c+&id=1,2&id=3&ratio=0.25&verbose=true&min_score=1.5&owner=rvasily",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"limit":     5,
+					"offset":    20,
+					"from":      "2020-01-02T03:04:05Z",
+					"step":      "30m0s",
+					"account":   uint64(18446744073709551615),
+					"tags":      []string{"blue", "red", "green"},
+					"labels":    []string{"a", "b, c "},
+					"ids":       []int{1, 2, 3},
+					"ratio":     0.25,
+					"verbose":   true,
+					"min_score": 1.5,
+					"owner":     "rvasily",
+				},
+			},
+		},
+		Case{ // значения по умолчанию, необязательные указатели остаются nil
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1",
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"limit":     10,
+					"offset":    0,
+					"from":      "2020-01-02T03:04:05Z",
+					"step":      "1h0m0s",
+					"account":   1,
+					"tags":      []string{"red", "green"},
+					"labels":    nil,
+					"ids":       []int{},
+					"ratio":     0.5,
+					"verbose":   false,
+					"min_score": nil,
+					"owner":     nil,
+				},
+			},
+		},
+		Case{ // только GET
+			Path:   ApiReport,
+			Method: http.MethodPost,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1",
+			Status: http.StatusNotAcceptable,
+			Result: CR{
+				"error": "bad method",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "limit=five&period.from=2020-01-02T03:04:05Z&account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "limit must be int",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "offset=-1&period.from=2020-01-02T03:04:05Z&account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "offset must be >= 0",
+			},
+		},
+		Case{ // параметр вложенной структуры
+			Path:   ApiReport,
+			Query:  "account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "period.from must me not empty",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=yesterday&account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "period.from must be RFC3339 time",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&period.step=often&account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "period.step must be duration",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&period.step=30s&account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "period.step must be >= 1m",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&period.step=25h&account=1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "period.step must be <= 24h",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=-1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "account must be uint64",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&tag=red,black",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "tag must be one of [red, green, blue]",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&id=1,two",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "id must be list of int",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&id=1,2,3,4,5,6",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "id len must be <= 5",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&ratio=1.5",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "ratio must be <= 1",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&verbose=sure",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "verbose must be bool",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&min_score=-1",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "min_score must be >= 0",
+			},
+		},
+		Case{
+			Path:   ApiReport,
+			Query:  "period.from=2020-01-02T03:04:05Z&account=1&owner=rv",
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "owner len must be >= 3",
+			},
+		},
+	}
+
+	runTests(t, ts, cases)
+}
+
+func TestReportApiJSON(t *testing.T) {
+	ts := httptest.NewServer(NewReportApi())
+
+	cases := []JSONCase{
+		JSONCase{ // вложенный объект, массивы, null у необязательного параметра
+			Path: ApiReport,
+			Body: `{
+				"limit": 5,
+				"period": {"from": "2020-01-02T03:04:05Z", "step": "30m"},
+				"account": 18446744073709551615,
+				"tag": ["blue", "red"],
+				"label": ["a,b", " x "],
+				"id": [1, 2, 3],
+				"verbose": true,
+				"min_score": null,
+				"owner": "rvasily"
+			}`,
+			Status: http.StatusOK,
+			Result: CR{
+				"error": "",
+				"response": CR{
+					"limit":     5,
+					"offset":    0,
+					"from":      "2020-01-02T03:04:05Z",
+					"step":      "30m0s",
+					"account":   uint64(18446744073709551615),
+					"tags":      []string{"blue", "red"},
+					"labels":    []string{"a,b", " x "},
+					"ids":       []int{1, 2, 3},
+					"ratio":     0.5,
+					"verbose":   true,
+					"min_score": nil,
+					"owner":     "rvasily",
+				},
+			},
+		},
+		JSONCase{
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {}}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "period.from must me not empty",
+			},
+		},
+		JSONCase{
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "id": [1, "two"]}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "id must be list of int",
+			},
+		},
+		JSONCase{
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "verbose": "sure"}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "verbose must be bool",
+			},
+		},
+		JSONCase{ // вложенный массив
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "id": [[1]]}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{ // массив объектов
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "tag": [{"name": "red"}]}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{ // null в списке
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "tag": ["red", null]}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{ // массив вместо значения
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "limit": [1, 2]}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{ // объект вместо значения
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "owner": {"login": "rvasily"}}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{ // значение вместо вложенной структуры
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": "2020-01-02T03:04:05Z"}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "bad json body",
+			},
+		},
+		JSONCase{ // в JSON запятая - часть значения, а не разделитель списка
+			Path:   ApiReport,
+			Body:   `{"account": 1, "period": {"from": "2020-01-02T03:04:05Z"}, "tag": ["red,green"]}`,
+			Status: http.StatusBadRequest,
+			Result: CR{
+				"error": "tag must be one of [red, green, blue]",
+			},
+		},
+	}
+
+	runJSONTests(t, ts, cases)
+}
+
+func TestReportApiClient(t *testing.T) {
+	ts := httptest.NewServer(NewReportApi())
+	defer ts.Close()
+
+	ctx := context.Background()
+	api := NewReportApiClient(ts.URL, ClientOptions{HTTPClient: client})
+	from := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	ratio, minScore, owner := 0.25, 0.0, "rvasily"
+	report, err := api.Report(ctx, ReportParams{
+		Paging:   Paging{Limit: 5, Offset: 20},
+		Period:   Period{From: from, Step: 30 * time.Minute},
+		Account:  18446744073709551615,
+		Tags:     []string{"blue", "red"},
+		Labels:   []string{"a,b", " x ", ""},
+		IDs:      []int{1, 2, 3},
+		Ratio:    &ratio,
+		Verbose:  true,
+		MinScore: &minScore,
+		Owner:    &owner,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &Report{
+		Limit:    5,
+		Offset:   20,
+		From:     from,
+		Step:     "30m0s",
+		Account:  18446744073709551615,
+		Tags:     []string{"blue", "red"},
+		Labels:   []string{"a,b", " x ", ""},
+		IDs:      []int{1, 2, 3},
+		Ratio:    &ratio,
+		Verbose:  true,
+		MinScore: &minScore,
+		Owner:    &owner,
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected report\nGot: %#v\nExpected: %#v", report, expected)
+	}
+
+	// элемент списка доходит как есть, даже если он единственный
+	report, err = api.Report(ctx, ReportParams{Paging: Paging{Limit: 1}, Period: Period{From: from, Step: time.Hour}, Account: 1, Labels: []string{" a,b "}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(report.Labels, []string{" a,b "}) {
+		t.Errorf("unexpected labels %#v", report.Labels)
+	}
+
+	// поле-значение передаётся и нулевым, default сервера к нему не применяется
+	zero := 0.0
+	report, err = api.Report(ctx, ReportParams{Paging: Paging{Limit: 1}, Period: Period{From: from, Step: time.Hour}, Account: 1, Ratio: &zero})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = &Report{
+		Limit:   1,
+		From:    from,
+		Step:    "1h0m0s",
+		Account: 1,
+		Tags:    []string{"red", "green"},
+		IDs:     []int{},
+		Ratio:   &zero,
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected report\nGot: %#v\nExpected: %#v", report, expected)
+	}
+
+	_, err = api.Report(ctx, ReportParams{Period: Period{From: from, Step: time.Hour}, Account: 1})
+	expectApiError(t, err, http.StatusBadRequest, "limit must be >= 1")
+
+	// default сервера получает только пустой указатель
+	report, err = api.Report(ctx, ReportParams{Paging: Paging{Limit: 1}, Period: Period{From: from, Step: time.Hour}, Account: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Ratio == nil || *report.Ratio != 0.5 {
+		t.Errorf("expected default ratio 0.5, got %v", report.Ratio)
+	}
+
+	_, err = api.Report(ctx, ReportParams{Paging: Paging{Limit: 1}, Period: Period{From: from, Step: time.Second}, Account: 1})
+	expectApiError(t, err, http.StatusBadRequest, "period.step must be >= 1m")
+
+	_, err = api.Report(ctx, ReportParams{Paging: Paging{Limit: 1}, Period: Period{From: from, Step: time.Hour}, Account: 1, IDs: []int{1, 2, 3, 4, 5, 6}})
+	expectApiError(t, err, http.StatusBadRequest, "id len must be <= 5")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := api.Report(cancelled, ReportParams{Paging: Paging{Limit: 1}, Period: Period{From: from, Step: time.Hour}, Account: 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestReportApiOpenAPI(t *testing.T) {
+	document := fetchOpenAPI(t, NewReportApi())
+
+	report := []string{"paths", "/report", "get"}
+	expectMissing(t, document, "components", "securitySchemes")
+	expectMissing(t, document, append(report, "requestBody")...)
+
+	params := append(report, "parameters")
+	expectJSON(t, document,
+		CR{"name": "period.from", "in": "query", "required": true, "schema": CR{"type": "string", "format": "date-time"}},
+		append(params, "period.from")...)
+	expectJSON(t, document, CR{"type": "integer", "minimum": 1, "maximum": 100, "default": 10}, append(params, "limit", "schema")...)
+	expectJSON(t, document, "1h", append(params, "period.step", "schema", "default")...)
+	expectJSON(t, document,
+		CR{
+			"name": "tag", "in": "query", "style": "form", "explode": true,
+			"schema": CR{
+				"type":    "array",
+				"items":   CR{"type": "string", "enum": []string{"red", "green", "blue"}},
+				"default": []string{"red", "green"},
+			},
+		},
+		append(params, "tag")...)
+	expectJSON(t, document, CR{"type": "array", "items": CR{"type": "integer"}, "maxItems": 5}, append(params, "id", "schema")...)
+	expectJSON(t, document, false, append(params, "verbose", "schema", "default")...)
+	expectJSON(t, document,
+		CR{"type": "number", "format": "double", "nullable": true, "minimum": 0},
+		append(params, "min_score", "schema")...)
+
+	expectJSON(t, document, CR{"type": "string", "format": "date-time"}, "components", "schemas", "Report", "properties", "from")
+	expectJSON(t, document, CR{"type": "string", "nullable": true}, "components", "schemas", "Report", "properties", "owner")
+}